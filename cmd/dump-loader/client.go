@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/dump"
+)
+
+// errNotFound is returned for requests the server answers with 404.
+var errNotFound = errors.New("not found")
+
+// statusError is a response, other than 404, that indicates failure.
+type statusError struct {
+	status int
+	msg    string
+	body   []byte
+}
+
+func (e *statusError) Error() string {
+	if e.msg != "" {
+		return fmt.Sprintf("%s: %s", http.StatusText(e.status), e.msg)
+	}
+	return http.StatusText(e.status)
+}
+
+// Indicates whether a request that failed with the status might succeed if it
+// is tried again.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// client sends requests to a go server, retrying the ones that fail for what
+// might be temporary reasons.
+type client struct {
+	http *http.Client
+
+	// headers added to every request, e.g. to authenticate.
+	header http.Header
+
+	// how many times a failed request is tried again.
+	retries int
+
+	// how long to wait before the first retry. The wait doubles after each.
+	backoff time.Duration
+}
+
+// Send a request once, returning the body of the response.
+func (c *client) send(ctx context.Context, method, u, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	} else if res.StatusCode/100 != 2 {
+		var m struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(b, &m)
+		return nil, &statusError{status: res.StatusCode, msg: m.Error, body: b}
+	}
+
+	return b, nil
+}
+
+// Send a request, trying it again with increasing waits in between while it
+// fails in a way that might be temporary.
+func (c *client) do(ctx context.Context, method, u, contentType string, body []byte) ([]byte, error) {
+	wait := c.backoff
+	for i := 0; ; i++ {
+		b, err := c.send(ctx, method, u, contentType, body)
+
+		var se *statusError
+		if err == nil || errors.Is(err, errNotFound) || i == c.retries ||
+			(errors.As(err, &se) && !retryable(se.status)) {
+			return b, err
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		wait *= 2
+	}
+}
+
+// Get the JSON at the URL and decode it into v.
+func (c *client) getJSON(ctx context.Context, u string, v any) error {
+	b, err := c.do(ctx, http.MethodGet, u, "", nil)
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", u, err)
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Get the named route from the server at base, or nil if there is none.
+func (c *client) getRoute(ctx context.Context, base, name string) (*dump.Entry, error) {
+	var m struct {
+		Route *dump.Entry `json:"route"`
+	}
+
+	if err := c.getJSON(ctx, base+"/api/url/"+url.PathEscape(name), &m); errors.Is(err, errNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if m.Route == nil || m.Route.Route == nil {
+		return nil, errors.New("no route in response")
+	}
+
+	return m.Route, nil
+}
+
+// importResult is the response to an import.
+type importResult struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Skipped []string `json:"skipped"`
+}
+
+// Import the routes into the server at base in a single batch. When the
+// policy is fail and some of the routes exist, it returns them as Skipped
+// along with the error.
+func (c *client) importRoutes(
+	ctx context.Context,
+	base string,
+	routes []*dump.Entry,
+	policy internal.ConflictPolicy,
+) (*importResult, error) {
+	var buf bytes.Buffer
+
+	w, err := dump.NewWriter(&buf, dump.JSONL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range routes {
+		if err := w.Write(e); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	u := base + "/api/import?" + url.Values{"conflict": {string(policy)}}.Encode()
+
+	var res importResult
+	b, err := c.do(ctx, http.MethodPost, u, "application/x-ndjson", buf.Bytes())
+
+	var se *statusError
+	if errors.As(err, &se) && se.status == http.StatusConflict {
+		json.Unmarshal(se.body, &res)
+		return &res, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/dump"
+)
+
+// action is what loading a route did, or would do, to the server.
+type action int
+
+const (
+	actionCreate action = iota
+	actionUpdate
+	actionSkip
+	actionFail
+)
+
+// result describes the outcome of loading a single route.
+type result struct {
+	route  *dump.Entry
+	old    *dump.Entry
+	action action
+	err    error
+}
+
+// summary counts the outcomes of loading every route.
+type summary struct {
+	Created  int
+	Updated  int
+	Skipped  int
+	Failed   int
+	Failures []*result
+}
+
+func (s *summary) add(r *result) {
+	switch r.action {
+	case actionCreate:
+		s.Created++
+	case actionUpdate:
+		s.Updated++
+	case actionSkip:
+		s.Skipped++
+	case actionFail:
+		s.Failed++
+		s.Failures = append(s.Failures, r)
+	}
+}
+
+// Write the counts, and the reason each failure failed, to w.
+func (s *summary) write(w io.Writer, dryRun bool) {
+	verb := "created %d, updated %d, skipped %d, failed %d\n"
+	if dryRun {
+		verb = "would create %d, update %d, skip %d; failed %d\n"
+	}
+	fmt.Fprintf(w, verb, s.Created, s.Updated, s.Skipped, s.Failed)
+
+	sort.Slice(s.Failures, func(i, j int) bool {
+		return s.Failures[i].route.Name < s.Failures[j].route.Name
+	})
+
+	for _, r := range s.Failures {
+		fmt.Fprintf(w, "  %s: %s\n", r.route.Name, r.err)
+	}
+}
+
+// The parts of a route that are compared to decide whether it changed,
+// written the same way however they were given.
+type routeFields struct {
+	URL         string
+	Passthrough internal.Passthrough
+	Description string
+	Tags        []string
+	Owner       string
+	CoOwners    []string
+}
+
+func routeFieldsOf(e *dump.Entry) *routeFields {
+	c := &routeFields{
+		URL:         e.URL,
+		Passthrough: e.Passthrough,
+		Description: e.Description,
+		Tags:        e.Tags,
+		Owner:       e.Owner,
+		CoOwners:    e.CoOwners,
+	}
+
+	if c.Passthrough == "" {
+		c.Passthrough = internal.PassthroughAppend
+	}
+
+	if len(c.Tags) == 0 {
+		c.Tags = nil
+	}
+
+	if len(c.CoOwners) == 0 {
+		c.CoOwners = nil
+	}
+
+	return c
+}
+
+// Indicates whether the route on the server is the same as the one in the
+// dump.
+func sameRoute(a, b *dump.Entry) bool {
+	return reflect.DeepEqual(routeFieldsOf(a), routeFieldsOf(b))
+}
+
+// loader loads routes into the server at base.
+type loader struct {
+	client  *client
+	base    string
+	workers int
+
+	// how many routes are sent in each import.
+	batchSize int
+
+	// what the server does with routes that already exist.
+	policy internal.ConflictPolicy
+
+	// when set, nothing is written to the server. Instead, each route is
+	// compared to the one on the server to find what would change.
+	dryRun bool
+}
+
+// Find what loading the route would do by comparing it to the one on the
+// server. A route that already exists is classified by the policy alone, as
+// the server does, even when nothing about it would change.
+func (l *loader) planRoute(ctx context.Context, rt *dump.Entry) *result {
+	res := &result{route: rt}
+
+	old, err := l.client.getRoute(ctx, l.base, rt.Name)
+	if err != nil {
+		res.action, res.err = actionFail, err
+		return res
+	}
+	res.old = old
+
+	switch {
+	case old == nil:
+		res.action = actionCreate
+	case l.policy == internal.ConflictOverwrite:
+		res.action = actionUpdate
+	case l.policy == internal.ConflictSkip:
+		res.action = actionSkip
+	default:
+		res.action, res.err = actionFail, internal.ErrRouteExists
+	}
+
+	return res
+}
+
+// Import a batch of routes and find what happened to each of them.
+func (l *loader) importBatch(ctx context.Context, batch []*dump.Entry) []*result {
+	results := make([]*result, 0, len(batch))
+
+	res, err := l.client.importRoutes(ctx, l.base, batch, l.policy)
+	if err != nil {
+		// nothing in the batch was imported.
+		if res != nil && len(res.Skipped) > 0 {
+			err = fmt.Errorf("%w: %s", err, strings.Join(res.Skipped, ", "))
+		}
+
+		for _, rt := range batch {
+			results = append(results, &result{route: rt, action: actionFail, err: err})
+		}
+		return results
+	}
+
+	actions := map[string]action{}
+	for _, name := range res.Created {
+		actions[name] = actionCreate
+	}
+	for _, name := range res.Updated {
+		actions[name] = actionUpdate
+	}
+	for _, name := range res.Skipped {
+		actions[name] = actionSkip
+	}
+
+	for _, rt := range batch {
+		r := &result{route: rt}
+		if act, ok := actions[rt.Name]; ok {
+			r.action = act
+		} else {
+			r.action, r.err = actionFail, fmt.Errorf("not in the server's response")
+		}
+		results = append(results, r)
+	}
+
+	return results
+}
+
+// Load every route using a bounded number of workers and return the result
+// of each, in order of name.
+func (l *loader) load(ctx context.Context, routes []*dump.Entry) []*result {
+	workers := l.workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	size := l.batchSize
+	if l.dryRun || size < 1 {
+		size = 1
+	}
+
+	ch := make(chan []*dump.Entry)
+	results := make([]*result, 0, len(routes))
+
+	var lck sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range ch {
+				var res []*result
+				if l.dryRun {
+					for _, rt := range batch {
+						res = append(res, l.planRoute(ctx, rt))
+					}
+				} else {
+					res = l.importBatch(ctx, batch)
+				}
+
+				lck.Lock()
+				results = append(results, res...)
+				lck.Unlock()
+			}
+		}()
+	}
+
+	for i := 0; i < len(routes); i += size {
+		ch <- routes[i:min(i+size, len(routes))]
+	}
+	close(ch)
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].route.Name < results[j].route.Name
+	})
+
+	return results
+}
+
+// Write the differences between the routes in the dump and those on the
+// server to w, with + for routes that would be created and ~ for routes that
+// would change. Routes that would be overwritten with the same fields are left
+// out.
+func writeDiff(w io.Writer, results []*result) {
+	for _, r := range results {
+		switch r.action {
+		case actionCreate:
+			fmt.Fprintf(w, "+ %s %s\n", r.route.Name, r.route.URL)
+		case actionUpdate:
+			if sameRoute(r.old, r.route) {
+				continue
+			}
+			fmt.Fprintf(w, "~ %s\n", r.route.Name)
+			a, b := routeFieldsOf(r.old), routeFieldsOf(r.route)
+			if a.URL != b.URL {
+				fmt.Fprintf(w, "    url: %s -> %s\n", a.URL, b.URL)
+			}
+			if a.Passthrough != b.Passthrough {
+				fmt.Fprintf(w, "    passthrough: %s -> %s\n", a.Passthrough, b.Passthrough)
+			}
+			if a.Description != b.Description {
+				fmt.Fprintf(w, "    description: %q -> %q\n", a.Description, b.Description)
+			}
+			if !reflect.DeepEqual(a.Tags, b.Tags) {
+				fmt.Fprintf(w, "    tags: %v -> %v\n", a.Tags, b.Tags)
+			}
+			if a.Owner != b.Owner {
+				fmt.Fprintf(w, "    owner: %s -> %s\n", a.Owner, b.Owner)
+			}
+			if !reflect.DeepEqual(a.CoOwners, b.CoOwners) {
+				fmt.Fprintf(w, "    co_owners: %v -> %v\n", a.CoOwners, b.CoOwners)
+			}
+		}
+	}
+}
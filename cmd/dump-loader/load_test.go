@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend/memory"
+	"github.com/kellegous/go/internal/dump"
+	"github.com/kellegous/go/internal/web"
+)
+
+func newServer(t *testing.T, routes map[string]string) (*httptest.Server, *memory.Backend) {
+	t.Helper()
+
+	backend := memory.New()
+	for name, u := range routes {
+		if err := backend.Put(context.Background(), name, &internal.Route{
+			URL:         u,
+			Passthrough: internal.PassthroughAppend,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	web.Setup(mux, backend, "")
+
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s, backend
+}
+
+func newClient() *client {
+	return &client{
+		http:    http.DefaultClient,
+		retries: 3,
+		backoff: time.Millisecond,
+	}
+}
+
+func actionsOf(results []*result) map[string]action {
+	actions := map[string]action{}
+	for _, r := range results {
+		actions[r.route.Name] = r.action
+	}
+	return actions
+}
+
+func entry(name, u string, tags ...string) *dump.Entry {
+	return &dump.Entry{
+		Name:  name,
+		Route: &internal.Route{URL: u, Tags: tags},
+	}
+}
+
+func TestLoadDryRun(t *testing.T) {
+	s, backend := newServer(t, map[string]string{
+		"a": "http://a/",
+		"b": "http://b/",
+	})
+
+	routes := []*dump.Entry{
+		entry("a", "http://a/"),
+		entry("b", "http://b/", "x"),
+		entry("c", "http://c/"),
+	}
+
+	l := &loader{
+		client:  newClient(),
+		base:    s.URL,
+		workers: 2,
+		policy:  internal.ConflictOverwrite,
+		dryRun:  true,
+	}
+
+	// a is overwritten even though it's the same, as it would be by the server.
+	expected := map[string]action{
+		"a": actionUpdate,
+		"b": actionUpdate,
+		"c": actionCreate,
+	}
+
+	results := l.load(context.Background(), routes)
+	if actions := actionsOf(results); !reflect.DeepEqual(actions, expected) {
+		t.Fatalf("expected %v, got %v", expected, actions)
+	}
+
+	var buf bytes.Buffer
+	writeDiff(&buf, results)
+	if diff := buf.String(); diff != "~ b\n    tags: [] -> [x]\n+ c http://c/\n" {
+		t.Fatalf("unexpected diff: %q", diff)
+	}
+
+	// a dry run changes nothing.
+	if _, err := backend.Get(context.Background(), "c"); err == nil {
+		t.Fatal("expected c not to be created")
+	}
+
+	// routes that differ are what the policy says they would be.
+	l.policy = internal.ConflictSkip
+	if act := actionsOf(l.load(context.Background(), routes))["b"]; act != actionSkip {
+		t.Fatalf("expected b to be skipped, got %v", act)
+	}
+
+	l.policy = internal.ConflictFail
+	if act := actionsOf(l.load(context.Background(), routes))["b"]; act != actionFail {
+		t.Fatalf("expected b to fail, got %v", act)
+	}
+}
+
+func TestLoadDryRunMatchesLoad(t *testing.T) {
+	for _, policy := range []internal.ConflictPolicy{
+		internal.ConflictOverwrite,
+		internal.ConflictSkip,
+	} {
+		s, _ := newServer(t, map[string]string{
+			"a": "http://a/",
+			"b": "http://b/",
+		})
+
+		routes := []*dump.Entry{
+			entry("a", "http://a/"),
+			entry("b", "http://b/", "x"),
+			entry("c", "http://c/"),
+		}
+
+		l := &loader{
+			client:    newClient(),
+			base:      s.URL,
+			workers:   2,
+			batchSize: 10,
+			policy:    policy,
+			dryRun:    true,
+		}
+
+		planned := actionsOf(l.load(context.Background(), routes))
+
+		l.dryRun = false
+		loaded := actionsOf(l.load(context.Background(), routes))
+
+		if !reflect.DeepEqual(planned, loaded) {
+			t.Fatalf("with %s, expected the dry run to report %v, got %v", policy, loaded, planned)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	s, backend := newServer(t, map[string]string{
+		"a": "http://a/",
+		"b": "http://b/",
+	})
+
+	routes := []*dump.Entry{
+		entry("a", "http://a/"),
+		entry("b", "http://b/", "x"),
+		entry("c", "http://c/"),
+		entry("d", "http://d/"),
+		entry("e", "not a url {"),
+	}
+
+	l := &loader{
+		client:    newClient(),
+		base:      s.URL,
+		workers:   2,
+		batchSize: 2,
+		policy:    internal.ConflictSkip,
+	}
+
+	expected := map[string]action{
+		"a": actionSkip,
+		"b": actionSkip,
+		"c": actionCreate,
+		"d": actionCreate,
+		"e": actionFail,
+	}
+
+	var sum summary
+	results := l.load(context.Background(), routes)
+	for _, r := range results {
+		sum.add(r)
+	}
+
+	if actions := actionsOf(results); !reflect.DeepEqual(actions, expected) {
+		t.Fatalf("expected %v, got %v", expected, actions)
+	}
+
+	if sum.Created != 2 || sum.Skipped != 2 || sum.Failed != 1 || sum.Failures[0].route.Name != "e" {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+
+	rt, err := backend.Get(context.Background(), "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rt.Tags) != 0 {
+		t.Fatalf("expected b to be left alone, got %v", rt.Tags)
+	}
+
+	// a batch with any existing routes fails as a whole.
+	l.policy = internal.ConflictFail
+	results = l.load(context.Background(), routes[1:3])
+	for _, r := range results {
+		if r.action != actionFail || !strings.Contains(r.err.Error(), "b") {
+			t.Fatalf("expected %s to fail naming b, got %v %v", r.route.Name, r.action, r.err)
+		}
+	}
+
+	l.policy = internal.ConflictOverwrite
+	if act := actionsOf(l.load(context.Background(), routes[:2]))["b"]; act != actionUpdate {
+		t.Fatalf("expected b to be updated, got %v", act)
+	}
+
+	if rt, err = backend.Get(context.Background(), "b"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(rt.Tags, []string{"x"}) {
+		t.Fatalf("expected b to be tagged, got %v", rt.Tags)
+	}
+}
+
+func TestLoadSendsHeaders(t *testing.T) {
+	s, backend := newServer(t, nil)
+
+	authed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Forwarded-User") != "alice" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer authed.Close()
+
+	header, err := parseHeaders([]string{"X-Forwarded-User: alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient()
+	c.header = header
+
+	l := &loader{
+		client:    c,
+		base:      authed.URL,
+		workers:   1,
+		batchSize: 10,
+		policy:    internal.ConflictOverwrite,
+	}
+
+	if act := actionsOf(l.load(context.Background(), []*dump.Entry{entry("a", "http://a/")}))["a"]; act != actionFail {
+		t.Fatalf("expected a to fail without a token, got %v", act)
+	}
+
+	header.Set("Authorization", "Bearer secret")
+	if act := actionsOf(l.load(context.Background(), []*dump.Entry{entry("a", "http://a/")}))["a"]; act != actionCreate {
+		t.Fatalf("expected a to be created, got %v", act)
+	}
+
+	if _, err := backend.Get(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parseHeaders([]string{"no colon"}); err == nil {
+		t.Fatal("expected an error for a header without a colon")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	s, _ := newServer(t, nil)
+
+	var calls int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1)%3 != 0 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		s.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer flaky.Close()
+
+	c := newClient()
+	routes := []*dump.Entry{entry("a", "http://a/")}
+	if _, err := c.importRoutes(context.Background(), flaky.URL, routes, internal.ConflictOverwrite); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+
+	c.retries = 1
+	if _, err := c.importRoutes(context.Background(), flaky.URL, routes, internal.ConflictOverwrite); err == nil {
+		t.Fatal("expected an error once the retries ran out")
+	}
+
+	// requests that can't succeed aren't retried.
+	calls = 2
+	if _, err := c.importRoutes(context.Background(), flaky.URL, []*dump.Entry{entry("a", "")}, internal.ConflictOverwrite); err == nil {
+		t.Fatal("expected an error for a route without a url")
+	} else if calls != 3 {
+		t.Fatalf("expected 1 call, got %d", calls-2)
+	}
+}
+
+func TestReadRoutesFollowsNext(t *testing.T) {
+	s, _ := newServer(t, map[string]string{
+		"a": "http://a/",
+		"b": "http://b/",
+		"c": "http://c/",
+	})
+
+	res, err := http.Get(s.URL + "/api/urls/?limit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	filename := filepath.Join(t.TempDir(), "dump.json")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.ReadFrom(res.Body); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := readRoutes(context.Background(), newClient(), filename, ""); err == nil || !strings.Contains(err.Error(), "--source") {
+		t.Fatalf("expected an error for a partial dump, got %v", err)
+	}
+
+	routes, err := readRoutes(context.Background(), newClient(), filename, s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, rt := range routes {
+		names = append(names, rt.Name)
+	}
+
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Fatalf("expected a, b and c, got %v", names)
+	}
+}
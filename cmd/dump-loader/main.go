@@ -1,20 +1,22 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+
+	"github.com/kellegous/go/internal"
 )
 
 const (
-	apiPath = "%s://%s:%s/api/url/%s"
+	baseURL = "%s://%s:%s"
 )
 
 type config struct {
@@ -22,17 +24,29 @@ type config struct {
 	host     string
 	port     string
 	dumpFile string
+	source   string
+	workers  int
+	batch    int
+	conflict string
+	headers  []string
+	retries  int
+	backoff  time.Duration
+	timeout  time.Duration
+	dryRun   bool
+	diff     bool
 }
 
-type RoutesDump struct {
-	OK     bool    `json:"ok"`
-	Routes []Route `json:"routes"`
-	Next   string  `json:"next"`
-}
-
-type Route struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
+// Parse headers given as "Name: value".
+func parseHeaders(headers []string) (http.Header, error) {
+	h := http.Header{}
+	for _, s := range headers {
+		k, v, ok := strings.Cut(s, ":")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid header %q, expected 'Name: value'", s)
+		}
+		h.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	return h, nil
 }
 
 func main() {
@@ -40,7 +54,18 @@ func main() {
 	pflag.StringVar(&c.proto, "protocol", "https", "protocol to use. Only HTTP or HTTPS supported")
 	pflag.StringVar(&c.host, "host", "localhost", "host to post data to")
 	pflag.StringVar(&c.port, "port", "8067", "port on host to talk to")
-	pflag.StringVar(&c.dumpFile, "file", "", "dump file to load from")
+	pflag.StringVar(&c.dumpFile, "file", "", "dump file to load from, either pages of /api/urls/ or a .jsonl, .csv or .yaml export")
+	pflag.StringVar(&c.source, "source", "", "base URL of the server the dump came from, e.g. https://go.example.com, used to fetch the pages that follow a partial dump. Without --file, every page is fetched from it.")
+	pflag.IntVar(&c.workers, "workers", 4, "how many batches of links to load at once")
+	pflag.IntVar(&c.batch, "batch-size", 1000, "how many links to send in each request to /api/import")
+	pflag.StringVar(&c.conflict, "conflict", "overwrite", "what to do with links that already exist: 'overwrite', 'skip' or 'fail', which loads nothing from a batch that has any")
+	pflag.String("token", "", "API token with which to authenticate, sent as Authorization: Bearer")
+	pflag.StringArrayVar(&c.headers, "header", nil, "header to send with each request, e.g. 'X-Forwarded-User: alice'. May be repeated.")
+	pflag.IntVar(&c.retries, "retries", 3, "how many times to retry a request that fails with a network error, 429 or 5xx")
+	pflag.DurationVar(&c.backoff, "backoff", 500*time.Millisecond, "how long to wait before the first retry; the wait doubles after each")
+	pflag.DurationVar(&c.timeout, "timeout", 30*time.Second, "how long to wait for each request")
+	pflag.BoolVar(&c.dryRun, "dry-run", false, "report what would change without changing anything")
+	pflag.BoolVar(&c.diff, "diff", false, "print the differences between the dump and the server without changing anything")
 	pflag.Parse()
 
 	if err := viper.BindPFlags(pflag.CommandLine); err != nil {
@@ -51,40 +76,62 @@ func main() {
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 
-	if c.dumpFile == "" {
-		log.Fatal("dump file must be specified with --file argument")
+	if c.dumpFile == "" && c.source == "" {
+		log.Fatal("dump file must be specified with --file argument, or a server to copy with --source")
 	}
 
-	var d RoutesDump
-
-	f, err := ioutil.ReadFile(c.dumpFile)
+	policy := internal.ConflictPolicy(c.conflict)
+	if !policy.Valid() {
+		log.Fatalf("invalid --conflict value: %s", c.conflict)
+	}
 
+	header, err := parseHeaders(c.headers)
 	if err != nil {
-		log.Printf("error reading dump file : %s\n", c.dumpFile)
 		log.Fatal(err)
 	}
 
-	err = json.Unmarshal(f, &d)
+	// the token can also come from the environment, so it needn't be on the
+	// command line.
+	if token := viper.GetString("token"); token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	ctx := context.Background()
+
+	cl := &client{
+		http:    &http.Client{Timeout: c.timeout},
+		header:  header,
+		retries: c.retries,
+		backoff: c.backoff,
+	}
 
+	routes, err := readRoutes(ctx, cl, c.dumpFile, strings.TrimSuffix(c.source, "/"))
 	if err != nil {
-		log.Printf("error parsing dump file : %s\n", c.dumpFile)
 		log.Fatal(err)
 	}
 
-	for _, v := range d.Routes {
-		req := fmt.Sprintf(apiPath, c.proto, c.host, c.port, v.Name)
-		p, err := json.Marshal(&v)
-		if err != nil {
-			log.Printf("error marshalling data for link : %s\n", v.Name)
-			log.Println(err)
-			continue
-		}
-		resp, err := http.Post(req, "application/json", bytes.NewReader(p))
-		if err != nil {
-			log.Printf("error POSTing link : %s %s\n", v.Name, err.Error())
-		} else {
-			log.Printf("POSTed short link (%s) : %s\n", resp.Status, v.Name)
-			resp.Body.Close()
-		}
+	l := &loader{
+		client:    cl,
+		base:      fmt.Sprintf(baseURL, c.proto, c.host, c.port),
+		workers:   c.workers,
+		batchSize: c.batch,
+		policy:    policy,
+		dryRun:    c.dryRun || c.diff,
+	}
+
+	results := l.load(ctx, routes)
+
+	if c.diff {
+		writeDiff(os.Stdout, results)
+	}
+
+	var s summary
+	for _, r := range results {
+		s.add(r)
+	}
+	s.write(os.Stderr, l.dryRun)
+
+	if s.Failed > 0 {
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/kellegous/go/internal/dump"
+)
+
+type RoutesDump struct {
+	OK     bool          `json:"ok"`
+	Routes []*dump.Entry `json:"routes"`
+	Next   string        `json:"next"`
+}
+
+// Read the routes in a dump in one of the export formats.
+func readDump(r io.Reader, f dump.Format) ([]*dump.Entry, error) {
+	dr, err := dump.NewReader(r, f)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []*dump.Entry
+	for {
+		e, err := dr.Read()
+		if errors.Is(err, io.EOF) {
+			return routes, nil
+		} else if err != nil {
+			return nil, err
+		}
+		routes = append(routes, e)
+	}
+}
+
+// Read a dump of one or more pages of /api/urls/, one after another, and
+// return the routes along with the cursor of the page that follows the last
+// one.
+func readPages(r io.Reader) ([]*dump.Entry, string, error) {
+	var routes []*dump.Entry
+	var next string
+
+	dec := json.NewDecoder(r)
+	for {
+		var d RoutesDump
+		if err := dec.Decode(&d); errors.Is(err, io.EOF) {
+			return routes, next, nil
+		} else if err != nil {
+			return nil, "", err
+		}
+
+		for _, e := range d.Routes {
+			if e.Route == nil || e.URL == "" {
+				return nil, "", fmt.Errorf("route %q has no url", e.Name)
+			}
+		}
+
+		routes = append(routes, d.Routes...)
+		next = d.Next
+	}
+}
+
+// Fetch the pages of /api/urls/ on the server at source, starting with the
+// one at cursor, until there are no more.
+func fetchPages(ctx context.Context, c *client, source, cursor string) ([]*dump.Entry, error) {
+	var routes []*dump.Entry
+	for {
+		q := url.Values{
+			"include-generated-names": {"true"},
+			"limit":                   {"1000"},
+		}
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+
+		var d RoutesDump
+		if err := c.getJSON(ctx, source+"/api/urls/?"+q.Encode(), &d); err != nil {
+			return nil, err
+		}
+
+		routes = append(routes, d.Routes...)
+
+		if d.Next == "" {
+			return routes, nil
+		}
+		cursor = d.Next
+	}
+}
+
+// Read the routes to load from the dump file, if there is one, and from the
+// server at source, if there is one. A dump from /api/urls/ that ends with a
+// cursor is continued by fetching the rest from source.
+func readRoutes(ctx context.Context, c *client, filename, source string) ([]*dump.Entry, error) {
+	if filename == "" {
+		return fetchPages(ctx, c, source, "")
+	}
+
+	r, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// dumps in one of the export formats are recognized by their extension.
+	if f, err := dump.FormatOf(filename); err == nil {
+		return readDump(r, f)
+	}
+
+	routes, next, err := readPages(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+
+	if next == "" {
+		return routes, nil
+	} else if source == "" {
+		return nil, fmt.Errorf("%s is only part of a dump; pass --source to fetch the rest", filename)
+	}
+
+	rest, err := fetchPages(ctx, c, source, next)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(routes, rest...), nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+	"github.com/kellegous/go/internal/dump"
+)
+
+// The actor to which an import attributes the routes it puts.
+const importActor = "import"
+
+// Find the format to use for a file, preferring the one given by a flag.
+func formatFor(name, filename string) (dump.Format, error) {
+	if name != "" {
+		return dump.ParseFormat(name)
+	}
+
+	if filename == "-" {
+		return "", errors.New("--format must be specified when using stdin or stdout")
+	}
+
+	return dump.FormatOf(filename)
+}
+
+// Run the export subcommand, which writes every route in a backend to a file.
+func runExport(args []string) error {
+	flags := pflag.NewFlagSet("export", pflag.ContinueOnError)
+	from := flags.String("from", "", "The backend to export, e.g. leveldb:/data")
+	out := flags.String("out", "-", "The file to write, or - for stdout")
+	format := flags.String("format", "", "The format to write: jsonl, csv or yaml. Defaults to the extension of --out.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *from == "" {
+		return errors.New("--from must be specified")
+	}
+
+	f, err := formatFor(*format, *out)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	src, err := openBackendSpec(ctx, *from)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", *from, err)
+	}
+	defer src.Close()
+
+	var file *os.File
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		if file, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	iter, err := src.Dump(ctx)
+	if err != nil {
+		return err
+	}
+	defer iter.Release()
+
+	dw, err := dump.NewWriter(w, f)
+	if err != nil {
+		return err
+	}
+
+	n, err := dump.WriteAll(dw, iter)
+	if err != nil {
+		return fmt.Errorf("exporting routes: %w", err)
+	}
+
+	if err := dw.Close(); err != nil {
+		return err
+	}
+
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("exported %d routes", n)
+	return nil
+}
+
+// Run the import subcommand, which puts every route in the given files into a
+// backend, replacing any routes with the same names.
+func runImport(args []string) error {
+	flags := pflag.NewFlagSet("import", pflag.ContinueOnError)
+	to := flags.String("to", "", "The backend to import into, e.g. leveldb:/data")
+	format := flags.String("format", "", "The format of the files: jsonl, csv or yaml. Defaults to the extension of each file.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *to == "" {
+		return errors.New("--to must be specified")
+	}
+
+	filenames := flags.Args()
+	if len(filenames) == 0 {
+		return errors.New("no files to import; use - for stdin")
+	}
+
+	ctx := internal.WithActor(context.Background(), importActor)
+
+	dst, err := openBackendSpec(ctx, *to)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", *to, err)
+	}
+	defer dst.Close()
+
+	for _, filename := range filenames {
+		f, err := formatFor(*format, filename)
+		if err != nil {
+			return err
+		}
+
+		n, err := importFile(ctx, dst, filename, f)
+		if err != nil {
+			return fmt.Errorf("importing %s: %w", filename, err)
+		}
+
+		log.Printf("imported %d routes from %s", n, filename)
+	}
+
+	return nil
+}
+
+// Load the routes in the file, or stdin if it is -, into the backend.
+func importFile(ctx context.Context, dst backend.Backend, filename string, f dump.Format) (int, error) {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	dr, err := dump.NewReader(r, f)
+	if err != nil {
+		return 0, err
+	}
+
+	return dump.Load(ctx, dst, dr)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+	"github.com/kellegous/go/internal/migrate"
+)
+
+// The actor to which the migration attributes the routes it copies.
+const migrateActor = "migrate"
+
+// Open the backend described by spec, which is the kind of backend and its
+// argument separated by a colon, e.g. leveldb:/data or firestore:project.
+func openBackendSpec(ctx context.Context, spec string) (backend.Backend, error) {
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok && kind != "firestore" {
+		return nil, fmt.Errorf("invalid backend %q, expected kind:argument", spec)
+	}
+	return openBackend(ctx, kind, arg)
+}
+
+// Indicates whether the backend has any routes.
+func hasRoutes(ctx context.Context, b backend.Backend) (bool, error) {
+	iter, err := b.List(ctx, "")
+	if err != nil {
+		return false, err
+	}
+	defer iter.Release()
+
+	ok := iter.Next()
+	return ok, iter.Error()
+}
+
+// Run the migrate subcommand, which copies the routes and the ID counter of
+// one backend into another and then checks that they match.
+func runMigrate(args []string) error {
+	flags := pflag.NewFlagSet("migrate", pflag.ContinueOnError)
+	from := flags.String("from", "", "The backend to copy from, e.g. leveldb:/data")
+	to := flags.String("to", "", "The backend to copy to, e.g. firestore:project")
+	checkpoint := flags.String("checkpoint", "migrate.checkpoint", "The file in which progress is recorded so that an interrupted migration can be resumed")
+	resume := flags.Bool("resume", false, "Resume the migration recorded in the checkpoint file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *from == "" || *to == "" {
+		return errors.New("both --from and --to must be specified")
+	}
+
+	ctx := internal.WithActor(context.Background(), migrateActor)
+
+	src, err := openBackendSpec(ctx, *from)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", *from, err)
+	}
+	defer src.Close()
+
+	dst, err := openBackendSpec(ctx, *to)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", *to, err)
+	}
+	defer dst.Close()
+
+	cp := &migrate.Checkpoint{
+		From: *from,
+		To:   *to,
+	}
+
+	if *resume {
+		if cp, err = migrate.ReadCheckpoint(*checkpoint); err != nil {
+			return fmt.Errorf("reading checkpoint: %w", err)
+		}
+
+		if cp.From != *from || cp.To != *to {
+			return fmt.Errorf("%s is a checkpoint of a migration from %s to %s", *checkpoint, cp.From, cp.To)
+		}
+
+		log.Printf("resuming after %q, with %d routes already copied", cp.Last, cp.Copied)
+	} else {
+		if _, err := os.Stat(*checkpoint); err == nil {
+			return fmt.Errorf("%s exists; pass --resume to continue that migration or remove it", *checkpoint)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		if ok, err := hasRoutes(ctx, dst); err != nil {
+			return err
+		} else if ok {
+			return fmt.Errorf("%s already has routes", *to)
+		}
+	}
+
+	copied := cp.Copied
+	if _, err := migrate.Routes(ctx, dst, src, cp.Last, func(last string, n int) error {
+		cp.Last = last
+		cp.Copied = copied + n
+		log.Printf("copied %d routes", cp.Copied)
+		return cp.Write(*checkpoint)
+	}); err != nil {
+		return fmt.Errorf("copying routes: %w", err)
+	}
+
+	id, err := migrate.ID(ctx, dst, src)
+	if err != nil {
+		return fmt.Errorf("copying the ID counter: %w", err)
+	}
+	log.Printf("carried over the ID counter at %d", id)
+
+	report, err := migrate.Verify(ctx, dst, src)
+	if err != nil {
+		return fmt.Errorf("verifying: %w", err)
+	}
+
+	log.Printf("%s has %d routes, %s has %d", *from, report.Source, *to, report.Target)
+
+	if !report.OK() {
+		return fmt.Errorf(
+			"%d routes are missing, %d are different and %d are extra, including %s",
+			report.Missing,
+			report.Changed,
+			report.Extra,
+			strings.Join(report.Mismatches, ", "))
+	}
+
+	if err := os.Remove(*checkpoint); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	log.Printf("migrated %d routes", report.Source)
+	return nil
+}
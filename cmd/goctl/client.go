@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/kellegous/go/internal"
+)
+
+// How many routes are asked for in each page of a listing.
+const pageSize = 1000
+
+// apiError is a response from the server that indicates failure.
+type apiError struct {
+	status int
+	msg    string
+	body   []byte
+}
+
+func (e *apiError) Error() string {
+	if e.msg != "" {
+		return fmt.Sprintf("%s: %s", http.StatusText(e.status), e.msg)
+	}
+	return http.StatusText(e.status)
+}
+
+// route is a route as the API returns it, with its name and, when asked
+// for, its visits.
+type route struct {
+	Name string `json:"name"`
+	*internal.Route
+	Hits *internal.Hits `json:"hits,omitempty"`
+}
+
+// importResult is what an import did to the routes on the server.
+type importResult struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Skipped []string `json:"skipped"`
+}
+
+// client sends requests to the API of a go server.
+type client struct {
+	http   *http.Client
+	server string
+	token  string
+}
+
+// Send a request to the API and decode the JSON response into v, unless v
+// is nil.
+func (c *client) do(
+	ctx context.Context,
+	method, path string,
+	query url.Values,
+	contentType string,
+	body io.Reader,
+	v any,
+) error {
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode/100 != 2 {
+		var m struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(b, &m)
+		return &apiError{status: res.StatusCode, msg: m.Error, body: b}
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// The API path of the named route, or one of its subresources.
+func routePath(name string, sub ...string) string {
+	p := "/api/url/" + url.PathEscape(name)
+	for _, s := range sub {
+		p += "/" + s
+	}
+	return p
+}
+
+// Get the named route.
+func (c *client) get(ctx context.Context, name string) (*route, error) {
+	var m struct {
+		Route *route `json:"route"`
+	}
+
+	if err := c.do(ctx, http.MethodGet, routePath(name), nil, "", nil, &m); err != nil {
+		return nil, err
+	} else if m.Route == nil || m.Route.Route == nil {
+		return nil, fmt.Errorf("no route in response")
+	}
+
+	return m.Route, nil
+}
+
+// Change the named route, or create it, with the fields in req. When create
+// is set, it fails if the route already exists.
+func (c *client) set(ctx context.Context, name string, req map[string]any, create bool) (*route, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var query url.Values
+	if create {
+		query = url.Values{"create": {"true"}}
+	}
+
+	var m struct {
+		Route *route `json:"route"`
+	}
+
+	if err := c.do(ctx, http.MethodPost, routePath(name), query, "application/json", bytes.NewReader(b), &m); err != nil {
+		return nil, err
+	} else if m.Route == nil || m.Route.Route == nil {
+		return nil, fmt.Errorf("no route in response")
+	}
+
+	return m.Route, nil
+}
+
+// Delete the named route.
+func (c *client) del(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, routePath(name), nil, "", nil, nil)
+}
+
+// Get the revisions of the named route, oldest first.
+func (c *client) history(ctx context.Context, name string) ([]*internal.Revision, error) {
+	var m struct {
+		Revisions []*internal.Revision `json:"revisions"`
+	}
+
+	if err := c.do(ctx, http.MethodGet, routePath(name, "history"), nil, "", nil, &m); err != nil {
+		return nil, err
+	}
+
+	return m.Revisions, nil
+}
+
+// List the routes whose names start with prefix, page by page, calling fn
+// with each. Generated names are only listed when generated is set.
+func (c *client) list(
+	ctx context.Context,
+	prefix string,
+	generated bool,
+	fn func(rt *route) error,
+) error {
+	query := url.Values{
+		"limit":                   {strconv.Itoa(pageSize)},
+		"include-generated-names": {strconv.FormatBool(generated)},
+	}
+
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+
+	for {
+		var m struct {
+			Routes []*route `json:"routes"`
+			Next   string   `json:"next"`
+		}
+
+		if err := c.do(ctx, http.MethodGet, "/api/urls/", query, "", nil, &m); err != nil {
+			return err
+		}
+
+		for _, rt := range m.Routes {
+			if err := fn(rt); err != nil {
+				return err
+			}
+		}
+
+		if m.Next == "" {
+			return nil
+		}
+
+		query.Set("cursor", m.Next)
+	}
+}
+
+// Import the body, which is a dump in the given format, doing what the
+// policy says with routes that already exist. When the import fails because
+// some routes exist, it returns them as Skipped along with the error.
+func (c *client) importDump(
+	ctx context.Context,
+	body io.Reader,
+	format, contentType string,
+	policy internal.ConflictPolicy,
+) (*importResult, error) {
+	query := url.Values{
+		"conflict": {string(policy)},
+		"format":   {format},
+	}
+
+	var res importResult
+	err := c.do(ctx, http.MethodPost, "/api/import", query, contentType, body, &res)
+	var ae *apiError
+	if errors.As(err, &ae) && ae.status == http.StatusConflict {
+		json.Unmarshal(ae.body, &res)
+		return &res, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
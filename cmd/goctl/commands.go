@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/dump"
+)
+
+// Returned from within a listing to stop it early.
+var errStop = errors.New("stop")
+
+// Check that there are exactly n arguments.
+func needArgs(args []string, n int, what string) error {
+	if len(args) != n {
+		return fmt.Errorf("expected %s", what)
+	}
+	return nil
+}
+
+// Find the format to use for a file, preferring the one given by a flag.
+func formatFor(name, filename string) (dump.Format, error) {
+	if name != "" {
+		return dump.ParseFormat(name)
+	}
+
+	if filename == "-" {
+		return "", errors.New("--format must be specified when using stdin or stdout")
+	}
+
+	return dump.FormatOf(filename)
+}
+
+var cmdGet = &command{
+	usage: "get <name>",
+	run: func(ctx context.Context, env *env, flags *pflag.FlagSet, args []string) error {
+		if err := needArgs(args, 1, "the name of a link"); err != nil {
+			return err
+		}
+
+		rt, err := env.client.get(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		return env.writeRoute(rt)
+	},
+}
+
+var cmdSet = &command{
+	usage: "set <name> <url>",
+	flags: func(flags *pflag.FlagSet) {
+		flags.String("description", "", "What the link is for")
+		flags.StringSlice("tags", nil, "Tags with which to find the link, separated by commas")
+		flags.String("owner", "", "Who owns the link. Only the owner can give it away.")
+		flags.StringSlice("co-owners", nil, "Other users or groups who may change the link, separated by commas")
+		flags.String("passthrough", "", "What to do with the rest of the path: 'append', 'ignore' or 'reject'")
+		flags.Bool("create", false, "Fail if the link already exists instead of replacing it")
+		flags.Int64("revision", 0, "Fail if the link has changed since this revision")
+	},
+	run: func(ctx context.Context, env *env, flags *pflag.FlagSet, args []string) error {
+		if err := needArgs(args, 2, "the name and url of a link"); err != nil {
+			return err
+		}
+
+		// only the fields that were given are changed.
+		req := map[string]any{"url": args[1]}
+		for _, name := range []string{"description", "owner", "passthrough"} {
+			if flags.Changed(name) {
+				v, _ := flags.GetString(name)
+				req[name] = v
+			}
+		}
+
+		for _, name := range []string{"tags", "co-owners"} {
+			if flags.Changed(name) {
+				v, _ := flags.GetStringSlice(name)
+				if v == nil {
+					v = []string{}
+				}
+				req[strings.ReplaceAll(name, "-", "_")] = v
+			}
+		}
+
+		if flags.Changed("revision") {
+			req["revision"], _ = flags.GetInt64("revision")
+		}
+
+		create, _ := flags.GetBool("create")
+
+		rt, err := env.client.set(ctx, args[0], req, create)
+		if err != nil {
+			return err
+		}
+
+		return env.writeRoute(rt)
+	},
+}
+
+var cmdRm = &command{
+	usage: "rm <name>...",
+	run: func(ctx context.Context, env *env, flags *pflag.FlagSet, args []string) error {
+		if len(args) == 0 {
+			return errors.New("expected the names of links")
+		}
+
+		for _, name := range args {
+			if err := env.client.del(ctx, name); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+
+		return nil
+	},
+}
+
+var cmdLs = &command{
+	usage: "ls [--prefix=<prefix>]",
+	flags: func(flags *pflag.FlagSet) {
+		flags.String("prefix", "", "Only list links whose names start with this")
+		flags.Bool("generated", false, "Also list links with generated names")
+		flags.Int("limit", 0, "The most links to list, or 0 for all of them")
+	},
+	run: func(ctx context.Context, env *env, flags *pflag.FlagSet, args []string) error {
+		if err := needArgs(args, 0, "no arguments"); err != nil {
+			return err
+		}
+
+		prefix, _ := flags.GetString("prefix")
+		generated, _ := flags.GetBool("generated")
+		limit, _ := flags.GetInt("limit")
+
+		var rts []*route
+		if err := env.client.list(ctx, prefix, generated, func(rt *route) error {
+			rts = append(rts, rt)
+			if len(rts) == limit {
+				return errStop
+			}
+			return nil
+		}); err != nil && !errors.Is(err, errStop) {
+			return err
+		}
+
+		return env.writeRoutes(rts)
+	},
+}
+
+var cmdHistory = &command{
+	usage: "history <name>",
+	run: func(ctx context.Context, env *env, flags *pflag.FlagSet, args []string) error {
+		if err := needArgs(args, 1, "the name of a link"); err != nil {
+			return err
+		}
+
+		revs, err := env.client.history(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		return env.writeRevisions(revs)
+	},
+}
+
+var cmdImport = &command{
+	usage: "import [--conflict=fail|skip|overwrite] <file>",
+	flags: func(flags *pflag.FlagSet) {
+		flags.String("format", "", "The format of the file: jsonl, csv or yaml. Defaults to the extension of the file.")
+		flags.String("conflict", string(internal.ConflictFail), "What to do with links that already exist: 'fail', which imports nothing, 'skip' or 'overwrite'")
+	},
+	run: func(ctx context.Context, env *env, flags *pflag.FlagSet, args []string) error {
+		if err := needArgs(args, 1, "a file to import, or - for stdin"); err != nil {
+			return err
+		}
+
+		name, _ := flags.GetString("format")
+		f, err := formatFor(name, args[0])
+		if err != nil {
+			return err
+		}
+
+		conflict, _ := flags.GetString("conflict")
+		policy := internal.ConflictPolicy(conflict)
+		if !policy.Valid() {
+			return fmt.Errorf("invalid --conflict value: %s", conflict)
+		}
+
+		var r io.Reader = os.Stdin
+		if args[0] != "-" {
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			r = file
+		}
+
+		res, err := env.client.importDump(ctx, r, string(f), f.ContentType(), policy)
+		if err != nil {
+			if res != nil && len(res.Skipped) > 0 {
+				return fmt.Errorf("%w: %s", err, strings.Join(res.Skipped, ", "))
+			}
+			return err
+		}
+
+		return env.writeImport(res)
+	},
+}
+
+var cmdExport = &command{
+	usage: "export [--out=<file>]",
+	flags: func(flags *pflag.FlagSet) {
+		flags.String("out", "-", "The file to write, or - for stdout")
+		flags.String("format", "", "The format to write: jsonl, csv or yaml. Defaults to the extension of --out.")
+	},
+	run: func(ctx context.Context, env *env, flags *pflag.FlagSet, args []string) error {
+		if err := needArgs(args, 0, "no arguments"); err != nil {
+			return err
+		}
+
+		out, _ := flags.GetString("out")
+		name, _ := flags.GetString("format")
+		f, err := formatFor(name, out)
+		if err != nil {
+			return err
+		}
+
+		w := env.out
+		if out != "-" {
+			file, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+
+		dw, err := dump.NewWriter(w, f)
+		if err != nil {
+			return err
+		}
+
+		if err := env.client.list(ctx, "", true, func(rt *route) error {
+			return dw.Write(&dump.Entry{Name: rt.Name, Route: rt.Route})
+		}); err != nil {
+			return err
+		}
+
+		return dw.Close()
+	},
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend/memory"
+	"github.com/kellegous/go/internal/web"
+)
+
+func newServer(t *testing.T) (*httptest.Server, *memory.Backend) {
+	t.Helper()
+
+	// keep any config file of the user running the tests out of them.
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	backend := memory.New()
+
+	mux := http.NewServeMux()
+	web.Setup(mux, backend, "")
+
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s, backend
+}
+
+// Run goctl with the arguments, failing the test if it fails, and return
+// what it printed.
+func mustRun(t *testing.T, args ...string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := run(context.Background(), args, &buf); err != nil {
+		t.Fatalf("goctl %s: %s", strings.Join(args, " "), err)
+	}
+	return buf.String()
+}
+
+func TestCommands(t *testing.T) {
+	s, backend := newServer(t)
+
+	var rt route
+	out := mustRun(t, "set", "--server", s.URL, "-o", "json", "--tags=a,b", "--description=docs", "docs", "http://docs.example.com/")
+	if err := json.Unmarshal([]byte(out), &rt); err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.Name != "docs" || rt.URL != "http://docs.example.com/" || rt.Description != "docs" || !reflect.DeepEqual(rt.Tags, []string{"a", "b"}) {
+		t.Fatalf("unexpected route: %+v", rt)
+	}
+
+	// fields that aren't given are left alone.
+	mustRun(t, "set", "--server", s.URL, "docs", "http://docs.example.com/v2")
+	mustRun(t, "set", "--server", s.URL, "dogs", "http://dogs.example.com/")
+	mustRun(t, "set", "--server", s.URL, "cats", "http://cats.example.com/")
+
+	if out := mustRun(t, "get", "--server", s.URL, "docs"); !strings.Contains(out, "http://docs.example.com/v2") || !strings.Contains(out, "a, b") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+
+	if err := run(context.Background(), []string{"set", "--server", s.URL, "--create", "docs", "http://x/"}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected --create to fail for an existing link")
+	}
+
+	var rts []*route
+	if err := json.Unmarshal([]byte(mustRun(t, "ls", "--server", s.URL, "-o", "json", "--prefix", "do")), &rts); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rts) != 2 || rts[0].Name != "docs" || rts[1].Name != "dogs" {
+		t.Fatalf("expected docs and dogs, got %v", rts)
+	}
+
+	if out := mustRun(t, "ls", "--server", s.URL, "--limit", "1"); out != "NAME  URL                       OWNER  VISITS\ncats  http://cats.example.com/         0\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	var revs []*internal.Revision
+	if err := json.Unmarshal([]byte(mustRun(t, "history", "--server", s.URL, "-o", "json", "docs")), &revs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revs) != 2 || revs[1].Route.URL != "http://docs.example.com/v2" {
+		t.Fatalf("unexpected revisions: %v", revs)
+	}
+
+	filename := filepath.Join(t.TempDir(), "links.csv")
+	mustRun(t, "export", "--server", s.URL, "--out", filename)
+
+	mustRun(t, "rm", "--server", s.URL, "docs", "dogs")
+	if _, err := backend.Get(context.Background(), "dogs"); err == nil {
+		t.Fatal("expected dogs to be deleted")
+	}
+
+	// cats still exists, so nothing is imported unless conflicts are skipped.
+	if err := run(context.Background(), []string{"import", "--server", s.URL, filename}, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "cats") {
+		t.Fatalf("expected cats to conflict, got %v", err)
+	}
+
+	if out := mustRun(t, "import", "--server", s.URL, "--conflict=skip", filename); out != "created 2, updated 0, skipped 1\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	rt2, err := backend.Get(context.Background(), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rt2.Description != "docs" || !reflect.DeepEqual(rt2.Tags, []string{"a", "b"}) {
+		t.Fatalf("unexpected route: %+v", rt2)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	s, _ := newServer(t)
+
+	authed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer authed.Close()
+
+	if err := run(context.Background(), []string{"ls"}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected an error without a server")
+	}
+
+	config := filepath.Join(t.TempDir(), "goctl.yaml")
+	if err := os.WriteFile(config, []byte("server: "+authed.URL+"\ntoken: secret\noutput: json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if out := mustRun(t, "ls", "--config", config); out != "[]\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	// flags take precedence over the environment, which takes precedence
+	// over the config file.
+	t.Setenv("GOCTL_CONFIG", config)
+	t.Setenv("GOCTL_TOKEN", "wrong")
+	if err := run(context.Background(), []string{"ls"}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected the token from the environment to be used")
+	}
+
+	if out := mustRun(t, "ls", "--token", "secret", "-o", "table"); out != "NAME  URL  OWNER  VISITS\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	if err := run(context.Background(), []string{"ls", "--config", filepath.Join(t.TempDir(), "missing.yaml")}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected an error for a missing config file")
+	}
+}
@@ -0,0 +1,180 @@
+// goctl manages the links of a go server through its API.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// command is one of the subcommands of goctl.
+type command struct {
+	usage string
+
+	// add the flags of the subcommand.
+	flags func(flags *pflag.FlagSet)
+
+	// run the subcommand with the arguments left after the flags, writing
+	// its output to env.out.
+	run func(ctx context.Context, env *env, flags *pflag.FlagSet, args []string) error
+}
+
+var commands = map[string]*command{
+	"get":     cmdGet,
+	"set":     cmdSet,
+	"rm":      cmdRm,
+	"ls":      cmdLs,
+	"history": cmdHistory,
+	"import":  cmdImport,
+	"export":  cmdExport,
+}
+
+// env is what every subcommand runs with: the server to talk to and how to
+// write what it finds.
+type env struct {
+	client *client
+	output string
+	out    io.Writer
+}
+
+// The config file read when --config isn't given, if it exists.
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "goctl", "config.yaml")
+}
+
+// Add the flags that every subcommand takes.
+func addCommonFlags(flags *pflag.FlagSet) {
+	flags.String("config", defaultConfigFile(), "The config file from which to read the settings below, e.g. server: https://go.example.com")
+	flags.String("server", "", "The URL of the go server")
+	flags.String("token", "", "The API token with which to authenticate, sent as Authorization: Bearer")
+	flags.StringP("output", "o", "table", "How to print results: 'table' or 'json'")
+	flags.Duration("timeout", 30*time.Second, "How long to wait for each request")
+}
+
+// Read the settings from the flags, then the environment, where they are
+// prefixed with GOCTL_, and then the config file.
+func loadConfig(flags *pflag.FlagSet) (*viper.Viper, error) {
+	v := viper.New()
+	if err := v.BindPFlags(flags); err != nil {
+		return nil, err
+	}
+
+	v.SetEnvPrefix("goctl")
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+
+	if file := v.GetString("config"); file != "" {
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); errors.Is(err, fs.ErrNotExist) && !v.IsSet("config") {
+			// the default config file is optional.
+		} else if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", file, err)
+		}
+	}
+
+	return v, nil
+}
+
+// Create the environment for a subcommand from its parsed flags.
+func newEnv(flags *pflag.FlagSet, out io.Writer) (*env, error) {
+	v, err := loadConfig(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	server := strings.TrimRight(v.GetString("server"), "/")
+	if server == "" {
+		return nil, errors.New("no server configured: use --server, GOCTL_SERVER or server in the config file")
+	}
+
+	output := v.GetString("output")
+	if output != "table" && output != "json" {
+		return nil, fmt.Errorf("invalid --output value: %s", output)
+	}
+
+	return &env{
+		client: &client{
+			http:   &http.Client{Timeout: v.GetDuration("timeout")},
+			server: server,
+			token:  v.GetString("token"),
+		},
+		output: output,
+		out:    out,
+	}, nil
+}
+
+// Write how to use goctl to w.
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "usage: goctl <command> [flags] [args]")
+	fmt.Fprintln(w)
+	for _, name := range names {
+		fmt.Fprintf(w, "  goctl %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run goctl <command> --help for the flags of each command.")
+}
+
+// Run the subcommand named by the first argument.
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return errors.New("no command given")
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(os.Stderr)
+		return fmt.Errorf("unknown command %s", args[0])
+	}
+
+	flags := pflag.NewFlagSet(args[0], pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: goctl %s\n", cmd.usage)
+		flags.PrintDefaults()
+	}
+	addCommonFlags(flags)
+	if cmd.flags != nil {
+		cmd.flags(flags)
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	env, err := newEnv(flags, out)
+	if err != nil {
+		return err
+	}
+
+	return cmd.run(ctx, env, flags, flags.Args())
+}
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); errors.Is(err, pflag.ErrHelp) {
+		return
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "goctl: %s\n", err)
+		os.Exit(1)
+	}
+}
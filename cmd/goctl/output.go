@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Write v as indented JSON.
+func (e *env) writeJSON(v any) error {
+	enc := json.NewEncoder(e.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Write rows as a table with aligned columns. The first row is the header.
+func (e *env) writeTable(rows [][]string) error {
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// Format a time for a table, leaving it blank if it's unknown.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+// Write every field of a route.
+func (e *env) writeRoute(rt *route) error {
+	if e.output == "json" {
+		return e.writeJSON(rt)
+	}
+
+	rows := [][]string{
+		{"name:", rt.Name},
+		{"url:", rt.URL},
+		{"description:", rt.Description},
+		{"tags:", strings.Join(rt.Tags, ", ")},
+		{"owner:", rt.Owner},
+		{"co-owners:", strings.Join(rt.CoOwners, ", ")},
+		{"passthrough:", string(rt.Passthrough)},
+		{"created:", formatTime(rt.Created)},
+		{"updated:", formatTime(rt.Time)},
+		{"modified by:", rt.ModifiedBy},
+		{"revision:", fmt.Sprint(rt.Revision)},
+	}
+
+	if rt.Hits != nil {
+		rows = append(rows,
+			[]string{"visits:", fmt.Sprint(rt.Hits.Count)},
+			[]string{"last visit:", formatTime(rt.Hits.Last)})
+	}
+
+	return e.writeTable(rows)
+}
+
+// Write a list of routes, one per row.
+func (e *env) writeRoutes(rts []*route) error {
+	if e.output == "json" {
+		if rts == nil {
+			rts = []*route{}
+		}
+		return e.writeJSON(rts)
+	}
+
+	rows := [][]string{{"NAME", "URL", "OWNER", "VISITS"}}
+	for _, rt := range rts {
+		var visits int64
+		if rt.Hits != nil {
+			visits = rt.Hits.Count
+		}
+		rows = append(rows, []string{rt.Name, rt.URL, rt.Owner, fmt.Sprint(visits)})
+	}
+
+	return e.writeTable(rows)
+}
+
+// Write the revisions of a route, oldest first.
+func (e *env) writeRevisions(revs []*internal.Revision) error {
+	if e.output == "json" {
+		if revs == nil {
+			revs = []*internal.Revision{}
+		}
+		return e.writeJSON(revs)
+	}
+
+	rows := [][]string{{"REVISION", "TIME", "BY", "URL"}}
+	for _, rev := range revs {
+		u := "(deleted)"
+		if rev.Route != nil {
+			u = rev.Route.URL
+		}
+		rows = append(rows, []string{fmt.Sprint(rev.ID), formatTime(rev.Time), rev.By, u})
+	}
+
+	return e.writeTable(rows)
+}
+
+// Write what an import did.
+func (e *env) writeImport(res *importResult) error {
+	if e.output == "json" {
+		return e.writeJSON(res)
+	}
+
+	_, err := fmt.Fprintf(e.out, "created %d, updated %d, skipped %d\n",
+		len(res.Created), len(res.Updated), len(res.Skipped))
+	return err
+}
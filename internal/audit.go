@@ -0,0 +1,55 @@
+package internal
+
+import "time"
+
+// The actions recorded in the audit log.
+const (
+	AuditCreate      = "create"
+	AuditUpdate      = "update"
+	AuditDelete      = "delete"
+	AuditRevert      = "revert"
+	AuditTransfer    = "transfer"
+	AuditRestore     = "restore"
+	AuditPurge       = "purge"
+	AuditDump        = "dump"
+	AuditImport      = "import"
+	AuditTokenCreate = "token.create"
+	AuditTokenRevoke = "token.revoke"
+)
+
+// AuditEvent records a change made to the store, or access to all of it.
+type AuditEvent struct {
+	// ID is assigned by the backend when the event is appended.
+	ID string `json:"id"`
+
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor,omitempty"`
+	Action string    `json:"action"`
+
+	// Name is the route the action applies to. For token actions, it is the
+	// token's ID.
+	Name string `json:"name,omitempty"`
+
+	// Detail is a human readable description of the change, such as the new
+	// URL of a route.
+	Detail string `json:"detail,omitempty"`
+}
+
+// AuditFilter selects events from the audit log. Empty fields match all
+// events.
+type AuditFilter struct {
+	Actor string
+	Name  string
+	Since time.Time
+
+	// Limit is the maximum number of events to return, or 0 for all.
+	Limit int
+}
+
+// Matches indicates whether the event is selected by the filter, ignoring
+// the limit.
+func (f *AuditFilter) Matches(e *AuditEvent) bool {
+	return (f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Name == "" || e.Name == f.Name) &&
+		!e.Time.Before(f.Since)
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/kellegous/go/internal"
+)
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// Scopes limit what callers using API tokens may do. Each scope includes the
+// ones before it.
+const (
+	// ScopeRead allows reading.
+	ScopeRead = "read"
+
+	// ScopeWriteOwn allows changing routes the caller owns.
+	ScopeWriteOwn = "write:own"
+
+	// ScopeWriteAll allows changing any route, if the caller is an admin.
+	ScopeWriteAll = "write:all"
+
+	// ScopeAdmin allows everything an admin may do.
+	ScopeAdmin = "admin"
+)
+
+var scopeLevels = map[string]int{
+	ScopeRead:     1,
+	ScopeWriteOwn: 2,
+	ScopeWriteAll: 3,
+	ScopeAdmin:    4,
+}
+
+// ValidScope indicates whether s is a known scope.
+func ValidScope(s string) bool {
+	_, ok := scopeLevels[s]
+	return ok
+}
+
+// Identity describes an authenticated caller.
+type Identity struct {
+	// Name is the stable name of the caller, which is used to attribute changes.
+	Name   string   `json:"name"`
+	Email  string   `json:"email,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+
+	// Admin indicates that the caller is an administrator, who may change any
+	// route unless limited by Scopes.
+	Admin bool `json:"admin,omitempty"`
+
+	// Scopes limit what the caller may do. Callers who are not using an API
+	// token have nil Scopes and are unrestricted.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// Allows indicates whether the caller's scopes permit the given scope.
+func (id *Identity) Allows(scope string) bool {
+	if id.Scopes == nil {
+		return true
+	}
+
+	for _, s := range id.Scopes {
+		if scopeLevels[s] >= scopeLevels[scope] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Is indicates whether the principal, which is a name, an email address or
+// a group, refers to this identity.
+func (id *Identity) Is(principal string) bool {
+	if principal == "" {
+		return false
+	}
+
+	if principal == id.Name || (id.Email != "" && strings.EqualFold(principal, id.Email)) {
+		return true
+	}
+
+	for _, g := range id.Groups {
+		if principal == g {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Provider determines the identity of the caller of a request.
+type Provider interface {
+	// Authenticate returns the identity of the caller. It returns a nil Identity
+	// if the request carries no credentials that this provider accepts and an
+	// error only if it failed to check the credentials.
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+type identityKey struct{}
+
+// NewContext returns a context carrying the given identity. Changes made with
+// the context are also attributed to the identity.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return internal.WithActor(context.WithValue(ctx, identityKey{}, id), id.Name)
+}
+
+// FromContext returns the identity carried by the context, or nil if the
+// caller was not authenticated.
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// BearerToken extracts the bearer token from the Authorization header of the
+// request.
+func BearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(h[7:])
+}
+
+// Authenticator identifies the callers of requests by consulting each of its
+// providers in order. A nil Authenticator has no providers.
+type Authenticator struct {
+	providers []Provider
+
+	// Admins are the principals whose identities are administrators.
+	Admins []string
+}
+
+// New creates an Authenticator that uses the given providers.
+func New(providers ...Provider) *Authenticator {
+	return &Authenticator{
+		providers: providers,
+	}
+}
+
+// With returns a copy of this Authenticator that consults the given
+// providers before its own.
+func (a *Authenticator) With(providers ...Provider) *Authenticator {
+	c := &Authenticator{}
+	if a != nil {
+		c.Admins = a.Admins
+		providers = append(providers, a.providers...)
+	}
+	c.providers = providers
+	return c
+}
+
+// Enabled indicates whether any providers are configured. When none are,
+// every caller is anonymous and no request requires authentication.
+func (a *Authenticator) Enabled() bool {
+	return a != nil && len(a.providers) > 0
+}
+
+// Authenticate returns the identity of the caller of the request, or nil if
+// the caller is anonymous. A request that presents an Authorization header
+// that no provider accepts is an error.
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+	if !a.Enabled() {
+		return nil, nil
+	}
+
+	for _, p := range a.providers {
+		id, err := p.Authenticate(r)
+		if err != nil {
+			return nil, err
+		} else if id != nil {
+			return a.withAdmin(id), nil
+		}
+	}
+
+	if r.Header.Get("Authorization") != "" {
+		return nil, errInvalidCredentials
+	}
+
+	return nil, nil
+}
+
+// Return a copy of the identity that is marked as an administrator if it is
+// one of the configured admins.
+func (a *Authenticator) withAdmin(id *Identity) *Identity {
+	c := *id
+	for _, p := range a.Admins {
+		if c.Is(p) {
+			c.Admin = true
+		}
+	}
+	return &c
+}
+
+func writeUnauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	w.WriteHeader(http.StatusUnauthorized)
+	if err := json.NewEncoder(w).Encode(struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}{false, msg}); err != nil {
+		log.Panic(err)
+	}
+}
+
+// Handler wraps h so that the identity of each caller is attached to the
+// request's context. Requests for which required returns true are refused
+// unless the caller is authenticated.
+func (a *Authenticator) Handler(
+	h http.Handler,
+	required func(r *http.Request) bool,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := a.Authenticate(r)
+		if errors.Is(err, errInvalidCredentials) {
+			writeUnauthorized(w, err.Error())
+			return
+		} else if err != nil {
+			log.Printf("[error] authenticating: %s", err)
+			http.Error(w, "authentication error", http.StatusInternalServerError)
+			return
+		}
+
+		if id == nil {
+			if a.Enabled() && required(r) {
+				writeUnauthorized(w, "authentication required")
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		h.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
+
+// ServeHTTP handles the requests needed by providers that sign users in
+// through the browser, such as OIDC.
+func (a *Authenticator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a != nil {
+		for _, p := range a.providers {
+			if h, ok := p.(http.Handler); ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+	}
+	http.NotFound(w, r)
+}
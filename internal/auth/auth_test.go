@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kellegous/go/internal"
+)
+
+func needHeader(t *testing.T, vals ...string) *Header {
+	nets, err := ParseNetworks(vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Header{
+		UserHeader:   "X-Forwarded-User",
+		EmailHeader:  "X-Forwarded-Email",
+		GroupsHeader: "X-Forwarded-Groups",
+		Trusted:      nets,
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	for _, v := range []string{"10.0.0.0/8", "127.0.0.1", "::1", "fd00::/8"} {
+		if _, err := ParseNetworks([]string{v}); err != nil {
+			t.Fatalf("ParseNetworks(%q): %s", v, err)
+		}
+	}
+
+	for _, v := range []string{"", "10.0.0.0/33", "localhost"} {
+		if _, err := ParseNetworks([]string{v}); err == nil {
+			t.Fatalf("ParseNetworks(%q) should have failed", v)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	p := needHeader(t, "10.0.0.0/8", "::1")
+
+	tests := []struct {
+		remote string
+		user   string
+		email  string
+		groups string
+		id     *Identity
+	}{
+		{"10.1.2.3:5555", "alice", "alice@example.com", "eng, ops,", &Identity{
+			Name:   "alice",
+			Email:  "alice@example.com",
+			Groups: []string{"eng", "ops"},
+		}},
+		{"[::1]:5555", "", "bob@example.com", "", &Identity{
+			Name:  "bob@example.com",
+			Email: "bob@example.com",
+		}},
+		{"192.168.1.1:5555", "alice", "", "", nil},
+		{"10.1.2.3:5555", "", "", "eng", nil},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remote
+		r.Header.Set("X-Forwarded-User", test.user)
+		r.Header.Set("X-Forwarded-Email", test.email)
+		r.Header.Set("X-Forwarded-Groups", test.groups)
+
+		id, err := p.Authenticate(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(id, test.id) {
+			t.Fatalf("from %s expected %v, got %v", test.remote, test.id, id)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "tokens.json")
+	if err := os.WriteFile(
+		filename,
+		[]byte(`{"s3cret": {"name": "ci", "groups": ["robots"]}}`),
+		0600,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadTokens(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		header string
+		id     *Identity
+	}{
+		{"Bearer s3cret", &Identity{Name: "ci", Groups: []string{"robots"}}},
+		{"bearer s3cret", &Identity{Name: "ci", Groups: []string{"robots"}}},
+		{"Bearer nope", nil},
+		{"Basic s3cret", nil},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", test.header)
+
+		id, err := p.Authenticate(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(id, test.id) {
+			t.Fatalf("for %q expected %v, got %v", test.header, test.id, id)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	a := New(NewTokens(map[string]*Identity{
+		"s3cret": {Name: "ci"},
+	}))
+
+	h := a.Handler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var name string
+			if id := FromContext(r.Context()); id != nil {
+				name = id.Name
+			}
+			w.Header().Set("X-Identity", name)
+			w.Header().Set("X-Actor", internal.ActorFrom(r.Context()))
+		}),
+		func(r *http.Request) bool {
+			return r.Method == "POST"
+		})
+
+	tests := []struct {
+		method string
+		token  string
+		status int
+		name   string
+	}{
+		{"GET", "", http.StatusOK, ""},
+		{"GET", "s3cret", http.StatusOK, "ci"},
+		{"POST", "s3cret", http.StatusOK, "ci"},
+		{"POST", "", http.StatusUnauthorized, ""},
+		{"GET", "nope", http.StatusUnauthorized, ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/", nil)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Fatalf("%s with %q: expected status %d, got %d",
+				test.method, test.token, test.status, w.Code)
+		}
+
+		if w.Code != http.StatusOK {
+			continue
+		}
+
+		if name := w.Header().Get("X-Identity"); name != test.name {
+			t.Fatalf("expected identity %q, got %q", test.name, name)
+		}
+
+		if actor := w.Header().Get("X-Actor"); actor != test.name {
+			t.Fatalf("expected actor %q, got %q", test.name, actor)
+		}
+	}
+}
+
+func TestHandlerDisabled(t *testing.T) {
+	var a *Authenticator
+
+	h := a.Handler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		func(r *http.Request) bool {
+			return true
+		})
+
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("Authorization", "Bearer whatever")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestIdentityIs(t *testing.T) {
+	id := &Identity{
+		Name:   "alice",
+		Email:  "alice@example.com",
+		Groups: []string{"eng"},
+	}
+
+	for _, p := range []string{"alice", "Alice@Example.com", "eng"} {
+		if !id.Is(p) {
+			t.Fatalf("expected %v to be %q", id, p)
+		}
+	}
+
+	for _, p := range []string{"", "bob", "ops", "Alice"} {
+		if id.Is(p) {
+			t.Fatalf("expected %v not to be %q", id, p)
+		}
+	}
+}
+
+func TestAdmins(t *testing.T) {
+	ci := &Identity{Name: "ci"}
+	a := New(NewTokens(map[string]*Identity{
+		"s3cret": ci,
+		"other":  {Name: "bob", Groups: []string{"sre"}},
+	}))
+	a.Admins = []string{"sre"}
+
+	tests := map[string]bool{
+		"s3cret": false,
+		"other":  true,
+	}
+
+	for token, admin := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		id, err := a.Authenticate(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id.Admin != admin {
+			t.Fatalf("for %s expected admin to be %t", id.Name, admin)
+		}
+	}
+
+	// the provider's identities are never modified.
+	if ci.Admin {
+		t.Fatal("provider identity was modified")
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		scopes  []string
+		allowed []string
+		denied  []string
+	}{
+		{nil, []string{ScopeRead, ScopeWriteOwn, ScopeWriteAll, ScopeAdmin}, nil},
+		{[]string{}, nil, []string{ScopeRead, ScopeWriteOwn}},
+		{[]string{ScopeRead}, []string{ScopeRead}, []string{ScopeWriteOwn, ScopeAdmin}},
+		{[]string{ScopeRead, ScopeWriteAll}, []string{ScopeWriteOwn, ScopeWriteAll}, []string{ScopeAdmin}},
+		{[]string{ScopeAdmin}, []string{ScopeRead, ScopeWriteAll, ScopeAdmin}, nil},
+	}
+
+	for _, test := range tests {
+		id := &Identity{Name: "ci", Scopes: test.scopes}
+		for _, s := range test.allowed {
+			if !id.Allows(s) {
+				t.Fatalf("expected %v to allow %s", test.scopes, s)
+			}
+		}
+
+		for _, s := range test.denied {
+			if id.Allows(s) {
+				t.Fatalf("expected %v not to allow %s", test.scopes, s)
+			}
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	a := New(NewTokens(map[string]*Identity{
+		"s3cret": {Name: "first"},
+	}))
+	a.Admins = []string{"second"}
+
+	b := a.With(NewTokens(map[string]*Identity{
+		"s3cret": {Name: "second"},
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer s3cret")
+
+	id, err := b.Authenticate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id.Name != "second" || !id.Admin {
+		t.Fatalf("expected the added provider to be consulted first, got %v", id)
+	}
+
+	if !(*Authenticator)(nil).With(NewTokens(nil)).Enabled() {
+		t.Fatal("expected the added provider to enable authentication")
+	}
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Header identifies callers by the headers set by an authenticating proxy,
+// such as X-Forwarded-User and X-Forwarded-Email. Since anyone can set these
+// headers, they are only believed when the request comes directly from one of
+// the trusted proxies.
+type Header struct {
+	UserHeader   string
+	EmailHeader  string
+	GroupsHeader string
+	Trusted      []*net.IPNet
+}
+
+// ParseNetworks parses a list of CIDR networks or bare IP addresses.
+func ParseNetworks(vals []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, v := range vals {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: v}
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Indicates whether the request came directly from a trusted proxy.
+func (p *Header) trusts(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range p.Trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate returns the identity asserted by the proxy's headers.
+func (p *Header) Authenticate(r *http.Request) (*Identity, error) {
+	var user, email string
+	if p.UserHeader != "" {
+		user = strings.TrimSpace(r.Header.Get(p.UserHeader))
+	}
+
+	if p.EmailHeader != "" {
+		email = strings.TrimSpace(r.Header.Get(p.EmailHeader))
+	}
+
+	if (user == "" && email == "") || !p.trusts(r) {
+		return nil, nil
+	}
+
+	id := &Identity{
+		Name:  user,
+		Email: email,
+	}
+
+	if id.Name == "" {
+		id.Name = email
+	}
+
+	if p.GroupsHeader != "" {
+		for _, g := range strings.Split(r.Header.Get(p.GroupsHeader), ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				id.Groups = append(id.Groups, g)
+			}
+		}
+	}
+
+	return id, nil
+}
@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// The cookie that holds the ID token of a user who signed in through the
+	// browser.
+	sessionCookie = "go-session"
+
+	// The cookie that holds the state and nonce of a sign in that is in
+	// progress, along with where to go once it's done.
+	stateCookie = "go-oidc-state"
+)
+
+// OIDC identifies callers by ID tokens issued by an OpenID Connect provider.
+// Tokens are accepted either as bearer tokens or from the session cookie set
+// when a user signs in through /auth/login.
+type OIDC struct {
+	verifier *oidc.IDTokenVerifier
+	config   *oauth2.Config
+}
+
+// NewOIDC discovers the configuration of the given issuer and creates a
+// provider that accepts ID tokens it issued for clientID.
+func NewOIDC(
+	ctx context.Context,
+	issuer string,
+	clientID string,
+	clientSecret string,
+	redirectURL string,
+) (*OIDC, error) {
+	p, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDC{
+		verifier: p.Verifier(&oidc.Config{ClientID: clientID}),
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     p.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+	}, nil
+}
+
+// Verify the raw ID token and return the identity it asserts.
+func (p *OIDC) identify(ctx context.Context, raw string) (*Identity, error) {
+	tok, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		Username string   `json:"preferred_username"`
+		Email    string   `json:"email"`
+		Groups   []string `json:"groups"`
+	}
+	if err := tok.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	id := &Identity{
+		Name:   claims.Username,
+		Email:  claims.Email,
+		Groups: claims.Groups,
+	}
+
+	if id.Name == "" {
+		id.Name = claims.Email
+	}
+
+	if id.Name == "" {
+		id.Name = tok.Subject
+	}
+
+	return id, nil
+}
+
+// Authenticate returns the identity asserted by the request's bearer token
+// or session cookie.
+func (p *OIDC) Authenticate(r *http.Request) (*Identity, error) {
+	if raw := BearerToken(r); raw != "" {
+		// a bearer token that isn't a valid ID token may still be accepted by
+		// another provider.
+		id, err := p.identify(r.Context(), raw)
+		if err != nil {
+			return nil, nil
+		}
+		return id, nil
+	}
+
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, nil
+	}
+
+	// an expired or otherwise invalid session simply leaves the user signed
+	// out.
+	id, err := p.identify(r.Context(), c.Value)
+	if err != nil {
+		return nil, nil
+	}
+	return id, nil
+}
+
+// Generate a random value for the state or nonce of a sign in.
+func randomValue() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
+
+// Only allow redirects back to paths on this server after signing in.
+func safeRedirect(s string) string {
+	// browsers treat a backslash like a slash, so /\ starts a host just as //
+	// does.
+	if !strings.HasPrefix(s, "/") || strings.HasPrefix(s, "//") || strings.HasPrefix(s, "/\\") {
+		return "/"
+	}
+
+	// browsers also ignore tabs and newlines, which can hide either prefix.
+	// Parsing rejects those along with the other control characters.
+	if u, err := url.Parse(s); err != nil || u.Scheme != "" || u.Host != "" {
+		return "/"
+	}
+
+	return s
+}
+
+func (p *OIDC) serveLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := randomValue()
+	if err != nil {
+		log.Printf("[error] generating oidc state: %s", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// the nonce ties the ID token to this sign in, so that a token issued for
+	// another can't be replayed through the callback.
+	nonce, err := randomValue()
+	if err != nil {
+		log.Printf("[error] generating oidc nonce: %s", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state + ":" + nonce + ":" + url.QueryEscape(safeRedirect(r.FormValue("next"))),
+		Path:     "/auth/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, p.config.AuthCodeURL(state, oidc.Nonce(nonce)), http.StatusFound)
+}
+
+func (p *OIDC) serveCallback(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(stateCookie)
+	if err != nil {
+		http.Error(w, "missing sign in state", http.StatusBadRequest)
+		return
+	}
+
+	state, rest, _ := strings.Cut(c.Value, ":")
+	nonce, next, _ := strings.Cut(rest, ":")
+	if state == "" || nonce == "" || r.FormValue("state") != state {
+		http.Error(w, "invalid sign in state", http.StatusBadRequest)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   stateCookie,
+		Path:   "/auth/",
+		MaxAge: -1,
+	})
+
+	tok, err := p.config.Exchange(r.Context(), r.FormValue("code"))
+	if err != nil {
+		log.Printf("[error] exchanging oidc code: %s", err)
+		http.Error(w, "sign in failed", http.StatusUnauthorized)
+		return
+	}
+
+	raw, ok := tok.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "sign in failed", http.StatusUnauthorized)
+		return
+	}
+
+	idt, err := p.verifier.Verify(r.Context(), raw)
+	if err != nil || idt.Nonce != nonce {
+		http.Error(w, "sign in failed", http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    raw,
+		Path:     "/",
+		Expires:  idt.Expiry,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if next, err = url.QueryUnescape(next); err != nil {
+		next = "/"
+	}
+	http.Redirect(w, r, safeRedirect(next), http.StatusFound)
+}
+
+func (p *OIDC) serveLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:   sessionCookie,
+		Path:   "/",
+		MaxAge: -1,
+	})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// ServeHTTP handles signing in and out through the browser.
+func (p *OIDC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/auth/login":
+		p.serveLogin(w, r)
+	case "/auth/callback":
+		p.serveCallback(w, r)
+	case "/auth/logout":
+		p.serveLogout(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	testClientID = "go-links"
+	testKeyID    = "test"
+)
+
+// A minimal OpenID Connect issuer that signs whatever claims it is told to.
+type testIssuer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iss := &testIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                iss.URL,
+			"authorization_endpoint":                iss.URL + "/authorize",
+			"token_endpoint":                        iss.URL + "/token",
+			"jwks_uri":                              iss.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{
+				{
+					Key:       &key.PublicKey,
+					KeyID:     testKeyID,
+					Algorithm: string(jose.RS256),
+					Use:       "sig",
+				},
+			},
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "the-code" {
+			http.Error(w, "bad code", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     iss.sign(t, iss.claims),
+		})
+	})
+
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+// Sign the claims, filling in the standard ones that are missing.
+func (i *testIssuer) sign(t *testing.T, claims map[string]interface{}) string {
+	c := map[string]interface{}{
+		"iss": i.URL,
+		"aud": testClientID,
+		"sub": "1234",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: i.key},
+		(&jose.SignerOptions{}).WithHeader("kid", testKeyID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := obj.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func needOIDC(t *testing.T, iss *testIssuer) *OIDC {
+	p, err := NewOIDC(
+		context.Background(),
+		iss.URL,
+		testClientID,
+		"secret",
+		"http://go/auth/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestOIDCBearer(t *testing.T) {
+	iss := newTestIssuer(t)
+	p := needOIDC(t, iss)
+
+	tests := []struct {
+		token string
+		name  string
+	}{
+		{iss.sign(t, map[string]interface{}{
+			"preferred_username": "alice",
+			"email":              "alice@example.com",
+			"groups":             []string{"eng"},
+		}), "alice"},
+		{iss.sign(t, map[string]interface{}{
+			"email": "bob@example.com",
+		}), "bob@example.com"},
+		{iss.sign(t, nil), "1234"},
+		{iss.sign(t, map[string]interface{}{
+			"preferred_username": "mallory",
+			"aud":                "someone-else",
+		}), ""},
+		{iss.sign(t, map[string]interface{}{
+			"preferred_username": "mallory",
+			"exp":                time.Now().Add(-time.Hour).Unix(),
+		}), ""},
+		{"not-a-jwt", ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+test.token)
+
+		id, err := p.Authenticate(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var name string
+		if id != nil {
+			name = id.Name
+		}
+
+		if name != test.name {
+			t.Fatalf("expected identity %q, got %q", test.name, name)
+		}
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.claims = map[string]interface{}{
+		"preferred_username": "alice",
+	}
+	p := needOIDC(t, iss)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/auth/login?next=/edit/foo", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected status %d, got %d", http.StatusFound, w.Code)
+	}
+
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loc.Path != "/authorize" || loc.Query().Get("client_id") != testClientID {
+		t.Fatalf("unexpected authorization redirect: %s", loc)
+	}
+
+	state := loc.Query().Get("state")
+	nonce := loc.Query().Get("nonce")
+	if nonce == "" {
+		t.Fatal("no nonce was sent")
+	}
+	cookies := w.Result().Cookies()
+
+	// a callback with the wrong state must be refused.
+	r := httptest.NewRequest("GET", "/auth/callback?code=the-code&state=wrong", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// as must an ID token that was issued for another sign in.
+	iss.claims["nonce"] = "wrong"
+	r = httptest.NewRequest("GET", "/auth/callback?code=the-code&state="+url.QueryEscape(state), nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	iss.claims["nonce"] = nonce
+	r = httptest.NewRequest("GET", "/auth/callback?code=the-code&state="+url.QueryEscape(state), nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusFound, w.Code, w.Body.String())
+	}
+
+	if loc := w.Header().Get("Location"); loc != "/edit/foo" {
+		t.Fatalf("expected redirect to /edit/foo, got %s", loc)
+	}
+
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie {
+			session = c
+		}
+	}
+	if session == nil {
+		t.Fatal("no session cookie was set")
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(session)
+	id, err := p.Authenticate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id == nil || id.Name != "alice" {
+		t.Fatalf("expected identity alice, got %v", id)
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	tests := map[string]string{
+		"/edit/foo":          "/edit/foo",
+		"":                   "/",
+		"//evil.example.com": "/",
+		"https://evil.com/":  "/",
+		"/\\evil.com":        "/",
+		"/\t/evil.com":       "/",
+		"/edit/a\\b":         "/edit/a\\b",
+	}
+
+	for in, out := range tests {
+		if got := safeRedirect(in); got != out {
+			t.Fatalf("safeRedirect(%q): expected %q, got %q", in, out, got)
+		}
+	}
+}
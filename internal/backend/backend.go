@@ -2,6 +2,7 @@ package backend
 
 import (
 	"context"
+	"time"
 
 	"github.com/kellegous/go/internal"
 )
@@ -10,8 +11,30 @@ type Backend interface {
 	Close() error
 	Get(ctx context.Context, id string) (*internal.Route, error)
 	Put(ctx context.Context, key string, route *internal.Route) error
+	PutIf(ctx context.Context, key string, route *internal.Route, rev int64) error
+	Create(ctx context.Context, key string, route *internal.Route) error
+	PutBatch(ctx context.Context, routes []*internal.NamedRoute, policy internal.ConflictPolicy) (*internal.BatchResult, error)
 	Del(ctx context.Context, id string) error
 	GetAll(ctx context.Context) (map[string]internal.Route, error)
 	List(ctx context.Context, start string) (internal.RouteIterator, error)
+	Dump(ctx context.Context) (internal.RouteIterator, error)
 	NextID(ctx context.Context) (uint64, error)
+	LastID(ctx context.Context) (uint64, error)
+	AdvanceID(ctx context.Context, id uint64) error
+	History(ctx context.Context, name string) ([]*internal.Revision, error)
+	AddHits(ctx context.Context, hits map[string]*internal.Hits) error
+	GetHits(ctx context.Context, names []string) (map[string]*internal.Hits, error)
+	GetAllHits(ctx context.Context) (map[string]*internal.Hits, error)
+	AddClicks(ctx context.Context, clicks map[string][]*internal.Clicks) error
+	GetClicks(ctx context.Context, name string, from, to time.Time) ([]*internal.Clicks, error)
+	PutToken(ctx context.Context, token *internal.Token) error
+	GetToken(ctx context.Context, id string) (*internal.Token, error)
+	ListTokens(ctx context.Context, owner string) ([]*internal.Token, error)
+	DelToken(ctx context.Context, id string) error
+	AppendAudit(ctx context.Context, event *internal.AuditEvent) error
+	ListAudit(ctx context.Context, filter *internal.AuditFilter) ([]*internal.AuditEvent, error)
+	ListTrash(ctx context.Context) ([]*internal.TrashedRoute, error)
+	GetTrash(ctx context.Context, name string) (*internal.TrashedRoute, error)
+	Restore(ctx context.Context, name string) (*internal.Route, error)
+	PurgeTrash(ctx context.Context, before time.Time) ([]string, error)
 }
@@ -0,0 +1,904 @@
+// Package backendtest checks that implementations of backend.Backend behave
+// the same way, so that the web handlers can rely on any of them.
+package backendtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+)
+
+// The number of concurrent callers used by the concurrency tests.
+const concurrency = 10
+
+// Run runs the conformance tests against the backends returned by newBackend,
+// which must return a new, empty backend each time it is called. Backends are
+// closed when each test finishes.
+func Run(t *testing.T, newBackend func(t *testing.T) backend.Backend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, ctx context.Context, b backend.Backend)
+	}{
+		{"GetPut", testGetPut},
+		{"PutIf", testPutIf},
+		{"Create", testCreate},
+		{"Del", testDel},
+		{"PutBatch", testPutBatch},
+		{"GetAll", testGetAll},
+		{"List", testList},
+		{"Seek", testSeek},
+		{"Dump", testDump},
+		{"NextID", testNextID},
+		{"ConcurrentNextID", testConcurrentNextID},
+		{"AdvanceID", testAdvanceID},
+		{"ConcurrentPut", testConcurrentPut},
+		{"History", testHistory},
+		{"Hits", testHits},
+		{"Clicks", testClicks},
+		{"Tokens", testTokens},
+		{"Audit", testAudit},
+		{"Trash", testTrash},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newBackend(t)
+			defer b.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			test.fn(t, ctx, b)
+		})
+	}
+}
+
+// Some backends only keep times to the microsecond.
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func urlOf(name string) string {
+	return "http://" + name + "/"
+}
+
+func putRoutes(t *testing.T, ctx context.Context, b backend.Backend, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := b.Put(ctx, name, &internal.Route{
+			URL:  urlOf(name),
+			Time: now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func mustGet(t *testing.T, ctx context.Context, b backend.Backend, name string) *internal.Route {
+	t.Helper()
+	rt, err := b.Get(ctx, name)
+	if err != nil {
+		t.Fatalf("get %s: %s", name, err)
+	}
+	return rt
+}
+
+func mustNotExist(t *testing.T, ctx context.Context, b backend.Backend, name string) {
+	t.Helper()
+	if _, err := b.Get(ctx, name); !errors.Is(err, internal.ErrRouteNotFound) {
+		t.Fatalf("expected ErrRouteNotFound for %s, got %v", name, err)
+	}
+}
+
+// Read the names from the iterator until it is exhausted.
+func namesOf(t *testing.T, iter internal.RouteIterator) []string {
+	t.Helper()
+	var names []string
+	for iter.Next() {
+		names = append(names, iter.Name())
+		if rt := iter.Route(); rt == nil || rt.URL != urlOf(iter.Name()) {
+			t.Fatalf("unexpected route for %s: %v", iter.Name(), rt)
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	return names
+}
+
+func testGetPut(t *testing.T, ctx context.Context, b backend.Backend) {
+	mustNotExist(t, ctx, b, "a")
+
+	a := &internal.Route{
+		URL:         "http://www.kellegous.com/",
+		Time:        now(),
+		Created:     now(),
+		Passthrough: internal.PassthroughIgnore,
+		Owner:       "alice",
+		CoOwners:    []string{"bob", "eng"},
+		Description: "a blog",
+		Tags:        []string{"blog", "personal"},
+		ModifiedBy:  "alice",
+	}
+
+	if err := b.Put(ctx, "a", a); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Revision != 1 {
+		t.Fatalf("expected Put to set revision 1, got %d", a.Revision)
+	}
+
+	rt := mustGet(t, ctx, b, "a")
+
+	if !rt.Time.Equal(a.Time) || !rt.Created.Equal(a.Created) {
+		t.Fatalf("expected times of %s and %s, got %s and %s", a.Time, a.Created, rt.Time, rt.Created)
+	}
+
+	// times are compared above, since their locations may differ.
+	rt.Time, rt.Created = a.Time, a.Created
+	if !reflect.DeepEqual(rt, a) {
+		t.Fatalf("expected %+v, got %+v", a, rt)
+	}
+
+	// replacing a route moves it to a new revision.
+	if err := b.Put(ctx, "a", &internal.Route{URL: "http://a/2", Time: now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rt := mustGet(t, ctx, b, "a"); rt.URL != "http://a/2" || rt.Revision != 2 || rt.Owner != "" {
+		t.Fatalf("expected the route to be replaced, got %+v", rt)
+	}
+}
+
+func testPutIf(t *testing.T, ctx context.Context, b backend.Backend) {
+	if err := b.PutIf(ctx, "a", &internal.Route{URL: urlOf("a")}, 0); !errors.Is(err, internal.ErrRouteNotFound) {
+		t.Fatalf("expected ErrRouteNotFound, got %v", err)
+	}
+	mustNotExist(t, ctx, b, "a")
+
+	putRoutes(t, ctx, b, "a")
+
+	if err := b.PutIf(ctx, "a", &internal.Route{URL: "http://a/2"}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.PutIf(ctx, "a", &internal.Route{URL: "http://a/3"}, 1); !errors.Is(err, internal.ErrRevisionMismatch) {
+		t.Fatalf("expected ErrRevisionMismatch, got %v", err)
+	}
+
+	if rt := mustGet(t, ctx, b, "a"); rt.URL != "http://a/2" || rt.Revision != 2 {
+		t.Fatalf("expected http://a/2 at revision 2, got %s at revision %d", rt.URL, rt.Revision)
+	}
+}
+
+func testCreate(t *testing.T, ctx context.Context, b backend.Backend) {
+	if err := b.Create(ctx, "a", &internal.Route{URL: urlOf("a")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Create(ctx, "a", &internal.Route{URL: "http://a/2"}); !errors.Is(err, internal.ErrRouteExists) {
+		t.Fatalf("expected ErrRouteExists, got %v", err)
+	}
+
+	if rt := mustGet(t, ctx, b, "a"); rt.URL != urlOf("a") || rt.Revision != 1 {
+		t.Fatalf("expected %s at revision 1, got %s at revision %d", urlOf("a"), rt.URL, rt.Revision)
+	}
+}
+
+func testDel(t *testing.T, ctx context.Context, b backend.Backend) {
+	putRoutes(t, ctx, b, "a", "b")
+
+	if err := b.Del(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	mustNotExist(t, ctx, b, "a")
+	mustGet(t, ctx, b, "b")
+
+	// deleting what doesn't exist isn't an error.
+	if err := b.Del(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Del(ctx, "nope"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the name can be used again.
+	if err := b.Create(ctx, "a", &internal.Route{URL: urlOf("a")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Make a batch of routes with the given names.
+func batchOf(names ...string) []*internal.NamedRoute {
+	var routes []*internal.NamedRoute
+	for _, name := range names {
+		routes = append(routes, &internal.NamedRoute{
+			Name: name,
+			Route: &internal.Route{
+				URL:  urlOf(name) + "batch",
+				Time: now(),
+			},
+		})
+	}
+	return routes
+}
+
+func testPutBatch(t *testing.T, ctx context.Context, b backend.Backend) {
+	putRoutes(t, ctx, b, "a")
+
+	// existing routes can cause the whole batch to fail.
+	res, err := b.PutBatch(ctx, batchOf("c", "a"), internal.ConflictFail)
+	if !errors.Is(err, internal.ErrRouteExists) {
+		t.Fatalf("expected ErrRouteExists, got %v", err)
+	}
+
+	if res == nil || !reflect.DeepEqual(res.Skipped, []string{"a"}) {
+		t.Fatalf("expected a to be the conflict, got %+v", res)
+	}
+	mustNotExist(t, ctx, b, "c")
+
+	// or be left alone.
+	res, err = b.PutBatch(ctx, batchOf("a", "b"), internal.ConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res, &internal.BatchResult{
+		Created: []string{"b"},
+		Skipped: []string{"a"},
+	}) {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	if rt := mustGet(t, ctx, b, "a"); rt.URL != urlOf("a") || rt.Revision != 1 {
+		t.Fatalf("expected a to be unchanged, got %+v", rt)
+	}
+
+	if rt := mustGet(t, ctx, b, "b"); rt.URL != urlOf("b")+"batch" || rt.Revision != 1 {
+		t.Fatalf("expected b to be created, got %+v", rt)
+	}
+
+	// or be replaced.
+	res, err = b.PutBatch(ctx, batchOf("a", "b", "c"), internal.ConflictOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res, &internal.BatchResult{
+		Created: []string{"c"},
+		Updated: []string{"a", "b"},
+	}) {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	if rt := mustGet(t, ctx, b, "a"); rt.URL != urlOf("a")+"batch" || rt.Revision != 2 {
+		t.Fatalf("expected a to be replaced, got %+v", rt)
+	}
+
+	revs, err := b.History(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revs) != 2 || revs[1].OldURL != urlOf("a") || revs[1].NewURL != urlOf("a")+"batch" {
+		t.Fatalf("expected the replacement to be recorded, got %v", revs)
+	}
+
+	if _, err := b.PutBatch(ctx, batchOf("d", "d"), internal.ConflictOverwrite); err == nil {
+		t.Fatal("expected an error for a batch with duplicate names")
+	}
+	mustNotExist(t, ctx, b, "d")
+}
+
+func testGetAll(t *testing.T, ctx context.Context, b backend.Backend) {
+	all, err := b.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(all) != 0 {
+		t.Fatalf("expected no routes, got %v", all)
+	}
+
+	putRoutes(t, ctx, b, "a", "b", "c")
+
+	if err := b.Del(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err = b.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 2 || all["a"].URL != urlOf("a") || all["c"].URL != urlOf("c") {
+		t.Fatalf("expected a and c, got %v", all)
+	}
+}
+
+func testList(t *testing.T, ctx context.Context, b backend.Backend) {
+	iter, err := b.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if iter.Valid() {
+		t.Fatal("expected an empty iterator to be invalid")
+	}
+
+	if names := namesOf(t, iter); len(names) != 0 {
+		t.Fatalf("expected no routes, got %v", names)
+	}
+	iter.Release()
+
+	putRoutes(t, ctx, b, "c", ":1", "a", "e", "b", "d")
+
+	iter, err = b.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if iter.Valid() {
+		t.Fatal("expected the iterator to be invalid before Next")
+	}
+
+	if names := namesOf(t, iter); !reflect.DeepEqual(names, []string{":1", "a", "b", "c", "d", "e"}) {
+		t.Fatalf("expected all routes in order, got %v", names)
+	}
+
+	if iter.Valid() {
+		t.Fatal("expected an exhausted iterator to be invalid")
+	}
+	iter.Release()
+
+	iter, err = b.List(ctx, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := namesOf(t, iter); !reflect.DeepEqual(names, []string{"c", "d", "e"}) {
+		t.Fatalf("expected routes from c, got %v", names)
+	}
+	iter.Release()
+
+	iter, err = b.List(ctx, "bb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := namesOf(t, iter); !reflect.DeepEqual(names, []string{"c", "d", "e"}) {
+		t.Fatalf("expected routes after bb, got %v", names)
+	}
+	iter.Release()
+}
+
+func testSeek(t *testing.T, ctx context.Context, b backend.Backend) {
+	putRoutes(t, ctx, b, "a", "c", "e", "g")
+
+	iter, err := b.List(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Release()
+
+	// seeking to a name that exists positions the iterator on it.
+	if !iter.Seek("e") || !iter.Valid() || iter.Name() != "e" || iter.Route().URL != urlOf("e") {
+		t.Fatalf("expected to be at e, got %q", iter.Name())
+	}
+
+	if !iter.Next() || iter.Name() != "g" {
+		t.Fatalf("expected to be at g after e, got %q", iter.Name())
+	}
+
+	// seeking between names positions the iterator on the next one.
+	if !iter.Seek("d") || iter.Name() != "e" {
+		t.Fatalf("expected to be at e, got %q", iter.Name())
+	}
+
+	// seeking never goes before the start of the iteration.
+	if !iter.Seek("") || iter.Name() != "c" {
+		t.Fatalf("expected to be at c, got %q", iter.Name())
+	}
+
+	if names := namesOf(t, iter); !reflect.DeepEqual(names, []string{"e", "g"}) {
+		t.Fatalf("expected e and g after c, got %v", names)
+	}
+
+	// seeking past the end exhausts the iterator without an error.
+	if iter.Seek("h") || iter.Valid() {
+		t.Fatal("expected seeking past the end to invalidate the iterator")
+	}
+
+	if iter.Next() {
+		t.Fatal("expected no more routes")
+	}
+
+	if err := iter.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testDump(t *testing.T, ctx context.Context, b backend.Backend) {
+	putRoutes(t, ctx, b, "c", "a", "b")
+
+	iter, err := b.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Release()
+
+	// changes made after the dump began are not part of it.
+	if err := b.Del(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	putRoutes(t, ctx, b, "aa", "d")
+	if err := b.Put(ctx, "a", &internal.Route{URL: "http://changed/", Time: now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := namesOf(t, iter); !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Fatalf("expected a, b and c, got %v", names)
+	}
+}
+
+func testNextID(t *testing.T, ctx context.Context, b backend.Backend) {
+	for i := uint64(1); i <= 3; i++ {
+		id, err := b.NextID(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id != i {
+			t.Fatalf("expected id %d, got %d", i, id)
+		}
+	}
+}
+
+func lastID(t *testing.T, ctx context.Context, b backend.Backend) uint64 {
+	t.Helper()
+	id, err := b.LastID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func testAdvanceID(t *testing.T, ctx context.Context, b backend.Backend) {
+	if id := lastID(t, ctx, b); id != 0 {
+		t.Fatalf("expected no last id, got %d", id)
+	}
+
+	if _, err := b.NextID(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if id := lastID(t, ctx, b); id != 1 {
+		t.Fatalf("expected last id 1, got %d", id)
+	}
+
+	if err := b.AdvanceID(ctx, 41); err != nil {
+		t.Fatal(err)
+	}
+
+	if id := lastID(t, ctx, b); id != 41 {
+		t.Fatalf("expected last id 41, got %d", id)
+	}
+
+	// the counter never moves backwards.
+	if err := b.AdvanceID(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := b.NextID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 42 {
+		t.Fatalf("expected id 42, got %d", id)
+	}
+}
+
+func testConcurrentNextID(t *testing.T, ctx context.Context, b backend.Backend) {
+	ids := make([]uint64, concurrency)
+	errs := make([]error, concurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], errs[i] = b.NextID(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	for i, id := range ids {
+		if id != uint64(i+1) {
+			t.Fatalf("expected ids 1 to %d, got %v", concurrency, ids)
+		}
+	}
+}
+
+func testConcurrentPut(t *testing.T, ctx context.Context, b backend.Backend) {
+	errs := make([]error, concurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = b.Put(ctx, "a", &internal.Route{
+				URL:  fmt.Sprintf("http://a/%d", i),
+				Time: now(),
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	// every change was recorded as its own revision.
+	revs, err := b.History(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revs) != concurrency {
+		t.Fatalf("expected %d revisions, got %d", concurrency, len(revs))
+	}
+
+	for i, rev := range revs {
+		if rev.ID != int64(i+1) {
+			t.Fatalf("expected revision %d, got %d", i+1, rev.ID)
+		}
+	}
+
+	rt := mustGet(t, ctx, b, "a")
+	if last := revs[len(revs)-1]; rt.Revision != last.ID || rt.URL != last.NewURL {
+		t.Fatalf("expected the route to match the last revision, got %+v and %+v", rt, last)
+	}
+}
+
+func testHistory(t *testing.T, ctx context.Context, b backend.Backend) {
+	revs, err := b.History(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(revs) != 0 {
+		t.Fatalf("expected no revisions, got %v", revs)
+	}
+
+	putRoutes(t, ctx, b, "a")
+
+	actx := internal.WithActor(ctx, "alice")
+
+	if err := b.Put(actx, "a", &internal.Route{URL: "http://a/2", Time: now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Del(actx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	revs, err = b.History(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revs) != 3 {
+		t.Fatalf("expected 3 revisions, got %d", len(revs))
+	}
+
+	if revs[0].OldURL != "" || revs[0].NewURL != urlOf("a") || revs[0].Route == nil {
+		t.Fatalf("unexpected creation: %+v", revs[0])
+	}
+
+	if revs[1].OldURL != urlOf("a") || revs[1].NewURL != "http://a/2" || revs[1].By != "alice" {
+		t.Fatalf("unexpected update: %+v", revs[1])
+	}
+
+	if revs[2].OldURL != "http://a/2" || revs[2].NewURL != "" || revs[2].Route != nil {
+		t.Fatalf("unexpected deletion: %+v", revs[2])
+	}
+}
+
+func testHits(t *testing.T, ctx context.Context, b backend.Backend) {
+	t0 := now()
+	t1 := t0.Add(time.Minute)
+
+	if err := b.AddHits(ctx, map[string]*internal.Hits{
+		"a": {Count: 2, Last: t1},
+		"b": {Count: 1, Last: t0},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.AddHits(ctx, map[string]*internal.Hits{
+		"a": {Count: 3, Last: t0},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	hits, err := b.GetHits(ctx, []string{"a", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hits) != 1 || hits["a"].Count != 5 || !hits["a"].Last.Equal(t1) {
+		t.Fatalf("unexpected hits: %v", hits)
+	}
+
+	all, err := b.GetAllHits(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 2 || all["a"].Count != 5 || all["b"].Count != 1 {
+		t.Fatalf("unexpected hits: %v", all)
+	}
+}
+
+func testClicks(t *testing.T, ctx context.Context, b backend.Backend) {
+	t0 := time.Now().Truncate(internal.ClickBucketSize)
+	t1 := t0.Add(internal.ClickBucketSize)
+	t2 := t1.Add(internal.ClickBucketSize)
+
+	if err := b.AddClicks(ctx, map[string][]*internal.Clicks{
+		"a": {
+			{Start: t1, Count: 1, Referrers: map[string]int64{"x.com": 1}},
+			{Start: t0, Count: 2, Agents: map[string]int64{"browser": 2}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.AddClicks(ctx, map[string][]*internal.Clicks{
+		"a": {
+			{Start: t1, Count: 2, Referrers: map[string]int64{"x.com": 1, "y.com": 1}},
+			{Start: t2, Count: 1},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	clicks, err := b.GetClicks(ctx, "a", t0, t2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clicks) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(clicks))
+	}
+
+	if !clicks[0].Start.Equal(t0) || clicks[0].Count != 2 || clicks[0].Agents["browser"] != 2 {
+		t.Fatalf("unexpected bucket: %+v", clicks[0])
+	}
+
+	if !clicks[1].Start.Equal(t1) || clicks[1].Count != 3 ||
+		!reflect.DeepEqual(clicks[1].Referrers, map[string]int64{"x.com": 2, "y.com": 1}) {
+		t.Fatalf("unexpected bucket: %+v", clicks[1])
+	}
+
+	clicks, err = b.GetClicks(ctx, "b", t0, t2)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(clicks) != 0 {
+		t.Fatalf("expected no buckets, got %v", clicks)
+	}
+}
+
+func testTokens(t *testing.T, ctx context.Context, b backend.Backend) {
+	if _, err := b.GetToken(ctx, "nope"); !errors.Is(err, internal.ErrTokenNotFound) {
+		t.Fatalf("expected ErrTokenNotFound, got %v", err)
+	}
+
+	for _, tk := range []*internal.Token{
+		{ID: "b", Owner: "alice", Scopes: []string{"read"}, Created: now(), Hash: []byte{1, 2}},
+		{ID: "a", Owner: "bob", Scopes: []string{"read"}, Created: now(), Hash: []byte{3, 4}},
+		{ID: "c", Owner: "alice", Groups: []string{"eng"}, Scopes: []string{"write:own"}, Created: now(), Hash: []byte{5, 6}},
+	} {
+		if err := b.PutToken(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tk, err := b.GetToken(ctx, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tk.Owner != "alice" || !reflect.DeepEqual(tk.Groups, []string{"eng"}) || !reflect.DeepEqual(tk.Hash, []byte{5, 6}) {
+		t.Fatalf("unexpected token: %+v", tk)
+	}
+
+	idsOf := func(tokens []*internal.Token) []string {
+		var ids []string
+		for _, tk := range tokens {
+			ids = append(ids, tk.ID)
+		}
+		return ids
+	}
+
+	tokens, err := b.ListTokens(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	} else if ids := idsOf(tokens); !reflect.DeepEqual(ids, []string{"b", "c"}) {
+		t.Fatalf("expected tokens b and c, got %v", ids)
+	}
+
+	tokens, err = b.ListTokens(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	} else if ids := idsOf(tokens); !reflect.DeepEqual(ids, []string{"a", "b", "c"}) {
+		t.Fatalf("expected all tokens, got %v", ids)
+	}
+
+	if err := b.DelToken(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.GetToken(ctx, "b"); !errors.Is(err, internal.ErrTokenNotFound) {
+		t.Fatalf("expected ErrTokenNotFound, got %v", err)
+	}
+}
+
+func testAudit(t *testing.T, ctx context.Context, b backend.Backend) {
+	t0 := now().Add(-time.Hour)
+
+	events := []*internal.AuditEvent{
+		{Time: t0, Actor: "alice", Action: internal.AuditCreate, Name: "a"},
+		{Time: t0.Add(time.Second), Actor: "bob", Action: internal.AuditUpdate, Name: "a"},
+		{Time: t0.Add(2 * time.Second), Actor: "alice", Action: internal.AuditDelete, Name: "b"},
+	}
+
+	ids := map[string]bool{}
+	for _, e := range events {
+		if err := b.AppendAudit(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+
+		if e.ID == "" || ids[e.ID] {
+			t.Fatalf("expected a new ID, got %q", e.ID)
+		}
+		ids[e.ID] = true
+	}
+
+	actionsOf := func(filter *internal.AuditFilter) []string {
+		t.Helper()
+		events, err := b.ListAudit(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var actions []string
+		for _, e := range events {
+			actions = append(actions, e.Action)
+		}
+		return actions
+	}
+
+	tests := []struct {
+		filter  internal.AuditFilter
+		actions []string
+	}{
+		{internal.AuditFilter{}, []string{"create", "update", "delete"}},
+		{internal.AuditFilter{Actor: "alice"}, []string{"create", "delete"}},
+		{internal.AuditFilter{Name: "a"}, []string{"create", "update"}},
+		{internal.AuditFilter{Since: t0.Add(time.Second)}, []string{"update", "delete"}},
+		{internal.AuditFilter{Limit: 2}, []string{"create", "update"}},
+		{internal.AuditFilter{Actor: "carol"}, nil},
+	}
+
+	for _, test := range tests {
+		if actions := actionsOf(&test.filter); !reflect.DeepEqual(actions, test.actions) {
+			t.Fatalf("expected %v for %+v, got %v", test.actions, test.filter, actions)
+		}
+	}
+}
+
+func testTrash(t *testing.T, ctx context.Context, b backend.Backend) {
+	if _, err := b.Restore(ctx, "a"); !errors.Is(err, internal.ErrRouteNotFound) {
+		t.Fatalf("expected ErrRouteNotFound, got %v", err)
+	}
+
+	putRoutes(t, ctx, b, "a", "b")
+
+	if err := b.Del(internal.WithActor(ctx, "alice"), "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Del(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	trash, err := b.ListTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trash) != 2 || trash[0].Name != "a" || trash[1].Name != "b" {
+		t.Fatalf("expected a and b in the trash, got %v", trash)
+	}
+
+	if trash[1].DeletedBy != "alice" || trash[1].Route == nil || trash[1].Route.URL != urlOf("b") {
+		t.Fatalf("unexpected trashed route: %+v", trash[1])
+	}
+
+	tr, err := b.GetTrash(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.Name != "b" || tr.DeletedBy != "alice" || tr.Route == nil || tr.Route.URL != urlOf("b") {
+		t.Fatalf("unexpected trashed route: %+v", tr)
+	}
+
+	if _, err := b.GetTrash(ctx, "c"); !errors.Is(err, internal.ErrRouteNotFound) {
+		t.Fatalf("expected ErrRouteNotFound, got %v", err)
+	}
+
+	rt, err := b.Restore(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.URL != urlOf("a") {
+		t.Fatalf("expected %s, got %s", urlOf("a"), rt.URL)
+	}
+
+	if _, err := b.GetTrash(ctx, "a"); !errors.Is(err, internal.ErrRouteNotFound) {
+		t.Fatalf("expected a to be out of the trash, got %v", err)
+	}
+
+	if got := mustGet(t, ctx, b, "a"); got.Revision != rt.Revision || got.Revision != 3 {
+		t.Fatalf("expected the restored route at revision 3, got %d", got.Revision)
+	}
+
+	// a name that has been taken again can't be restored over.
+	putRoutes(t, ctx, b, "b")
+	if _, err := b.Restore(ctx, "b"); !errors.Is(err, internal.ErrRouteExists) {
+		t.Fatalf("expected ErrRouteExists, got %v", err)
+	}
+
+	names, err := b.PurgeTrash(ctx, now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(names) != 0 {
+		t.Fatalf("expected nothing to be purged, got %v", names)
+	}
+
+	names, err = b.PurgeTrash(ctx, now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(names) != 1 || names[0] != "b" {
+		t.Fatalf("expected b to be purged, got %v", names)
+	}
+
+	trash, err = b.ListTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(trash) != 0 {
+		t.Fatalf("expected an empty trash, got %v", trash)
+	}
+}
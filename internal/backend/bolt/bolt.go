@@ -0,0 +1,396 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+)
+
+const (
+	dbFilename = "go.db"
+
+	// How long to wait for another process to release the database.
+	openTimeout = 5 * time.Second
+
+	// How much of the database is mapped into memory up front. The mapping
+	// only grows while no read transactions are open, so writes made while a
+	// dump is in progress wait for it unless the database still fits.
+	initialMmapSize = 1 << 30
+)
+
+var (
+	routesBucket  = []byte("routes")
+	historyBucket = []byte("history")
+	hitsBucket    = []byte("hits")
+	clicksBucket  = []byte("clicks")
+	tokensBucket  = []byte("tokens")
+	auditBucket   = []byte("audit")
+	trashBucket   = []byte("trash")
+	indexBucket   = []byte("index")
+	metaBucket    = []byte("meta")
+
+	// the key in the meta bucket of the last auto-generated ID.
+	idKey = []byte("id")
+)
+
+var _ backend.Backend = (*Backend)(nil)
+
+// Backend provides access to a bbolt database, which keeps everything in a
+// single file and maintains secondary indexes of the routes.
+type Backend struct {
+	db *bolt.DB
+}
+
+// New instantiates a new Backend that keeps its database in the directory
+// at path.
+func New(path string) (*Backend, error) {
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(filepath.Join(path, dbFilename), 0600, &bolt.Options{
+		Timeout:         openTimeout,
+		InitialMmapSize: initialMmapSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	backend := &Backend{db: db}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{
+			routesBucket,
+			historyBucket,
+			hitsBucket,
+			clicksBucket,
+			tokensBucket,
+			auditBucket,
+			trashBucket,
+			indexBucket,
+			metaBucket,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		return reindexIfNeeded(tx)
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return backend, nil
+}
+
+// Close the resources associated with this backend.
+func (backend *Backend) Close() error {
+	return backend.db.Close()
+}
+
+func encodeRoute(rt *internal.Route) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := rt.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeRoute(b []byte) (*internal.Route, error) {
+	rt := &internal.Route{}
+	if err := rt.Read(bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// Read the named route as part of the transaction, returning nil if it does
+// not exist.
+func getRoute(tx *bolt.Tx, name string) (*internal.Route, error) {
+	val := tx.Bucket(routesBucket).Get([]byte(name))
+	if val == nil {
+		return nil, nil
+	}
+	return decodeRoute(val)
+}
+
+// Get retreives a shortcut from the data store.
+func (backend *Backend) Get(ctx context.Context, name string) (*internal.Route, error) {
+	var rt *internal.Route
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		var err error
+		rt, err = getRoute(tx, name)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if rt == nil {
+		return nil, internal.ErrRouteNotFound
+	}
+
+	return rt, nil
+}
+
+// Put stores a new shortcut in the data store.
+func (backend *Backend) Put(ctx context.Context, key string, rt *internal.Route) error {
+	return backend.db.Update(func(tx *bolt.Tx) error {
+		old, err := getRoute(tx, key)
+		if err != nil {
+			return err
+		}
+
+		return write(ctx, tx, key, old, rt)
+	})
+}
+
+// PutIf stores the shortcut only if the existing shortcut is at revision rev.
+// It fails with internal.ErrRouteNotFound if there is no existing shortcut and
+// with internal.ErrRevisionMismatch if it has since changed.
+func (backend *Backend) PutIf(ctx context.Context, key string, rt *internal.Route, rev int64) error {
+	return backend.db.Update(func(tx *bolt.Tx) error {
+		old, err := getRoute(tx, key)
+		if err != nil {
+			return err
+		} else if old == nil {
+			return internal.ErrRouteNotFound
+		}
+
+		if old.Revision != rev {
+			return internal.ErrRevisionMismatch
+		}
+
+		return write(ctx, tx, key, old, rt)
+	})
+}
+
+// Create stores the shortcut only if there is no existing shortcut with the
+// same name. It fails with internal.ErrRouteExists otherwise.
+func (backend *Backend) Create(ctx context.Context, key string, rt *internal.Route) error {
+	return backend.db.Update(func(tx *bolt.Tx) error {
+		old, err := getRoute(tx, key)
+		if err != nil {
+			return err
+		} else if old != nil {
+			return internal.ErrRouteExists
+		}
+
+		return write(ctx, tx, key, nil, rt)
+	})
+}
+
+// PutBatch stores all of the routes in a single transaction, doing what the
+// policy says with those that already exist.
+func (backend *Backend) PutBatch(
+	ctx context.Context,
+	routes []*internal.NamedRoute,
+	policy internal.ConflictPolicy,
+) (*internal.BatchResult, error) {
+	var res *internal.BatchResult
+	err := backend.db.Update(func(tx *bolt.Tx) error {
+		var writes []*internal.BatchWrite
+		var err error
+		res, writes, err = internal.PlanBatch(routes, policy, func(name string) (*internal.Route, error) {
+			return getRoute(tx, name)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, w := range writes {
+			if err := write(ctx, tx, w.Name, w.Old, w.Route); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return res, err
+}
+
+// Write rt as the named route, replacing old, as part of the transaction. The
+// change is recorded as a new revision and the indexes are updated to match.
+func write(ctx context.Context, tx *bolt.Tx, key string, old, rt *internal.Route) error {
+	id, err := lastRevisionID(tx, key)
+	if err != nil {
+		return err
+	}
+
+	rt.Revision = id + 1
+
+	b, err := encodeRoute(rt)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(routesBucket).Put([]byte(key), b); err != nil {
+		return err
+	}
+
+	if err := unindex(tx, key, old); err != nil {
+		return err
+	}
+
+	if err := index(tx, key, rt); err != nil {
+		return err
+	}
+
+	return record(ctx, tx, key, id, old, rt)
+}
+
+// Del moves an existing shortcut from the data store into the trash.
+func (backend *Backend) Del(ctx context.Context, key string) error {
+	return backend.db.Update(func(tx *bolt.Tx) error {
+		old, err := getRoute(tx, key)
+		if err != nil {
+			return err
+		} else if old == nil {
+			return nil
+		}
+
+		id, err := lastRevisionID(tx, key)
+		if err != nil {
+			return err
+		}
+
+		if err := putTrash(tx, &internal.TrashedRoute{
+			Name:      key,
+			Route:     old,
+			Deleted:   time.Now(),
+			DeletedBy: internal.ActorFrom(ctx),
+		}); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(routesBucket).Delete([]byte(key)); err != nil {
+			return err
+		}
+
+		if err := unindex(tx, key, old); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, key, id, old, nil)
+	})
+}
+
+// List all routes in an iterator, starting with the key prefix of start (which can also be nil).
+func (backend *Backend) List(ctx context.Context, start string) (internal.RouteIterator, error) {
+	return &RouteIterator{
+		db:    backend.db,
+		start: start,
+		key:   []byte(start),
+		incl:  true,
+		more:  true,
+	}, nil
+}
+
+// Dump iterates over every route, in order of name, as they all were when
+// Dump was called. The iterator holds a read transaction open until it is
+// released, so writes that would grow the database beyond initialMmapSize
+// wait for it.
+func (backend *Backend) Dump(ctx context.Context) (internal.RouteIterator, error) {
+	tx, err := backend.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RouteIterator{
+		db:   backend.db,
+		tx:   tx,
+		incl: true,
+		more: true,
+	}, nil
+}
+
+// GetAll gets everything in the db to dump it out for backup purposes
+func (backend *Backend) GetAll(ctx context.Context) (map[string]internal.Route, error) {
+	golinks := map[string]internal.Route{}
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(routesBucket).ForEach(func(k, v []byte) error {
+			rt, err := decodeRoute(v)
+			if err != nil {
+				return err
+			}
+			golinks[string(k)] = *rt
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return golinks, nil
+}
+
+// Read the last auto-generated ID as part of the transaction.
+func lastID(tx *bolt.Tx) (uint64, error) {
+	v := tx.Bucket(metaBucket).Get(idKey)
+	if v == nil {
+		return 0, nil
+	} else if len(v) != 8 {
+		return 0, errors.New("invalid id record")
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+func putLastID(tx *bolt.Tx, id uint64) error {
+	return tx.Bucket(metaBucket).Put(idKey, binary.BigEndian.AppendUint64(nil, id))
+}
+
+// NextID generates the next numeric ID to be used for an auto-named shortcut.
+func (backend *Backend) NextID(ctx context.Context) (uint64, error) {
+	var id uint64
+	if err := backend.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if id, err = lastID(tx); err != nil {
+			return err
+		}
+
+		id++
+
+		return putLastID(tx, id)
+	}); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// LastID returns the last ID generated by NextID, or 0 if there hasn't been
+// one.
+func (backend *Backend) LastID(ctx context.Context) (uint64, error) {
+	var id uint64
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		var err error
+		id, err = lastID(tx)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// AdvanceID ensures that NextID only generates IDs after id. It never moves
+// the counter backwards.
+func (backend *Backend) AdvanceID(ctx context.Context, id uint64) error {
+	return backend.db.Update(func(tx *bolt.Tx) error {
+		cur, err := lastID(tx)
+		if err != nil {
+			return err
+		} else if id <= cur {
+			return nil
+		}
+		return putLastID(tx, id)
+	})
+}
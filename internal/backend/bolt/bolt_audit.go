@@ -0,0 +1,76 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Stops iteration of the audit log once the filter's limit is reached.
+var errAuditLimit = errors.New("audit limit reached")
+
+// AppendAudit appends the event to the audit log, assigning its ID. Events
+// are keyed by the bucket's big-endian sequence number so that they sort in
+// the order they were appended.
+func (backend *Backend) AppendAudit(ctx context.Context, event *internal.AuditEvent) error {
+	e := *event
+	if err := backend.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(auditBucket)
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		e.ID = fmt.Sprintf("%020d", seq)
+
+		v, err := json.Marshal(&e)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(binary.BigEndian.AppendUint64(nil, seq), v)
+	}); err != nil {
+		return err
+	}
+
+	event.ID = e.ID
+	return nil
+}
+
+// ListAudit returns the events selected by the filter in the order they were
+// appended.
+func (backend *Backend) ListAudit(
+	ctx context.Context,
+	filter *internal.AuditFilter,
+) ([]*internal.AuditEvent, error) {
+	var res []*internal.AuditEvent
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(auditBucket).ForEach(func(k, v []byte) error {
+			var e internal.AuditEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+
+			if !filter.Matches(&e) {
+				return nil
+			}
+
+			res = append(res, &e)
+			if filter.Limit > 0 && len(res) >= filter.Limit {
+				return errAuditLimit
+			}
+			return nil
+		})
+	}); err != nil && !errors.Is(err, errAuditLimit) {
+		return nil, err
+	}
+
+	return res, nil
+}
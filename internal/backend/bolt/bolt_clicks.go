@@ -0,0 +1,94 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// The clicks of each route are kept in a bucket of their own, nested in the
+// clicks bucket under the route's name, and keyed by the big-endian start of
+// the bucket in seconds so that they sort by time.
+func clicksKey(start time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(start.Unix()))
+}
+
+// AddClicks adds the given visits to the stored buckets of each route.
+func (backend *Backend) AddClicks(ctx context.Context, clicks map[string][]*internal.Clicks) error {
+	return backend.db.Update(func(tx *bolt.Tx) error {
+		for name, cs := range clicks {
+			b, err := tx.Bucket(clicksBucket).CreateBucketIfNotExists([]byte(name))
+			if err != nil {
+				return err
+			}
+
+			for _, c := range cs {
+				key := clicksKey(c.Start)
+
+				cur := internal.Clicks{
+					Start: c.Start,
+				}
+
+				if val := b.Get(key); val != nil {
+					if err := json.Unmarshal(val, &cur); err != nil {
+						return err
+					}
+				}
+
+				cur.Add(c)
+
+				v, err := json.Marshal(&cur)
+				if err != nil {
+					return err
+				}
+
+				if err := b.Put(key, v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// GetClicks returns the stored buckets of the named route that start in the
+// range [from, to), in order of time.
+func (backend *Backend) GetClicks(ctx context.Context, name string, from, to time.Time) ([]*internal.Clicks, error) {
+	var res []*internal.Clicks
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(clicksBucket).Bucket([]byte(name))
+		if b == nil {
+			return nil
+		}
+
+		// the keys are unsigned, so a range that starts before 1970 starts at
+		// the beginning.
+		start := clicksKey(from)
+		if from.Unix() < 0 {
+			start = nil
+		}
+
+		cur := b.Cursor()
+		for k, v := cur.Seek(start); k != nil; k, v = cur.Next() {
+			if int64(binary.BigEndian.Uint64(k)) >= to.Unix() {
+				break
+			}
+
+			var c internal.Clicks
+			if err := json.Unmarshal(v, &c); err != nil {
+				return err
+			}
+			res = append(res, &c)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
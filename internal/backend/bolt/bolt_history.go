@@ -0,0 +1,91 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// The revisions of each route are kept in a bucket of their own, nested in
+// the history bucket under the route's name, and keyed by the big-endian
+// revision ID so that they sort in order of ID.
+func revisionKey(id int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+// Find the ID of the most recent revision of the named route, or 0 if there
+// are none.
+func lastRevisionID(tx *bolt.Tx, name string) (int64, error) {
+	b := tx.Bucket(historyBucket).Bucket([]byte(name))
+	if b == nil {
+		return 0, nil
+	}
+
+	k, _ := b.Cursor().Last()
+	if k == nil {
+		return 0, nil
+	}
+
+	return int64(binary.BigEndian.Uint64(k)), nil
+}
+
+// Record a change to the named route from old to rt, following the revision
+// with the given ID. Either route may be nil to indicate a creation or a
+// deletion.
+func record(ctx context.Context, tx *bolt.Tx, name string, id int64, old, rt *internal.Route) error {
+	rev := internal.Revision{
+		ID:    id + 1,
+		Time:  time.Now(),
+		By:    internal.ActorFrom(ctx),
+		Route: rt,
+	}
+
+	if old != nil {
+		rev.OldURL = old.URL
+	}
+
+	if rt != nil {
+		rev.NewURL = rt.URL
+	}
+
+	b, err := json.Marshal(&rev)
+	if err != nil {
+		return err
+	}
+
+	revs, err := tx.Bucket(historyBucket).CreateBucketIfNotExists([]byte(name))
+	if err != nil {
+		return err
+	}
+
+	return revs.Put(revisionKey(rev.ID), b)
+}
+
+// History returns the revisions of the named route, oldest first.
+func (backend *Backend) History(ctx context.Context, name string) ([]*internal.Revision, error) {
+	var revs []*internal.Revision
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyBucket).Bucket([]byte(name))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			var rev internal.Revision
+			if err := json.Unmarshal(v, &rev); err != nil {
+				return err
+			}
+			revs = append(revs, &rev)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return revs, nil
+}
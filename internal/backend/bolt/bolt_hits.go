@@ -0,0 +1,99 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Hits are stored as a little-endian count followed by the time of the last
+// visit in nanoseconds.
+func encodeHits(h *internal.Hits) []byte {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint64(b, uint64(h.Count))
+	binary.LittleEndian.PutUint64(b[8:], uint64(h.Last.UnixNano()))
+	return b
+}
+
+func decodeHits(b []byte) (*internal.Hits, error) {
+	if len(b) != 16 {
+		return nil, errors.New("invalid hits record")
+	}
+
+	return &internal.Hits{
+		Count: int64(binary.LittleEndian.Uint64(b)),
+		Last:  time.Unix(0, int64(binary.LittleEndian.Uint64(b[8:]))),
+	}, nil
+}
+
+// AddHits adds the given visits to the totals of each route.
+func (backend *Backend) AddHits(ctx context.Context, hits map[string]*internal.Hits) error {
+	return backend.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hitsBucket)
+		for name, h := range hits {
+			cur := &internal.Hits{}
+			if val := b.Get([]byte(name)); val != nil {
+				var err error
+				if cur, err = decodeHits(val); err != nil {
+					return err
+				}
+			}
+
+			cur.Add(h)
+			if err := b.Put([]byte(name), encodeHits(cur)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetHits returns the visits to each of the named routes. Routes that have
+// never been visited are omitted.
+func (backend *Backend) GetHits(ctx context.Context, names []string) (map[string]*internal.Hits, error) {
+	res := map[string]*internal.Hits{}
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hitsBucket)
+		for _, name := range names {
+			val := b.Get([]byte(name))
+			if val == nil {
+				continue
+			}
+
+			h, err := decodeHits(val)
+			if err != nil {
+				return err
+			}
+			res[name] = h
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetAllHits returns the visits to every route that has been visited.
+func (backend *Backend) GetAllHits(ctx context.Context) (map[string]*internal.Hits, error) {
+	res := map[string]*internal.Hits{}
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(hitsBucket).ForEach(func(k, v []byte) error {
+			h, err := decodeHits(v)
+			if err != nil {
+				return err
+			}
+			res[string(k)] = h
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
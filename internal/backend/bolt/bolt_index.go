@@ -0,0 +1,247 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"net/url"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Index identifies a secondary index of the routes.
+type Index string
+
+const (
+	// ByOwner indexes routes by their owner.
+	ByOwner Index = "owner"
+
+	// ByTag indexes routes by each of their tags.
+	ByTag Index = "tag"
+
+	// ByHost indexes routes by the host of the URL they redirect to.
+	ByHost Index = "host"
+
+	// byUpdated indexes routes by the time they were last updated. It is
+	// queried with RecentlyUpdated rather than Lookup.
+	byUpdated Index = "updated"
+)
+
+// The version of the layout of the indexes. Changing what is indexed or how
+// requires a new version so that existing databases are reindexed when they
+// are opened.
+const indexVersion = 1
+
+// the key in the meta bucket of the version of the indexes.
+var indexVersionKey = []byte("index")
+
+// The values of each index that refer to the route. Within the index bucket,
+// each of owner, tag and host is a bucket of buckets, one for each value,
+// holding the names of the routes with that value as keys.
+func indexValues(rt *internal.Route) map[Index][]string {
+	vals := map[Index][]string{}
+
+	if rt.Owner != "" {
+		vals[ByOwner] = []string{rt.Owner}
+	}
+
+	for _, tag := range rt.Tags {
+		if tag != "" {
+			vals[ByTag] = append(vals[ByTag], tag)
+		}
+	}
+
+	if host := hostOf(rt.URL); host != "" {
+		vals[ByHost] = []string{host}
+	}
+
+	return vals
+}
+
+// The host of the URL, without its port, in lower case. URLs that can't be
+// parsed have no host.
+func hostOf(u string) string {
+	p, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(p.Hostname())
+}
+
+// Routes are keyed in the updated index by the time they were last updated
+// followed by their name. The seconds are offset so that times before 1970
+// still sort first.
+func updatedKey(t time.Time, name string) []byte {
+	b := binary.BigEndian.AppendUint64(nil, uint64(t.Unix())^(1<<63))
+	b = binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+	return append(b, name...)
+}
+
+const updatedKeyLen = 12
+
+// Add the route to the indexes.
+func index(tx *bolt.Tx, name string, rt *internal.Route) error {
+	if rt == nil {
+		return nil
+	}
+
+	idx := tx.Bucket(indexBucket)
+	for i, vals := range indexValues(rt) {
+		b, err := idx.CreateBucketIfNotExists([]byte(i))
+		if err != nil {
+			return err
+		}
+
+		for _, val := range vals {
+			names, err := b.CreateBucketIfNotExists([]byte(val))
+			if err != nil {
+				return err
+			}
+
+			if err := names.Put([]byte(name), []byte{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	b, err := idx.CreateBucketIfNotExists([]byte(byUpdated))
+	if err != nil {
+		return err
+	}
+
+	return b.Put(updatedKey(rt.Time, name), []byte{})
+}
+
+// Remove the route from the indexes, dropping the values that no longer refer
+// to any route.
+func unindex(tx *bolt.Tx, name string, rt *internal.Route) error {
+	if rt == nil {
+		return nil
+	}
+
+	idx := tx.Bucket(indexBucket)
+	for i, vals := range indexValues(rt) {
+		b := idx.Bucket([]byte(i))
+		if b == nil {
+			continue
+		}
+
+		for _, val := range vals {
+			names := b.Bucket([]byte(val))
+			if names == nil {
+				continue
+			}
+
+			if err := names.Delete([]byte(name)); err != nil {
+				return err
+			}
+
+			if k, _ := names.Cursor().First(); k == nil {
+				if err := b.DeleteBucket([]byte(val)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if b := idx.Bucket([]byte(byUpdated)); b != nil {
+		return b.Delete(updatedKey(rt.Time, name))
+	}
+
+	return nil
+}
+
+// Rebuild the indexes from the routes if they were built with a different
+// version, or not at all.
+func reindexIfNeeded(tx *bolt.Tx) error {
+	meta := tx.Bucket(metaBucket)
+	if v := meta.Get(indexVersionKey); len(v) == 8 && binary.BigEndian.Uint64(v) == indexVersion {
+		return nil
+	}
+
+	if err := tx.DeleteBucket(indexBucket); err != nil {
+		return err
+	}
+
+	if _, err := tx.CreateBucket(indexBucket); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(routesBucket).ForEach(func(k, v []byte) error {
+		rt, err := decodeRoute(v)
+		if err != nil {
+			return err
+		}
+		return index(tx, string(k), rt)
+	}); err != nil {
+		return err
+	}
+
+	return meta.Put(indexVersionKey, binary.BigEndian.AppendUint64(nil, indexVersion))
+}
+
+// Lookup returns the names of the routes that have the value in the index, in
+// order of name. Owners and tags are matched exactly and hosts are matched
+// without regard to case.
+func (backend *Backend) Lookup(ctx context.Context, idx Index, val string) ([]string, error) {
+	if idx == ByHost {
+		val = strings.ToLower(val)
+	}
+
+	var names []string
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(indexBucket).Bucket([]byte(idx))
+		if b == nil || val == "" {
+			return nil
+		}
+
+		b = b.Bucket([]byte(val))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			names = append(names, string(k))
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// RecentlyUpdated returns the names of the routes updated at or after since,
+// most recent first. If limit is positive, no more than limit names are
+// returned.
+func (backend *Backend) RecentlyUpdated(ctx context.Context, since time.Time, limit int) ([]string, error) {
+	var names []string
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(indexBucket).Bucket([]byte(byUpdated))
+		if b == nil {
+			return nil
+		}
+
+		min := updatedKey(since, "")
+
+		c := b.Cursor()
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			if string(k[:updatedKeyLen]) < string(min) {
+				break
+			}
+
+			names = append(names, string(k[updatedKeyLen:]))
+			if limit > 0 && len(names) >= limit {
+				break
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
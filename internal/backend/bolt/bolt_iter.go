@@ -0,0 +1,161 @@
+package bolt
+
+import (
+	"bytes"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// How many routes the iterator reads in each transaction.
+const iterBatchSize = 100
+
+type namedRoute struct {
+	name string
+	rt   *internal.Route
+}
+
+// RouteIterator allows iteration of the named routes in the store. Routes are
+// read in batches, each in its own transaction, rather than holding a single
+// transaction open for the life of the iterator. bbolt cannot grow the
+// database while a read transaction is open, so a long-lived iterator would
+// block writes made before it is released.
+type RouteIterator struct {
+	db    *bolt.DB
+	start string
+
+	// the transaction in which every batch is read, if the iterator is a
+	// snapshot.
+	tx *bolt.Tx
+
+	// where the next batch is read from and whether that key is included.
+	key  []byte
+	incl bool
+	more bool
+
+	buf []namedRoute
+	pos int
+	err error
+}
+
+// Run fn in the iterator's transaction, or in a new one if it has none.
+func (i *RouteIterator) view(fn func(tx *bolt.Tx) error) error {
+	if i.tx != nil {
+		return fn(i.tx)
+	}
+	return i.db.View(fn)
+}
+
+// Read the next batch of routes.
+func (i *RouteIterator) fetch() error {
+	i.buf, i.pos = nil, 0
+
+	return i.view(func(tx *bolt.Tx) error {
+		c := tx.Bucket(routesBucket).Cursor()
+
+		k, v := c.Seek(i.key)
+		if !i.incl && k != nil && bytes.Equal(k, i.key) {
+			k, v = c.Next()
+		}
+
+		for ; k != nil && len(i.buf) < iterBatchSize; k, v = c.Next() {
+			rt, err := decodeRoute(v)
+			if err != nil {
+				return err
+			}
+			i.buf = append(i.buf, namedRoute{name: string(k), rt: rt})
+		}
+
+		i.more = k != nil
+		if n := len(i.buf); n > 0 {
+			i.key = []byte(i.buf[n-1].name)
+			i.incl = false
+		}
+
+		return nil
+	})
+}
+
+// Valid indicates whether the current values of the iterator are valid.
+func (i *RouteIterator) Valid() bool {
+	return i.pos < len(i.buf) && i.err == nil
+}
+
+// Next advances the iterator to the next value.
+func (i *RouteIterator) Next() bool {
+	if i.err != nil {
+		return false
+	}
+
+	if i.pos+1 < len(i.buf) {
+		i.pos++
+		return true
+	}
+
+	i.buf, i.pos = nil, 0
+	if !i.more {
+		return false
+	}
+
+	if err := i.fetch(); err != nil {
+		i.err = err
+		return false
+	}
+
+	return i.Valid()
+}
+
+// Seek positions the iterator on the first route whose name is at or after
+// cur, but never before the start of the listing.
+func (i *RouteIterator) Seek(cur string) bool {
+	if i.err != nil {
+		return false
+	}
+
+	if cur < i.start {
+		cur = i.start
+	}
+
+	i.key = []byte(cur)
+	i.incl = true
+
+	if err := i.fetch(); err != nil {
+		i.err = err
+		return false
+	}
+
+	return i.Valid()
+}
+
+// Error returns any active error that has stopped the iterator.
+func (i *RouteIterator) Error() error {
+	return i.err
+}
+
+// Name is the name of the current route.
+func (i *RouteIterator) Name() string {
+	if !i.Valid() {
+		return ""
+	}
+	return i.buf[i.pos].name
+}
+
+// Route is the current route.
+func (i *RouteIterator) Route() *internal.Route {
+	if !i.Valid() {
+		return nil
+	}
+	return i.buf[i.pos].rt
+}
+
+// Release disposes of the resources in the iterator.
+func (i *RouteIterator) Release() {
+	i.buf = nil
+	i.more = false
+
+	if i.tx != nil {
+		i.tx.Rollback()
+		i.tx = nil
+	}
+}
@@ -0,0 +1,274 @@
+package bolt
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+	"github.com/kellegous/go/internal/backend/backendtest"
+)
+
+func newTestBackend(t *testing.T) *Backend {
+	backend, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) backend.Backend {
+		return newTestBackend(t)
+	})
+}
+
+func lookup(t *testing.T, backend *Backend, idx Index, val string) []string {
+	t.Helper()
+	names, err := backend.Lookup(context.Background(), idx, val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestIndexes(t *testing.T) {
+	backend := newTestBackend(t)
+	defer backend.Close()
+
+	ctx := context.Background()
+
+	for name, rt := range map[string]*internal.Route{
+		"a": {
+			URL:   "https://github.com/kellegous/go",
+			Owner: "alice",
+			Tags:  []string{"code", "go"},
+		},
+		"b": {
+			URL:   "https://GitHub.com:443/",
+			Owner: "bob",
+			Tags:  []string{"code"},
+		},
+		"c": {
+			URL:   "https://example.com/",
+			Owner: "alice",
+		},
+	} {
+		if err := backend.Put(ctx, name, rt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		idx      Index
+		val      string
+		expected []string
+	}{
+		{ByOwner, "alice", []string{"a", "c"}},
+		{ByOwner, "bob", []string{"b"}},
+		{ByOwner, "carol", nil},
+		{ByTag, "code", []string{"a", "b"}},
+		{ByTag, "go", []string{"a"}},
+		{ByHost, "github.com", []string{"a", "b"}},
+		{ByHost, "EXAMPLE.com", []string{"c"}},
+		{ByHost, "", nil},
+	}
+
+	for _, test := range tests {
+		if names := lookup(t, backend, test.idx, test.val); !reflect.DeepEqual(names, test.expected) {
+			t.Fatalf("%s %q: expected %v, got %v", test.idx, test.val, test.expected, names)
+		}
+	}
+
+	// changing a route moves it in the indexes.
+	if err := backend.Put(ctx, "a", &internal.Route{
+		URL:   "https://example.com/go",
+		Owner: "bob",
+		Tags:  []string{"go"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := lookup(t, backend, ByOwner, "bob"); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Fatalf("expected a and b to be owned by bob, got %v", names)
+	}
+
+	if names := lookup(t, backend, ByTag, "code"); !reflect.DeepEqual(names, []string{"b"}) {
+		t.Fatalf("expected only b to be tagged code, got %v", names)
+	}
+
+	if names := lookup(t, backend, ByHost, "example.com"); !reflect.DeepEqual(names, []string{"a", "c"}) {
+		t.Fatalf("expected a and c to go to example.com, got %v", names)
+	}
+
+	// deleting a route removes it from the indexes and restoring it adds it
+	// back.
+	if err := backend.Del(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := lookup(t, backend, ByOwner, "alice"); names != nil {
+		t.Fatalf("expected nothing to be owned by alice, got %v", names)
+	}
+
+	if _, err := backend.Restore(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := lookup(t, backend, ByOwner, "alice"); !reflect.DeepEqual(names, []string{"c"}) {
+		t.Fatalf("expected c to be owned by alice, got %v", names)
+	}
+}
+
+func TestRecentlyUpdated(t *testing.T) {
+	backend := newTestBackend(t)
+	defer backend.Close()
+
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, name := range []string{"c", "a", "d", "b"} {
+		if err := backend.Put(ctx, name, &internal.Route{
+			URL:  "http://" + name + "/",
+			Time: base.Add(time.Duration(i) * time.Hour),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, err := backend.RecentlyUpdated(ctx, time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"b", "d", "a", "c"}) {
+		t.Fatalf("expected most recent first, got %v", names)
+	}
+
+	names, err = backend.RecentlyUpdated(ctx, base.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"b", "d", "a"}) {
+		t.Fatalf("expected routes updated since a, got %v", names)
+	}
+
+	// updating a route moves it to the front.
+	if err := backend.Put(ctx, "c", &internal.Route{
+		URL:  "http://c/",
+		Time: base.Add(time.Hour * 24),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err = backend.RecentlyUpdated(ctx, time.Time{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"c", "b"}) {
+		t.Fatalf("expected c and b, got %v", names)
+	}
+}
+
+func TestReindex(t *testing.T) {
+	dir := t.TempDir()
+
+	backend, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	if err := backend.Put(ctx, "a", &internal.Route{
+		URL:   "http://a/",
+		Owner: "alice",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a database whose indexes were built by an older version.
+	if err := backend.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(indexBucket); err != nil {
+			return err
+		}
+
+		if _, err := tx.CreateBucket(indexBucket); err != nil {
+			return err
+		}
+
+		return tx.Bucket(metaBucket).Delete(indexVersionKey)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := lookup(t, backend, ByOwner, "alice"); names != nil {
+		t.Fatalf("expected no index, got %v", names)
+	}
+
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	if names := lookup(t, backend, ByOwner, "alice"); !reflect.DeepEqual(names, []string{"a"}) {
+		t.Fatalf("expected a to be reindexed, got %v", names)
+	}
+}
+
+func TestListBatches(t *testing.T) {
+	backend := newTestBackend(t)
+	defer backend.Close()
+
+	ctx := context.Background()
+
+	var names []string
+	for i := 0; i < 2*iterBatchSize+5; i++ {
+		name := fmt.Sprintf("r%03d", i)
+		if err := backend.Put(ctx, name, &internal.Route{
+			URL:  "http://" + name + "/",
+			Time: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	iter, err := backend.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Release()
+
+	var got []string
+	for iter.Next() {
+		got = append(got, iter.Name())
+
+		// writing while iterating doesn't wait on the iterator.
+		if len(got) == iterBatchSize/2 {
+			if err := backend.Put(ctx, "r000", &internal.Route{URL: "http://changed/"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, names) {
+		t.Fatalf("expected %d routes in order, got %v", len(names), got)
+	}
+}
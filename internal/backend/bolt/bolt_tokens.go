@@ -0,0 +1,70 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// PutToken stores the API token, replacing any token with the same ID.
+func (backend *Backend) PutToken(ctx context.Context, token *internal.Token) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return backend.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokensBucket).Put([]byte(token.ID), b)
+	})
+}
+
+// GetToken retrieves the API token with the given ID.
+func (backend *Backend) GetToken(ctx context.Context, id string) (*internal.Token, error) {
+	var t *internal.Token
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(tokensBucket).Get([]byte(id))
+		if val == nil {
+			return internal.ErrTokenNotFound
+		}
+
+		t = &internal.Token{}
+		return json.Unmarshal(val, t)
+	}); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ListTokens returns the API tokens that belong to owner, or all tokens if
+// owner is empty, ordered by ID.
+func (backend *Backend) ListTokens(ctx context.Context, owner string) ([]*internal.Token, error) {
+	var res []*internal.Token
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokensBucket).ForEach(func(k, v []byte) error {
+			var t internal.Token
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+
+			if owner == "" || t.Owner == owner {
+				res = append(res, &t)
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DelToken revokes the API token with the given ID.
+func (backend *Backend) DelToken(ctx context.Context, id string) error {
+	return backend.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokensBucket).Delete([]byte(id))
+	})
+}
@@ -0,0 +1,126 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Put the deleted route into the trash, replacing any earlier deletion of a
+// route with the same name.
+func putTrash(tx *bolt.Tx, t *internal.TrashedRoute) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(trashBucket).Put([]byte(t.Name), b)
+}
+
+// ListTrash returns the deleted routes that can still be restored, ordered by
+// name.
+func (backend *Backend) ListTrash(ctx context.Context) ([]*internal.TrashedRoute, error) {
+	var res []*internal.TrashedRoute
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(trashBucket).ForEach(func(k, v []byte) error {
+			var t internal.TrashedRoute
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			res = append(res, &t)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetTrash returns the deleted route with the given name if it can still be
+// restored. It fails with internal.ErrRouteNotFound otherwise.
+func (backend *Backend) GetTrash(ctx context.Context, name string) (*internal.TrashedRoute, error) {
+	var t internal.TrashedRoute
+	if err := backend.db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(trashBucket).Get([]byte(name))
+		if val == nil {
+			return internal.ErrRouteNotFound
+		}
+		return json.Unmarshal(val, &t)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Restore moves the named route out of the trash, returning it as it was when
+// it was deleted. It fails with internal.ErrRouteExists if another route with
+// the same name has been created since.
+func (backend *Backend) Restore(ctx context.Context, name string) (*internal.Route, error) {
+	var t internal.TrashedRoute
+	if err := backend.db.Update(func(tx *bolt.Tx) error {
+		val := tx.Bucket(trashBucket).Get([]byte(name))
+		if val == nil {
+			return internal.ErrRouteNotFound
+		}
+
+		if err := json.Unmarshal(val, &t); err != nil {
+			return err
+		}
+
+		if cur, err := getRoute(tx, name); err != nil {
+			return err
+		} else if cur != nil {
+			return internal.ErrRouteExists
+		}
+
+		if err := write(ctx, tx, name, nil, t.Route); err != nil {
+			return err
+		}
+
+		return tx.Bucket(trashBucket).Delete([]byte(name))
+	}); err != nil {
+		return nil, err
+	}
+
+	return t.Route, nil
+}
+
+// PurgeTrash permanently removes the routes that were deleted before the
+// given time, returning the names of those that were removed in order.
+func (backend *Backend) PurgeTrash(ctx context.Context, before time.Time) ([]string, error) {
+	var names []string
+	if err := backend.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(trashBucket)
+		if err := b.ForEach(func(k, v []byte) error {
+			var t internal.TrashedRoute
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+
+			if t.Deleted.Before(before) {
+				names = append(names, t.Name)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			if err := b.Delete([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
@@ -0,0 +1,133 @@
+//go:build unix
+
+package firestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// How long to wait for the emulator to start accepting requests.
+const emulatorStartTimeout = time.Minute
+
+// Run the tests against a firestore emulator. If FIRESTORE_EMULATOR_HOST
+// isn't already set, an emulator is started with gcloud when it is installed.
+// Otherwise, the tests that need an emulator are skipped.
+func TestMain(m *testing.M) {
+	if os.Getenv(emulatorHostEnv) != "" {
+		os.Exit(m.Run())
+	}
+
+	stop, err := startEmulator()
+	if err != nil {
+		log.Printf("not starting a firestore emulator: %s", err)
+		os.Exit(m.Run())
+	}
+
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+// Find a local address that is free to listen on.
+func freeAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+// Start a firestore emulator with gcloud and point FIRESTORE_EMULATOR_HOST at
+// it, returning a function that stops it.
+func startEmulator() (func(), error) {
+	gcloud, err := exec.LookPath("gcloud")
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := freeAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(gcloud, "emulators", "firestore", "start", "--host-port="+addr)
+
+	// gcloud runs the emulator in a child process, so the whole process group
+	// has to be stopped.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	stop := func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+		select {
+		case <-exited:
+		case <-time.After(10 * time.Second):
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), emulatorStartTimeout)
+	defer cancel()
+
+	if err := waitForEmulator(ctx, addr, exited); err != nil {
+		stop()
+		return nil, err
+	}
+
+	if err := os.Setenv(emulatorHostEnv, addr); err != nil {
+		stop()
+		return nil, err
+	}
+
+	return stop, nil
+}
+
+// Wait until the emulator at addr responds to requests.
+func waitForEmulator(ctx context.Context, addr string, exited <-chan error) error {
+	t := time.NewTicker(250 * time.Millisecond)
+	defer t.Stop()
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, "GET", "http://"+addr+"/", nil)
+		if err != nil {
+			return err
+		}
+
+		if res, err := http.DefaultClient.Do(req); err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return fmt.Errorf("emulator: %w", err)
+		case <-ctx.Done():
+			return fmt.Errorf("emulator did not start: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
@@ -2,6 +2,7 @@ package firestore
 
 import (
 	"context"
+	"os"
 	"time"
 
 	fs "cloud.google.com/go/firestore"
@@ -14,9 +15,10 @@ import (
 
 var _ backend.Backend = (*Backend)(nil)
 
-// NextID is the next numeric ID to use for auto-generated IDs
+// NextID is the next numeric ID to use for auto-generated IDs. It is signed
+// since firestore can only store signed integers.
 type NextID struct {
-	ID uint32 `json:"id" firestore:"id"`
+	ID int64 `json:"id" firestore:"id"`
 }
 
 // Backend provides access to Google Firestore.
@@ -24,10 +26,20 @@ type Backend struct {
 	db *fs.Client
 }
 
+// emulatorHostEnv is the environment variable that, when set, points the
+// backend at a firestore emulator rather than the real thing.
+const emulatorHostEnv = "FIRESTORE_EMULATOR_HOST"
+
 // New instantiates a new Backend
 func New(ctx context.Context, path string) (*Backend, error) {
 	if path == "" {
-		path = getGoogleProject()
+		if os.Getenv(emulatorHostEnv) != "" {
+			// the emulator needs no credentials and accepts any project, so
+			// let the client pick one rather than looking for credentials.
+			path = fs.DetectProjectID
+		} else {
+			path = getGoogleProject()
+		}
 	}
 	client, err := fs.NewClient(ctx, path)
 	if err != nil {
@@ -69,42 +81,228 @@ func (backend *Backend) Get(ctx context.Context, name string) (*internal.Route,
 func (backend *Backend) Put(ctx context.Context, key string, rt *internal.Route) error {
 	ref := backend.db.Doc("routes/" + key)
 
-	_, err := ref.Set(ctx, rt)
+	return backend.db.RunTransaction(ctx, func(tctx context.Context, tx *fs.Transaction) error {
+		old, err := getRoute(tx, ref)
+		if err != nil {
+			return err
+		}
+
+		id, err := lastRevisionID(tx, ref)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, ref, id, old, rt)
+	})
+}
+
+// PutIf stores the shortcut only if the existing shortcut is at revision rev.
+// It fails with internal.ErrRouteNotFound if there is no existing shortcut and
+// with internal.ErrRevisionMismatch if it has since changed.
+func (backend *Backend) PutIf(ctx context.Context, key string, rt *internal.Route, rev int64) error {
+	ref := backend.db.Doc("routes/" + key)
+
+	return backend.db.RunTransaction(ctx, func(tctx context.Context, tx *fs.Transaction) error {
+		old, err := getRoute(tx, ref)
+		if err != nil {
+			return err
+		} else if old == nil {
+			return internal.ErrRouteNotFound
+		}
+
+		if old.Revision != rev {
+			return internal.ErrRevisionMismatch
+		}
+
+		id, err := lastRevisionID(tx, ref)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, ref, id, old, rt)
+	})
+}
+
+// Create stores the shortcut only if there is no existing shortcut with the
+// same name. It fails with internal.ErrRouteExists otherwise.
+func (backend *Backend) Create(ctx context.Context, key string, rt *internal.Route) error {
+	ref := backend.db.Doc("routes/" + key)
+
+	return backend.db.RunTransaction(ctx, func(tctx context.Context, tx *fs.Transaction) error {
+		old, err := getRoute(tx, ref)
+		if err != nil {
+			return err
+		} else if old != nil {
+			return internal.ErrRouteExists
+		}
+
+		id, err := lastRevisionID(tx, ref)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, ref, id, nil, rt)
+	})
+}
+
+// How many routes PutBatch writes in each transaction. Each route also writes
+// a revision, and firestore limits how much a single transaction can write.
+const batchChunkSize = 200
+
+// PutBatch stores all of the routes, doing what the policy says with those
+// that already exist. The routes are written in transactions of
+// batchChunkSize, so a batch larger than that is not atomic. Under
+// internal.ConflictFail, every route is checked before any are written.
+func (backend *Backend) PutBatch(
+	ctx context.Context,
+	routes []*internal.NamedRoute,
+	policy internal.ConflictPolicy,
+) (*internal.BatchResult, error) {
+	if len(routes) == 0 {
+		return &internal.BatchResult{}, nil
+	}
+
+	refs := make([]*fs.DocumentRef, 0, len(routes))
+	for _, nr := range routes {
+		refs = append(refs, backend.db.Doc("routes/"+nr.Name))
+	}
+
+	// check the whole batch up front, so that it fails before anything is
+	// written.
+	snaps, err := backend.db.GetAll(ctx, refs)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	exists := map[string]bool{}
+	for i, snap := range snaps {
+		exists[routes[i].Name] = snap.Exists()
+	}
+
+	if res, _, err := internal.PlanBatch(routes, policy, func(name string) (*internal.Route, error) {
+		if exists[name] {
+			return &internal.Route{}, nil
+		}
+		return nil, nil
+	}); err != nil {
+		return res, err
 	}
 
-	return nil
+	res := &internal.BatchResult{}
+	for i := 0; i < len(routes); i += batchChunkSize {
+		chunk := routes[i:min(i+batchChunkSize, len(routes))]
+
+		var cr *internal.BatchResult
+		if err := backend.db.RunTransaction(ctx, func(tctx context.Context, tx *fs.Transaction) error {
+			var writes []*internal.BatchWrite
+			var err error
+			cr, writes, err = internal.PlanBatch(chunk, policy, func(name string) (*internal.Route, error) {
+				return getRoute(tx, backend.db.Doc("routes/"+name))
+			})
+			if err != nil {
+				return err
+			}
+
+			// every read has to happen before any of the writes.
+			ids := make([]int64, len(writes))
+			for i, w := range writes {
+				if ids[i], err = lastRevisionID(tx, backend.db.Doc("routes/"+w.Name)); err != nil {
+					return err
+				}
+			}
+
+			for i, w := range writes {
+				if err := record(ctx, tx, backend.db.Doc("routes/"+w.Name), ids[i], w.Old, w.Route); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return res, err
+		}
+
+		res.Created = append(res.Created, cr.Created...)
+		res.Updated = append(res.Updated, cr.Updated...)
+		res.Skipped = append(res.Skipped, cr.Skipped...)
+	}
+
+	return res, nil
 }
 
-// Del removes an existing shortcut from the data store.
+// Del moves an existing shortcut from the data store into the trash.
 func (backend *Backend) Del(ctx context.Context, key string) error {
 	ref := backend.db.Doc("routes/" + key)
 
-	_, err := ref.Delete(ctx)
-	if err != nil {
-		return err
-	}
+	return backend.db.RunTransaction(ctx, func(tctx context.Context, tx *fs.Transaction) error {
+		old, err := getRoute(tx, ref)
+		if err != nil {
+			return err
+		} else if old == nil {
+			return nil
+		}
 
-	return nil
+		id, err := lastRevisionID(tx, ref)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Set(backend.trashDoc(key), &internal.TrashedRoute{
+			Name:      key,
+			Route:     old,
+			Deleted:   time.Now(),
+			DeletedBy: internal.ActorFrom(ctx),
+		}); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, ref, id, old, nil)
+	})
 }
 
-// List all routes in an iterator, starting with the key prefix of start (which can also be nil).
-func (backend *Backend) List(ctx context.Context, start string) (internal.RouteIterator, error) {
-	col := backend.db.Collection("routes").OrderBy(fs.DocumentID, fs.Asc)
+// Query the routes in order of name, starting with start (which can also be
+// empty).
+func routesFrom(db *fs.Client, start string) fs.Query {
+	q := db.Collection("routes").OrderBy(fs.DocumentID, fs.Asc)
 
 	if start != "" {
 		// we have a starting ID.
-		col = col.StartAt(start)
+		q = q.StartAt(start)
 	}
 
+	return q
+}
+
+// List all routes in an iterator, starting with the key prefix of start (which can also be nil).
+func (backend *Backend) List(ctx context.Context, start string) (internal.RouteIterator, error) {
 	return &RouteIterator{
-		ctx: ctx,
-		db:  backend.db,
-		it:  col.Documents(ctx),
+		ctx:   ctx,
+		db:    backend.db,
+		it:    routesFrom(backend.db, start).Documents(ctx),
+		start: start,
 	}, nil
 }
 
+// Dump iterates over every route, in order of name, as they all were when
+// Dump was called. The read time comes from firestore itself, by way of the
+// document holding the next ID, so that it doesn't depend on this machine's
+// clock.
+func (backend *Backend) Dump(ctx context.Context) (internal.RouteIterator, error) {
+	// a missing document still reports when it was read.
+	doc, err := backend.db.Doc("IDs/nextID").Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return nil, err
+	}
+
+	i := &RouteIterator{
+		ctx:      ctx,
+		db:       backend.db,
+		readTime: doc.ReadTime,
+	}
+	i.it = i.query("")
+	return i, nil
+}
+
 // GetAll gets everything in the db to dump it out for backup purposes
 func (backend *Backend) GetAll(ctx context.Context) (map[string]internal.Route, error) {
 	golinks := map[string]internal.Route{}
@@ -127,7 +325,7 @@ func (backend *Backend) GetAll(ctx context.Context) (map[string]internal.Route,
 // NextID generates the next numeric ID to be used for an auto-named shortcut.
 func (backend *Backend) NextID(ctx context.Context) (uint64, error) {
 	ref := backend.db.Doc("IDs/nextID")
-	var nid uint32
+	var nid int64
 
 	err := backend.db.RunTransaction(ctx, func(ctx context.Context, tx *fs.Transaction) error {
 		var nextID *NextID
@@ -164,6 +362,50 @@ func (backend *Backend) NextID(ctx context.Context) (uint64, error) {
 	return uint64(nid), nil
 }
 
+// LastID returns the last ID generated by NextID, or 0 if there hasn't been
+// one.
+func (backend *Backend) LastID(ctx context.Context) (uint64, error) {
+	doc, err := backend.db.Doc("IDs/nextID").Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var nextID NextID
+	if err := doc.DataTo(&nextID); err != nil {
+		return 0, err
+	}
+
+	return uint64(nextID.ID), nil
+}
+
+// AdvanceID ensures that NextID only generates IDs after id. It never moves
+// the counter backwards.
+func (backend *Backend) AdvanceID(ctx context.Context, id uint64) error {
+	ref := backend.db.Doc("IDs/nextID")
+
+	return backend.db.RunTransaction(ctx, func(ctx context.Context, tx *fs.Transaction) error {
+		var nextID NextID
+
+		doc, err := tx.Get(ref)
+		if err == nil {
+			if err := doc.DataTo(&nextID); err != nil {
+				return err
+			}
+		} else if status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		if int64(id) <= nextID.ID {
+			return nil
+		}
+
+		nextID.ID = int64(id)
+		return tx.Set(ref, &nextID)
+	})
+}
+
 func getGoogleProject() string {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
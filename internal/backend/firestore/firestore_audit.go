@@ -0,0 +1,67 @@
+package firestore
+
+import (
+	"context"
+
+	fs "cloud.google.com/go/firestore"
+
+	"github.com/kellegous/go/internal"
+)
+
+// AppendAudit appends the event to the audit log, assigning its ID.
+func (backend *Backend) AppendAudit(ctx context.Context, event *internal.AuditEvent) error {
+	ref := backend.db.Collection("audit").NewDoc()
+
+	e := *event
+	e.ID = ref.ID
+
+	// Create fails rather than overwriting, which keeps the log append-only.
+	if _, err := ref.Create(ctx, &e); err != nil {
+		return err
+	}
+
+	event.ID = e.ID
+	return nil
+}
+
+// ListAudit returns the events selected by the filter in the order they were
+// appended.
+func (backend *Backend) ListAudit(
+	ctx context.Context,
+	filter *internal.AuditFilter,
+) ([]*internal.AuditEvent, error) {
+	q := backend.db.Collection("audit").Query
+
+	if filter.Actor != "" {
+		q = q.Where("Actor", "==", filter.Actor)
+	}
+
+	if filter.Name != "" {
+		q = q.Where("Name", "==", filter.Name)
+	}
+
+	if !filter.Since.IsZero() {
+		q = q.Where("Time", ">=", filter.Since)
+	}
+
+	q = q.OrderBy("Time", fs.Asc)
+
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	docs, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*internal.AuditEvent, 0, len(docs))
+	for _, doc := range docs {
+		var e internal.AuditEvent
+		if err := doc.DataTo(&e); err != nil {
+			return nil, err
+		}
+		res = append(res, &e)
+	}
+	return res, nil
+}
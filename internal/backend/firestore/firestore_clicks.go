@@ -0,0 +1,99 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+
+	"github.com/kellegous/go/internal"
+)
+
+// The click buckets of a route are kept in a clicks subcollection of the
+// route's document. Each is named by the zero-padded start of the bucket in
+// seconds.
+func clicksOf(ref *fs.DocumentRef) *fs.CollectionRef {
+	return ref.Collection("clicks")
+}
+
+func clicksDocID(start time.Time) string {
+	return fmt.Sprintf("%020d", start.Unix())
+}
+
+// Build a map of increments for each of the given counts.
+func increments(counts map[string]int64) map[string]interface{} {
+	m := make(map[string]interface{}, len(counts))
+	for k, v := range counts {
+		m[k] = fs.Increment(v)
+	}
+	return m
+}
+
+// AddClicks adds the given visits to the stored buckets of each route.
+func (backend *Backend) AddClicks(ctx context.Context, clicks map[string][]*internal.Clicks) error {
+	bw := backend.db.BulkWriter(ctx)
+
+	var jobs []*fs.BulkWriterJob
+	for name, cs := range clicks {
+		col := clicksOf(backend.db.Doc("routes/" + name))
+		for _, c := range cs {
+			data := map[string]interface{}{
+				"Start": c.Start,
+				"Count": fs.Increment(c.Count),
+			}
+
+			// an empty map would replace the stored one when merged, so they are
+			// only included when there is something to add.
+			if len(c.Referrers) > 0 {
+				data["Referrers"] = increments(c.Referrers)
+			}
+
+			if len(c.Agents) > 0 {
+				data["Agents"] = increments(c.Agents)
+			}
+
+			job, err := bw.Set(col.Doc(clicksDocID(c.Start)), data, fs.MergeAll)
+			if err != nil {
+				bw.End()
+				return err
+			}
+			jobs = append(jobs, job)
+		}
+	}
+
+	bw.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetClicks returns the stored buckets of the named route that start in the
+// range [from, to), in order of time.
+func (backend *Backend) GetClicks(ctx context.Context, name string, from, to time.Time) ([]*internal.Clicks, error) {
+	docs, err := clicksOf(backend.db.Doc("routes/"+name)).
+		OrderBy("Start", fs.Asc).
+		StartAt(from).
+		EndBefore(to).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*internal.Clicks, 0, len(docs))
+	for _, doc := range docs {
+		var c internal.Clicks
+		if err := doc.DataTo(&c); err != nil {
+			return nil, err
+		}
+		res = append(res, &c)
+	}
+
+	return res, nil
+}
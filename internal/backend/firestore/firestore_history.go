@@ -0,0 +1,121 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kellegous/go/internal"
+)
+
+// The revisions of a route are kept in a history subcollection of the route's
+// document, which outlives the route itself.
+func historyOf(ref *fs.DocumentRef) *fs.CollectionRef {
+	return ref.Collection("history")
+}
+
+// Revision documents are named by their zero-padded ID so that they also sort
+// by ID.
+func revisionDocID(id int64) string {
+	return fmt.Sprintf("%020d", id)
+}
+
+// Read the route in the given document as part of the transaction, returning
+// nil if it does not exist.
+func getRoute(tx *fs.Transaction, ref *fs.DocumentRef) (*internal.Route, error) {
+	snap, err := tx.Get(ref)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rt internal.Route
+	if err := snap.DataTo(&rt); err != nil {
+		return nil, err
+	}
+
+	return &rt, nil
+}
+
+// Find the ID of the most recent revision of the route in the given document,
+// or 0 if there are none.
+func lastRevisionID(tx *fs.Transaction, ref *fs.DocumentRef) (int64, error) {
+	docs, err := tx.Documents(historyOf(ref).OrderBy("ID", fs.Desc).Limit(1)).GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	var rev internal.Revision
+	if err := docs[0].DataTo(&rev); err != nil {
+		return 0, err
+	}
+
+	return rev.ID, nil
+}
+
+// Change the route in the given document from old to rt as part of the
+// transaction, recording a new revision that follows the one with the given
+// ID. rt may be nil to delete the route.
+// All reads in the transaction must happen before this is called.
+func record(
+	ctx context.Context,
+	tx *fs.Transaction,
+	ref *fs.DocumentRef,
+	id int64,
+	old, rt *internal.Route,
+) error {
+	rev := internal.Revision{
+		ID:    id + 1,
+		Time:  time.Now(),
+		By:    internal.ActorFrom(ctx),
+		Route: rt,
+	}
+
+	if old != nil {
+		rev.OldURL = old.URL
+	}
+
+	if rt != nil {
+		rt.Revision = rev.ID
+		rev.NewURL = rt.URL
+		if err := tx.Set(ref, rt); err != nil {
+			return err
+		}
+	} else if err := tx.Delete(ref); err != nil {
+		return err
+	}
+
+	return tx.Create(historyOf(ref).Doc(revisionDocID(rev.ID)), &rev)
+}
+
+// History returns the revisions of the named route, oldest first.
+func (backend *Backend) History(ctx context.Context, name string) ([]*internal.Revision, error) {
+	docs, err := historyOf(backend.db.Doc("routes/"+name)).
+		OrderBy("ID", fs.Asc).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	revs := make([]*internal.Revision, 0, len(docs))
+	for _, doc := range docs {
+		var rev internal.Revision
+		if err := doc.DataTo(&rev); err != nil {
+			return nil, err
+		}
+		revs = append(revs, &rev)
+	}
+
+	return revs, nil
+}
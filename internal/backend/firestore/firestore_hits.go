@@ -0,0 +1,106 @@
+package firestore
+
+import (
+	"context"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+
+	"github.com/kellegous/go/internal"
+)
+
+// AddHits adds the given visits to the totals of each route.
+func (backend *Backend) AddHits(ctx context.Context, hits map[string]*internal.Hits) error {
+	bw := backend.db.BulkWriter(ctx)
+
+	jobs := make([]*fs.BulkWriterJob, 0, len(hits))
+	for name, h := range hits {
+		// the server does the arithmetic, so concurrent flushes from several
+		// instances are all counted. Last is stored as nanoseconds since the
+		// maximum transform only applies to numbers.
+		job, err := bw.Set(backend.db.Doc("hits/"+name), map[string]interface{}{
+			"Count": fs.Increment(h.Count),
+			"Last":  fs.FieldTransformMaximum(h.Last.UnixNano()),
+		}, fs.MergeAll)
+		if err != nil {
+			bw.End()
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+
+	bw.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The stored form of internal.Hits.
+type hitsDoc struct {
+	Count int64
+	Last  int64
+}
+
+func (d *hitsDoc) toHits() *internal.Hits {
+	return &internal.Hits{
+		Count: d.Count,
+		Last:  time.Unix(0, d.Last),
+	}
+}
+
+// GetHits returns the visits to each of the named routes. Routes that have
+// never been visited are omitted.
+func (backend *Backend) GetHits(ctx context.Context, names []string) (map[string]*internal.Hits, error) {
+	res := map[string]*internal.Hits{}
+	if len(names) == 0 {
+		return res, nil
+	}
+
+	refs := make([]*fs.DocumentRef, 0, len(names))
+	for _, name := range names {
+		refs = append(refs, backend.db.Doc("hits/"+name))
+	}
+
+	snaps, err := backend.db.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snap := range snaps {
+		if !snap.Exists() {
+			continue
+		}
+
+		var d hitsDoc
+		if err := snap.DataTo(&d); err != nil {
+			return nil, err
+		}
+		res[snap.Ref.ID] = d.toHits()
+	}
+
+	return res, nil
+}
+
+// GetAllHits returns the visits to every route that has been visited.
+func (backend *Backend) GetAllHits(ctx context.Context) (map[string]*internal.Hits, error) {
+	docs, err := backend.db.Collection("hits").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]*internal.Hits{}
+	for _, doc := range docs {
+		var d hitsDoc
+		if err := doc.DataTo(&d); err != nil {
+			return nil, err
+		}
+		res[doc.Ref.ID] = d.toHits()
+	}
+
+	return res, nil
+}
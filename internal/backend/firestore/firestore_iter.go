@@ -3,6 +3,7 @@ package firestore
 import (
 	"context"
 	"errors"
+	"time"
 
 	fs "cloud.google.com/go/firestore"
 	"github.com/kellegous/go/internal"
@@ -16,6 +17,21 @@ type RouteIterator struct {
 	it  *fs.DocumentIterator
 	doc *fs.DocumentSnapshot
 	err error
+
+	// start is where the iteration began. The iterator never moves before it.
+	start string
+
+	// readTime is when the database is read, if the iterator is a snapshot.
+	readTime time.Time
+}
+
+// Query the routes from start, at the iterator's read time if it has one.
+func (i *RouteIterator) query(start string) *fs.DocumentIterator {
+	q := routesFrom(i.db, start)
+	if !i.readTime.IsZero() {
+		q.WithReadOptions(fs.ReadTime(i.readTime))
+	}
+	return q.Documents(i.ctx)
 }
 
 // Valid indicates whether the current values of the iterator are valid.
@@ -40,21 +56,18 @@ func (i *RouteIterator) Next() bool {
 	return true
 }
 
-// Seek ...
+// Seek moves the iterator to the first route whose name is at or after cur.
 func (i *RouteIterator) Seek(cur string) bool {
+	if cur < i.start {
+		cur = i.start
+	}
+
 	// firestore makes this a little hard. Make a whole new
 	// document iterator that starts at a new spot.
-	i.it = i.db.Collection("routes").OrderBy(fs.DocumentID, fs.Asc).StartAt(cur).Documents(i.ctx)
-
-	doc, err := i.it.Next()
-	if err != nil {
-		i.err = err
-		i.doc = nil
-		return false
-	}
+	i.it.Stop()
+	i.it = i.query(cur)
 
-	i.doc = doc
-	return true
+	return i.Next()
 }
 
 // Error returns any active error that has stopped the iterator.
@@ -64,11 +77,18 @@ func (i *RouteIterator) Error() error {
 
 // Name is the name of the current route.
 func (i *RouteIterator) Name() string {
+	if i.doc == nil {
+		return ""
+	}
 	return i.doc.Ref.ID
 }
 
 // Route is the current route.
 func (i *RouteIterator) Route() *internal.Route {
+	if i.doc == nil {
+		return nil
+	}
+
 	var rt internal.Route
 	if err := i.doc.DataTo(&rt); err != nil {
 		i.err = err
@@ -0,0 +1,258 @@
+package firestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+	"github.com/kellegous/go/internal/backend/backendtest"
+)
+
+var projects atomic.Int64
+
+// Create a project name that no other test uses, so that its backend starts
+// out empty.
+func newProject() string {
+	return fmt.Sprintf("test-%d-%d", time.Now().UnixNano(), projects.Add(1))
+}
+
+// Skip the test unless there is a firestore emulator to run it against.
+func needEmulator(t *testing.T) {
+	if os.Getenv(emulatorHostEnv) == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+}
+
+// Create a backend for the project that talks to the firestore emulator.
+func newBackendIn(t *testing.T, project string) *Backend {
+	needEmulator(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	backend, err := New(ctx, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return backend
+}
+
+func newTestBackend(t *testing.T) *Backend {
+	return newBackendIn(t, newProject())
+}
+
+func putRoutes(t *testing.T, ctx context.Context, b *Backend, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := b.Put(ctx, name, &internal.Route{
+			URL:  "http://" + name + "/",
+			Time: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	needEmulator(t)
+
+	backendtest.Run(t, func(t *testing.T) backend.Backend {
+		return newTestBackend(t)
+	})
+}
+
+func TestNew(t *testing.T) {
+	needEmulator(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// with the emulator, no project or credentials are needed.
+	backend, err := New(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	if _, err := backend.Get(ctx, "nope"); !errors.Is(err, internal.ErrRouteNotFound) {
+		t.Fatalf("expected ErrRouteNotFound, got %v", err)
+	}
+}
+
+func TestNextIDTransactions(t *testing.T) {
+	// both instances of the service share the counter.
+	project := newProject()
+
+	a := newBackendIn(t, project)
+	defer a.Close()
+
+	b := newBackendIn(t, project)
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	const n = 10
+
+	ids := make([]uint64, 2*n)
+	errs := make([]error, 2*n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		for j, backend := range []*Backend{a, b} {
+			wg.Add(1)
+			go func(k int, backend *Backend) {
+				defer wg.Done()
+				ids[k], errs[k] = backend.NextID(ctx)
+			}(2*i+j, backend)
+		}
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	for i, id := range ids {
+		if id != uint64(i+1) {
+			t.Fatalf("expected ids 1 to %d, got %v", 2*n, ids)
+		}
+	}
+}
+
+func TestNextIDContinues(t *testing.T) {
+	backend := newTestBackend(t)
+	defer backend.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// counters written when the ID was a uint32 are read the same way.
+	if _, err := backend.db.Doc("IDs/nextID").Set(ctx, map[string]interface{}{
+		"id": uint32(41),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := backend.NextID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 42 {
+		t.Fatalf("expected id 42, got %d", id)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	backend := newTestBackend(t)
+	defer backend.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var names []string
+	for i := 0; i < 25; i++ {
+		names = append(names, fmt.Sprintf("r%02d", i))
+	}
+	putRoutes(t, ctx, backend, names...)
+
+	// page through the routes the way /api/urls/ does, starting each page at
+	// the route that followed the last one.
+	const limit = 10
+
+	var got []string
+	cursor := ""
+	for {
+		iter, err := backend.List(ctx, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var page []string
+		for len(page) < limit && iter.Next() {
+			page = append(page, iter.Name())
+		}
+
+		more := iter.Next()
+		cursor = iter.Name()
+		err = iter.Error()
+		iter.Release()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, page...)
+		if !more {
+			break
+		}
+
+		if len(got) > len(names) {
+			t.Fatalf("too many routes: %v", got)
+		}
+	}
+
+	if !reflect.DeepEqual(got, names) {
+		t.Fatalf("expected %v, got %v", names, got)
+	}
+}
+
+func TestSeekRequery(t *testing.T) {
+	backend := newTestBackend(t)
+	defer backend.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	putRoutes(t, ctx, backend, ":1", ":2", "a", "b")
+
+	iter, err := backend.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Release()
+
+	if !iter.Next() || iter.Name() != ":1" {
+		t.Fatalf("expected to be at :1, got %q", iter.Name())
+	}
+
+	// skip the generated names the way /api/urls/ does. Seek queries again,
+	// so it sees routes created since the iteration started.
+	putRoutes(t, ctx, backend, "aa")
+
+	if !iter.Seek(";") || iter.Name() != "a" {
+		t.Fatalf("expected to be at a, got %q", iter.Name())
+	}
+
+	var names []string
+	for iter.Next() {
+		names = append(names, iter.Name())
+	}
+
+	if err := iter.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"aa", "b"}) {
+		t.Fatalf("expected aa and b, got %v", names)
+	}
+
+	if iter.Seek("c") || iter.Error() != nil {
+		t.Fatalf("expected seeking past the end to end the iteration, got %v", iter.Error())
+	}
+}
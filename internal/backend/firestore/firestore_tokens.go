@@ -0,0 +1,63 @@
+package firestore
+
+import (
+	"context"
+
+	fs "cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kellegous/go/internal"
+)
+
+// PutToken stores the API token, replacing any token with the same ID.
+func (backend *Backend) PutToken(ctx context.Context, token *internal.Token) error {
+	_, err := backend.db.Doc("tokens/"+token.ID).Set(ctx, token)
+	return err
+}
+
+// GetToken retrieves the API token with the given ID.
+func (backend *Backend) GetToken(ctx context.Context, id string) (*internal.Token, error) {
+	snap, err := backend.db.Doc("tokens/" + id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, internal.ErrTokenNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var t internal.Token
+	if err := snap.DataTo(&t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTokens returns the API tokens that belong to owner, or all tokens if
+// owner is empty, ordered by ID.
+func (backend *Backend) ListTokens(ctx context.Context, owner string) ([]*internal.Token, error) {
+	q := backend.db.Collection("tokens").Query
+	if owner != "" {
+		q = q.Where("Owner", "==", owner)
+	}
+
+	docs, err := q.OrderBy(fs.DocumentID, fs.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*internal.Token, 0, len(docs))
+	for _, doc := range docs {
+		var t internal.Token
+		if err := doc.DataTo(&t); err != nil {
+			return nil, err
+		}
+		res = append(res, &t)
+	}
+	return res, nil
+}
+
+// DelToken revokes the API token with the given ID.
+func (backend *Backend) DelToken(ctx context.Context, id string) error {
+	_, err := backend.db.Doc("tokens/" + id).Delete(ctx)
+	return err
+}
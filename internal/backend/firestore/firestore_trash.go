@@ -0,0 +1,144 @@
+package firestore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kellegous/go/internal"
+)
+
+func (backend *Backend) trashDoc(name string) *fs.DocumentRef {
+	return backend.db.Doc("trash/" + name)
+}
+
+// ListTrash returns the deleted routes that can still be restored, ordered by
+// name.
+func (backend *Backend) ListTrash(ctx context.Context) ([]*internal.TrashedRoute, error) {
+	docs, err := backend.db.Collection("trash").
+		OrderBy(fs.DocumentID, fs.Asc).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*internal.TrashedRoute, 0, len(docs))
+	for _, doc := range docs {
+		var t internal.TrashedRoute
+		if err := doc.DataTo(&t); err != nil {
+			return nil, err
+		}
+		res = append(res, &t)
+	}
+	return res, nil
+}
+
+// GetTrash returns the deleted route with the given name if it can still be
+// restored. It fails with internal.ErrRouteNotFound otherwise.
+func (backend *Backend) GetTrash(ctx context.Context, name string) (*internal.TrashedRoute, error) {
+	snap, err := backend.trashDoc(name).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, internal.ErrRouteNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var t internal.TrashedRoute
+	if err := snap.DataTo(&t); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Restore moves the named route out of the trash, returning it as it was when
+// it was deleted. It fails with internal.ErrRouteExists if another route with
+// the same name has been created since.
+func (backend *Backend) Restore(ctx context.Context, name string) (*internal.Route, error) {
+	ref := backend.db.Doc("routes/" + name)
+	tref := backend.trashDoc(name)
+
+	var rt *internal.Route
+	if err := backend.db.RunTransaction(ctx, func(tctx context.Context, tx *fs.Transaction) error {
+		snap, err := tx.Get(tref)
+		if status.Code(err) == codes.NotFound {
+			return internal.ErrRouteNotFound
+		} else if err != nil {
+			return err
+		}
+
+		var t internal.TrashedRoute
+		if err := snap.DataTo(&t); err != nil {
+			return err
+		}
+
+		cur, err := getRoute(tx, ref)
+		if err != nil {
+			return err
+		} else if cur != nil {
+			return internal.ErrRouteExists
+		}
+
+		id, err := lastRevisionID(tx, ref)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(tref); err != nil {
+			return err
+		}
+
+		rt = t.Route
+		return record(ctx, tx, ref, id, nil, rt)
+	}); err != nil {
+		return nil, err
+	}
+
+	return rt, nil
+}
+
+// PurgeTrash permanently removes the routes that were deleted before the
+// given time, returning the names of those that were removed in order.
+func (backend *Backend) PurgeTrash(ctx context.Context, before time.Time) ([]string, error) {
+	docs, err := backend.db.Collection("trash").
+		Where("Deleted", "<", before).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	bw := backend.db.BulkWriter(ctx)
+
+	jobs := make([]*fs.BulkWriterJob, 0, len(docs))
+	names := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		job, err := bw.Delete(doc.Ref)
+		if err != nil {
+			bw.End()
+			return nil, err
+		}
+		jobs = append(jobs, job)
+		names = append(names, doc.Ref.ID)
+	}
+
+	bw.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
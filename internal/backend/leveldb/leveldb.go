@@ -5,9 +5,12 @@ import (
 	"context"
 	"encoding/binary"
 	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/opt"
@@ -19,18 +22,63 @@ import (
 
 const (
 	routesDbFilename = "routes.db"
+	hitsDbFilename   = "hits.db"
+	clicksDbFilename = "clicks.db"
+	tokensDbFilename = "tokens.db"
+	auditDbFilename  = "audit.db"
 	idLogFilename    = "id"
+	formatFilename   = "format"
+
+	// Revisions and the trash used to be kept in databases of their own, which
+	// are moved in with the routes when they are found.
+	legacyHistoryDbFilename = "history.db"
+	legacyTrashDbFilename   = "trash.db"
+
+	// The number of records rewritten in each batch during an upgrade.
+	upgradeBatchSize = 1000
 )
 
 var _ backend.Backend = (*Backend)(nil)
 
+// Revisions and trashed routes are stored with the routes, so that each
+// change is a single write, under keys that begin with 0xff. As that byte never
+// appears in UTF-8, the keys can't be mistaken for the names of routes, and
+// they sort after all of them.
+const reservedKeyPrefix = "\xff"
+
+// The range of keys that are the names of routes.
+var routeKeys = &util.Range{Limit: []byte(reservedKeyPrefix)}
+
+// Returned when writing a route whose name collides with the reserved keys.
+var errReservedName = errors.New("route names cannot begin with 0xff")
+
+// Indicates whether the name can't be used for a route because it would
+// collide with the reserved keys.
+func isReservedName(name string) bool {
+	return strings.HasPrefix(name, reservedKeyPrefix)
+}
+
 // Backend provides access to the leveldb store.
 type Backend struct {
 	// Path contains the location on disk where this DB exists.
-	path string
-	db   *leveldb.DB
-	lck  sync.Mutex
-	id   uint64
+	path   string
+	db     *leveldb.DB
+	hits   *leveldb.DB
+	clicks *leveldb.DB
+	tokens *leveldb.DB
+	audit  *leveldb.DB
+	lck    sync.Mutex
+	id     uint64
+
+	// mlck serializes changes to routes so that revisions are recorded in the
+	// order the changes were made.
+	mlck sync.Mutex
+
+	// hlck serializes updates to the hit and click counts.
+	hlck sync.Mutex
+
+	// alck serializes appends to the audit log.
+	alck sync.Mutex
 }
 
 // Commit the given ID to the data store.
@@ -80,29 +128,176 @@ func New(path string) (*Backend, error) {
 		}
 	}
 
-	// open the database
-	db, err := leveldb.OpenFile(filepath.Join(backend.path, routesDbFilename), nil)
-	if err != nil {
-		return nil, err
+	// open the databases
+	dbs := []struct {
+		filename string
+		db       **leveldb.DB
+	}{
+		{routesDbFilename, &backend.db},
+		{hitsDbFilename, &backend.hits},
+		{clicksDbFilename, &backend.clicks},
+		{tokensDbFilename, &backend.tokens},
+		{auditDbFilename, &backend.audit},
+	}
+
+	for _, d := range dbs {
+		db, err := leveldb.OpenFile(filepath.Join(backend.path, d.filename), nil)
+		if err != nil {
+			backend.Close()
+			return nil, err
+		}
+		*d.db = db
 	}
-	backend.db = db
 
 	id, err := load(filepath.Join(backend.path, idLogFilename))
 	if err != nil {
+		backend.Close()
 		return nil, err
 	}
 	backend.id = id
 
+	for _, m := range []struct {
+		filename string
+		prefix   string
+	}{
+		{legacyHistoryDbFilename, historyKeyPrefix},
+		{legacyTrashDbFilename, trashKeyPrefix},
+	} {
+		if err := backend.migrate(m.filename, m.prefix); err != nil {
+			backend.Close()
+			return nil, err
+		}
+	}
+
+	if err := backend.upgrade(); err != nil {
+		backend.Close()
+		return nil, err
+	}
+
 	return &backend, nil
 }
 
+// Rewrite any routes stored in an older format using the current format. This
+// only scans the database once; afterwards, the format file records that all
+// routes are current.
+func (backend *Backend) upgrade() error {
+	filename := filepath.Join(backend.path, formatFilename)
+
+	version, err := load(filename)
+	if err != nil {
+		return err
+	}
+
+	if version >= uint64(internal.RouteVersion) {
+		return nil
+	}
+
+	iter := backend.db.NewIterator(routeKeys, nil)
+	defer iter.Release()
+
+	var batch leveldb.Batch
+	for iter.Next() {
+		if !internal.NeedsUpgrade(iter.Value()) {
+			continue
+		}
+
+		rt := &internal.Route{}
+		if err := rt.Read(bytes.NewBuffer(iter.Value())); err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := rt.Write(&buf); err != nil {
+			return err
+		}
+
+		batch.Put(iter.Key(), buf.Bytes())
+
+		if batch.Len() == upgradeBatchSize {
+			if err := backend.db.Write(&batch, &opt.WriteOptions{Sync: true}); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if err := backend.db.Write(&batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return err
+	}
+
+	return commit(filename, uint64(internal.RouteVersion))
+}
+
+// Move everything out of a database in which older stores kept revisions or
+// the trash and in with the routes, under the given prefix, so that a route
+// can be written together with them. The old database is removed once
+// everything has been moved.
+func (backend *Backend) migrate(name, prefix string) error {
+	filename := filepath.Join(backend.path, name)
+	if _, err := os.Stat(filename); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	db, err := leveldb.OpenFile(filename, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	var batch leveldb.Batch
+	for iter.Next() {
+		batch.Put(append([]byte(prefix), iter.Key()...), iter.Value())
+
+		if batch.Len() == upgradeBatchSize {
+			if err := backend.db.Write(&batch, &opt.WriteOptions{Sync: true}); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if err := backend.db.Write(&batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return err
+	}
+
+	iter.Release()
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(filename)
+}
+
 // Close the resources associated with this backend.
 func (backend *Backend) Close() error {
-	return backend.db.Close()
+	var errs []error
+	for _, db := range []*leveldb.DB{backend.audit, backend.tokens, backend.clicks, backend.hits, backend.db} {
+		if db != nil {
+			errs = append(errs, db.Close())
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // Get retreives a shortcut from the data store.
 func (backend *Backend) Get(ctx context.Context, name string) (*internal.Route, error) {
+	if isReservedName(name) {
+		return nil, internal.ErrRouteNotFound
+	}
+
 	val, err := backend.db.Get([]byte(name), nil)
 	if err != nil {
 		if errors.Is(err, leveldb.ErrNotFound) {
@@ -121,17 +316,168 @@ func (backend *Backend) Get(ctx context.Context, name string) (*internal.Route,
 
 // Put stores a new shortcut in the data store.
 func (backend *Backend) Put(ctx context.Context, key string, rt *internal.Route) error {
+	backend.mlck.Lock()
+	defer backend.mlck.Unlock()
+
+	old, err := backend.Get(ctx, key)
+	if err != nil && !errors.Is(err, internal.ErrRouteNotFound) {
+		return err
+	}
+
+	return backend.write(ctx, key, old, rt)
+}
+
+// PutIf stores the shortcut only if the existing shortcut is at revision rev.
+// It fails with internal.ErrRouteNotFound if there is no existing shortcut and
+// with internal.ErrRevisionMismatch if it has since changed.
+func (backend *Backend) PutIf(ctx context.Context, key string, rt *internal.Route, rev int64) error {
+	backend.mlck.Lock()
+	defer backend.mlck.Unlock()
+
+	old, err := backend.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if old.Revision != rev {
+		return internal.ErrRevisionMismatch
+	}
+
+	return backend.write(ctx, key, old, rt)
+}
+
+// Create stores the shortcut only if there is no existing shortcut with the
+// same name. It fails with internal.ErrRouteExists otherwise.
+func (backend *Backend) Create(ctx context.Context, key string, rt *internal.Route) error {
+	backend.mlck.Lock()
+	defer backend.mlck.Unlock()
+
+	if _, err := backend.Get(ctx, key); err == nil {
+		return internal.ErrRouteExists
+	} else if !errors.Is(err, internal.ErrRouteNotFound) {
+		return err
+	}
+
+	return backend.write(ctx, key, nil, rt)
+}
+
+// PutBatch stores all of the routes at once, doing what the policy says with
+// those that already exist. The routes and their revisions are written in a
+// single batch.
+func (backend *Backend) PutBatch(
+	ctx context.Context,
+	routes []*internal.NamedRoute,
+	policy internal.ConflictPolicy,
+) (*internal.BatchResult, error) {
+	for _, rt := range routes {
+		if isReservedName(rt.Name) {
+			return nil, errReservedName
+		}
+	}
+
+	backend.mlck.Lock()
+	defer backend.mlck.Unlock()
+
+	res, writes, err := internal.PlanBatch(routes, policy, func(name string) (*internal.Route, error) {
+		rt, err := backend.Get(ctx, name)
+		if errors.Is(err, internal.ErrRouteNotFound) {
+			return nil, nil
+		}
+		return rt, err
+	})
+	if err != nil {
+		return res, err
+	}
+
+	var batch leveldb.Batch
+	for _, w := range writes {
+		if err := backend.putRoute(ctx, &batch, w.Name, w.Old, w.Route); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := backend.db.Write(&batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Add rt as the named route, replacing old, to the batch along with the new
+// revision that records the change. The caller must hold backend.mlck.
+func (backend *Backend) putRoute(
+	ctx context.Context,
+	batch *leveldb.Batch,
+	key string,
+	old, rt *internal.Route,
+) error {
+	id, err := backend.lastRevisionID(key)
+	if err != nil {
+		return err
+	}
+
+	rt.Revision = id + 1
+
 	var buf bytes.Buffer
 	if err := rt.Write(&buf); err != nil {
 		return err
 	}
 
-	return backend.db.Put([]byte(key), buf.Bytes(), &opt.WriteOptions{Sync: true})
+	batch.Put([]byte(key), buf.Bytes())
+
+	return backend.record(ctx, batch, key, id, old, rt)
 }
 
-// Del removes an existing shortcut from the data store.
+// Write rt as the named route, replacing old, and record the change as a new
+// revision. The caller must hold backend.mlck.
+func (backend *Backend) write(ctx context.Context, key string, old, rt *internal.Route) error {
+	if isReservedName(key) {
+		return errReservedName
+	}
+
+	var batch leveldb.Batch
+	if err := backend.putRoute(ctx, &batch, key, old, rt); err != nil {
+		return err
+	}
+
+	return backend.db.Write(&batch, &opt.WriteOptions{Sync: true})
+}
+
+// Del moves an existing shortcut from the data store into the trash. The
+// deletion, the trash entry and the revision are written together.
 func (backend *Backend) Del(ctx context.Context, key string) error {
-	return backend.db.Delete([]byte(key), &opt.WriteOptions{Sync: true})
+	backend.mlck.Lock()
+	defer backend.mlck.Unlock()
+
+	old, err := backend.Get(ctx, key)
+	if errors.Is(err, internal.ErrRouteNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	id, err := backend.lastRevisionID(key)
+	if err != nil {
+		return err
+	}
+
+	var batch leveldb.Batch
+	batch.Delete([]byte(key))
+
+	if err := putTrash(&batch, &internal.TrashedRoute{
+		Name:      key,
+		Route:     old,
+		Deleted:   time.Now(),
+		DeletedBy: internal.ActorFrom(ctx),
+	}); err != nil {
+		return err
+	}
+
+	if err := backend.record(ctx, &batch, key, id, old, nil); err != nil {
+		return err
+	}
+
+	return backend.db.Write(&batch, &opt.WriteOptions{Sync: true})
 }
 
 // List all routes in an iterator, starting with the key prefix of start (which can also be nil).
@@ -139,15 +485,29 @@ func (backend *Backend) List(ctx context.Context, start string) (internal.RouteI
 	return &RouteIterator{
 		it: backend.db.NewIterator(&util.Range{
 			Start: []byte(start),
-			Limit: nil,
+			Limit: routeKeys.Limit,
 		}, nil),
 	}, nil
 }
 
+// Dump iterates over every route, in order of name, as they all were when
+// Dump was called.
+func (backend *Backend) Dump(ctx context.Context) (internal.RouteIterator, error) {
+	snap, err := backend.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RouteIterator{
+		it:   snap.NewIterator(routeKeys, nil),
+		snap: snap,
+	}, nil
+}
+
 // GetAll gets everything in the db to dump it out for backup purposes
 func (backend *Backend) GetAll(ctx context.Context) (map[string]internal.Route, error) {
 	golinks := map[string]internal.Route{}
-	iter := backend.db.NewIterator(nil, nil)
+	iter := backend.db.NewIterator(routeKeys, nil)
 	defer iter.Release()
 
 	for iter.Next() {
@@ -194,3 +554,30 @@ func (backend *Backend) NextID(ctx context.Context) (uint64, error) {
 
 	return backend.id, nil
 }
+
+// LastID returns the last ID generated by NextID, or 0 if there hasn't been
+// one.
+func (backend *Backend) LastID(ctx context.Context) (uint64, error) {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	return backend.id, nil
+}
+
+// AdvanceID ensures that NextID only generates IDs after id. It never moves
+// the counter backwards.
+func (backend *Backend) AdvanceID(ctx context.Context, id uint64) error {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	if id <= backend.id {
+		return nil
+	}
+
+	if err := commit(filepath.Join(backend.path, idLogFilename), id); err != nil {
+		return err
+	}
+
+	backend.id = id
+	return nil
+}
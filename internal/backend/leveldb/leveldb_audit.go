@@ -0,0 +1,89 @@
+package leveldb
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb/opt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Audit events are keyed by a big-endian sequence number so that they sort in
+// the order they were appended.
+func (backend *Backend) lastAuditSeq() (uint64, error) {
+	iter := backend.audit.NewIterator(nil, nil)
+	defer iter.Release()
+
+	if iter.Last() {
+		return binary.BigEndian.Uint64(iter.Key()), nil
+	}
+
+	return 0, iter.Error()
+}
+
+// AppendAudit appends the event to the audit log, assigning its ID.
+func (backend *Backend) AppendAudit(ctx context.Context, event *internal.AuditEvent) error {
+	backend.alck.Lock()
+	defer backend.alck.Unlock()
+
+	seq, err := backend.lastAuditSeq()
+	if err != nil {
+		return err
+	}
+	seq++
+
+	e := *event
+	e.ID = fmt.Sprintf("%020d", seq)
+
+	b, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+
+	if err := backend.audit.Put(
+		binary.BigEndian.AppendUint64(nil, seq),
+		b,
+		&opt.WriteOptions{Sync: true},
+	); err != nil {
+		return err
+	}
+
+	event.ID = e.ID
+	return nil
+}
+
+// ListAudit returns the events selected by the filter in the order they were
+// appended.
+func (backend *Backend) ListAudit(
+	ctx context.Context,
+	filter *internal.AuditFilter,
+) ([]*internal.AuditEvent, error) {
+	iter := backend.audit.NewIterator(nil, nil)
+	defer iter.Release()
+
+	var res []*internal.AuditEvent
+	for iter.Next() {
+		var e internal.AuditEvent
+		if err := json.Unmarshal(iter.Value(), &e); err != nil {
+			return nil, err
+		}
+
+		if !filter.Matches(&e) {
+			continue
+		}
+
+		res = append(res, &e)
+		if filter.Limit > 0 && len(res) >= filter.Limit {
+			break
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
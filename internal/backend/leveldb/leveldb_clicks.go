@@ -0,0 +1,82 @@
+package leveldb
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Clicks are keyed by the route's name, a zero byte and the big-endian start
+// of the bucket in seconds so that the buckets of a route sort by time.
+func clicksKey(name string, start time.Time) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte(name), 0), uint64(start.Unix()))
+}
+
+// AddClicks adds the given visits to the stored buckets of each route.
+func (backend *Backend) AddClicks(ctx context.Context, clicks map[string][]*internal.Clicks) error {
+	backend.hlck.Lock()
+	defer backend.hlck.Unlock()
+
+	var batch leveldb.Batch
+	for name, cs := range clicks {
+		for _, c := range cs {
+			key := clicksKey(name, c.Start)
+
+			cur := internal.Clicks{
+				Start: c.Start,
+			}
+
+			val, err := backend.clicks.Get(key, nil)
+			if err == nil {
+				if err := json.Unmarshal(val, &cur); err != nil {
+					return err
+				}
+			} else if !errors.Is(err, leveldb.ErrNotFound) {
+				return err
+			}
+
+			cur.Add(c)
+
+			b, err := json.Marshal(&cur)
+			if err != nil {
+				return err
+			}
+			batch.Put(key, b)
+		}
+	}
+
+	return backend.clicks.Write(&batch, &opt.WriteOptions{Sync: true})
+}
+
+// GetClicks returns the stored buckets of the named route that start in the
+// range [from, to), in order of time.
+func (backend *Backend) GetClicks(ctx context.Context, name string, from, to time.Time) ([]*internal.Clicks, error) {
+	iter := backend.clicks.NewIterator(&util.Range{
+		Start: clicksKey(name, from),
+		Limit: clicksKey(name, to),
+	}, nil)
+	defer iter.Release()
+
+	var res []*internal.Clicks
+	for iter.Next() {
+		var c internal.Clicks
+		if err := json.Unmarshal(iter.Value(), &c); err != nil {
+			return nil, err
+		}
+		res = append(res, &c)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
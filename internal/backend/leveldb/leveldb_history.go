@@ -0,0 +1,115 @@
+package leveldb
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/kellegous/go/internal"
+)
+
+// The prefix of the keys of revisions.
+const historyKeyPrefix = reservedKeyPrefix + "h"
+
+// The revisions of a route are keyed by the history prefix, the route's name,
+// a zero byte and the big-endian revision ID so that they sort in order of ID.
+func historyPrefix(name string) []byte {
+	return append([]byte(historyKeyPrefix+name), 0)
+}
+
+func historyKey(name string, id int64) []byte {
+	return binary.BigEndian.AppendUint64(historyPrefix(name), uint64(id))
+}
+
+// Indicates whether key is the key of a revision of the route whose prefix is
+// given. This guards against names that themselves contain a zero byte.
+func isHistoryKey(prefix, key []byte) bool {
+	return len(key) == len(prefix)+8
+}
+
+// Find the ID of the most recent revision of the named route, or 0 if there
+// are none.
+func (backend *Backend) lastRevisionID(name string) (int64, error) {
+	prefix := historyPrefix(name)
+	iter := backend.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	for ok := iter.Last(); ok; ok = iter.Prev() {
+		if isHistoryKey(prefix, iter.Key()) {
+			return int64(binary.BigEndian.Uint64(iter.Key()[len(prefix):])), nil
+		}
+	}
+
+	return 0, iter.Error()
+}
+
+// Encode the revision that changes a route from old to rt, following the
+// revision with the given ID. Either route may be nil to indicate a creation or
+// a deletion.
+func encodeRevision(ctx context.Context, id int64, old, rt *internal.Route) ([]byte, error) {
+	rev := internal.Revision{
+		ID:    id + 1,
+		Time:  time.Now(),
+		By:    internal.ActorFrom(ctx),
+		Route: rt,
+	}
+
+	if old != nil {
+		rev.OldURL = old.URL
+	}
+
+	if rt != nil {
+		rev.NewURL = rt.URL
+	}
+
+	return json.Marshal(&rev)
+}
+
+// Record a change to the named route from old to rt in the batch that makes
+// the change, following the revision with the given ID. Either route may be nil
+// to indicate a creation or a deletion. The caller must hold backend.mlck.
+func (backend *Backend) record(
+	ctx context.Context,
+	batch *leveldb.Batch,
+	name string,
+	id int64,
+	old, rt *internal.Route,
+) error {
+	b, err := encodeRevision(ctx, id, old, rt)
+	if err != nil {
+		return err
+	}
+
+	batch.Put(historyKey(name, id+1), b)
+	return nil
+}
+
+// History returns the revisions of the named route, oldest first.
+func (backend *Backend) History(ctx context.Context, name string) ([]*internal.Revision, error) {
+	prefix := historyPrefix(name)
+	iter := backend.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	var revs []*internal.Revision
+	for iter.Next() {
+		if !isHistoryKey(prefix, iter.Key()) {
+			continue
+		}
+
+		var rev internal.Revision
+		if err := json.Unmarshal(iter.Value(), &rev); err != nil {
+			return nil, err
+		}
+		revs = append(revs, &rev)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return revs, nil
+}
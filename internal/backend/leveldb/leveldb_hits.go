@@ -0,0 +1,101 @@
+package leveldb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Hits are stored as a little-endian count followed by the time of the last
+// visit in nanoseconds.
+func encodeHits(h *internal.Hits) []byte {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint64(b, uint64(h.Count))
+	binary.LittleEndian.PutUint64(b[8:], uint64(h.Last.UnixNano()))
+	return b
+}
+
+func decodeHits(b []byte) (*internal.Hits, error) {
+	if len(b) != 16 {
+		return nil, errors.New("invalid hits record")
+	}
+
+	return &internal.Hits{
+		Count: int64(binary.LittleEndian.Uint64(b)),
+		Last:  time.Unix(0, int64(binary.LittleEndian.Uint64(b[8:]))),
+	}, nil
+}
+
+func (backend *Backend) getHits(name string) (*internal.Hits, error) {
+	val, err := backend.hits.Get([]byte(name), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return decodeHits(val)
+}
+
+// AddHits adds the given visits to the totals of each route.
+func (backend *Backend) AddHits(ctx context.Context, hits map[string]*internal.Hits) error {
+	backend.hlck.Lock()
+	defer backend.hlck.Unlock()
+
+	var batch leveldb.Batch
+	for name, h := range hits {
+		cur, err := backend.getHits(name)
+		if err != nil {
+			return err
+		} else if cur == nil {
+			cur = &internal.Hits{}
+		}
+
+		cur.Add(h)
+		batch.Put([]byte(name), encodeHits(cur))
+	}
+
+	return backend.hits.Write(&batch, &opt.WriteOptions{Sync: true})
+}
+
+// GetHits returns the visits to each of the named routes. Routes that have
+// never been visited are omitted.
+func (backend *Backend) GetHits(ctx context.Context, names []string) (map[string]*internal.Hits, error) {
+	res := map[string]*internal.Hits{}
+	for _, name := range names {
+		h, err := backend.getHits(name)
+		if err != nil {
+			return nil, err
+		} else if h != nil {
+			res[name] = h
+		}
+	}
+	return res, nil
+}
+
+// GetAllHits returns the visits to every route that has been visited.
+func (backend *Backend) GetAllHits(ctx context.Context) (map[string]*internal.Hits, error) {
+	iter := backend.hits.NewIterator(nil, nil)
+	defer iter.Release()
+
+	res := map[string]*internal.Hits{}
+	for iter.Next() {
+		h, err := decodeHits(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		res[string(iter.Key())] = h
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
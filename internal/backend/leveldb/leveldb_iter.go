@@ -4,12 +4,14 @@ import (
 	"bytes"
 
 	"github.com/kellegous/go/internal"
+	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 )
 
 // RouteIterator allows iteration of the named routes in the store.
 type RouteIterator struct {
 	it   iterator.Iterator
+	snap *leveldb.Snapshot
 	name string
 	rt   *internal.Route
 	err  error
@@ -88,4 +90,7 @@ func (i *RouteIterator) Route() *internal.Route {
 // Release disposes of the resources in the iterator.
 func (i *RouteIterator) Release() {
 	i.it.Release()
+	if i.snap != nil {
+		i.snap.Release()
+	}
 }
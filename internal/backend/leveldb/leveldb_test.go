@@ -1,17 +1,37 @@
 package leveldb
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/syndtr/goleveldb/leveldb"
+
 	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+	"github.com/kellegous/go/internal/backend/backendtest"
 )
 
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) backend.Backend {
+		backend, err := New(filepath.Join(t.TempDir(), "data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return backend
+	})
+}
+
 func TestGetPut(t *testing.T) {
 	tmp, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -212,3 +232,279 @@ func TestList(t *testing.T) {
 	}
 	mustBeIterOf(t, iter)
 }
+
+func TestUpgrade(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	path := filepath.Join(tmp, "data")
+
+	backend, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// write records the way they were written before routes were versioned.
+	for _, name := range []string{"a", "b"} {
+		var buf bytes.Buffer
+		if err := binary.Write(&buf, binary.LittleEndian, int64(420)); err != nil {
+			t.Fatal(err)
+		}
+		buf.WriteString(fmt.Sprintf("http://%s/", name))
+
+		if err := backend.db.Put([]byte(name), buf.Bytes(), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(path, formatFilename)); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err = New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for _, name := range []string{"a", "b"} {
+		val, err := backend.db.Get([]byte(name), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if internal.NeedsUpgrade(val) {
+			t.Fatalf("expected %s to have been upgraded", name)
+		}
+
+		rt, err := backend.Get(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rt.URL != fmt.Sprintf("http://%s/", name) {
+			t.Fatalf("expected URL of http://%s/, got %s", name, rt.URL)
+		}
+
+		if !rt.Time.Equal(time.Unix(0, 420)) {
+			t.Fatalf("expected Time of %s, got %s", time.Unix(0, 420), rt.Time)
+		}
+	}
+}
+
+func TestReservedKeys(t *testing.T) {
+	backend, err := New(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := putRoutes(ctx, backend, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backend.Del(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the revisions and the trash are stored with the routes, but aren't
+	// routes.
+	iter, err := backend.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Release()
+
+	var names []string
+	for iter.Next() {
+		names = append(names, iter.Name())
+	}
+
+	if err := iter.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"b"}) {
+		t.Fatalf("expected only b, got %q", names)
+	}
+
+	for _, name := range []string{"\xffa", historyKeyPrefix + "b", trashKeyPrefix + "a"} {
+		if err := backend.Put(ctx, name, &internal.Route{
+			URL:  "http://a/",
+			Time: time.Now(),
+		}); !errors.Is(err, errReservedName) {
+			t.Fatalf("expected errReservedName for %q, got %v", name, err)
+		}
+
+		if _, err := backend.Get(ctx, name); !errors.Is(err, internal.ErrRouteNotFound) {
+			t.Fatalf("expected ErrRouteNotFound for %q, got %v", name, err)
+		}
+	}
+}
+
+func TestHistoryMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+
+	backend, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := backend.Put(ctx, "a", &internal.Route{
+		URL:  "http://b/",
+		Time: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// move the revision into a database of its own, where it used to be kept,
+	// next to an older one.
+	history, err := leveldb.OpenFile(filepath.Join(path, legacyHistoryDbFilename), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, u := range []string{"http://a/", "http://b/"} {
+		b, err := json.Marshal(&internal.Revision{
+			ID:     int64(id + 1),
+			NewURL: u,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := history.Put(historyKey("a", int64(id+1))[len(historyKeyPrefix):], b, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := history.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backend.db.Delete(historyKey("a", 1), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err = New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	if _, err := os.Stat(filepath.Join(path, legacyHistoryDbFilename)); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected %s to be removed, got %v", legacyHistoryDbFilename, err)
+	}
+
+	revs, err := backend.History(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revs) != 2 || revs[0].NewURL != "http://a/" || revs[1].NewURL != "http://b/" {
+		t.Fatalf("unexpected revisions: %v", revs)
+	}
+
+	if err := backend.Put(ctx, "a", &internal.Route{
+		URL:  "http://c/",
+		Time: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rt, err := backend.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.Revision != 3 {
+		t.Fatalf("expected revision 3, got %d", rt.Revision)
+	}
+}
+
+func TestTrashMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+
+	backend, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// put a deleted route into a database of its own, where it used to be
+	// kept.
+	trash, err := leveldb.OpenFile(filepath.Join(path, legacyTrashDbFilename), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(&internal.TrashedRoute{
+		Name:    "a",
+		Route:   &internal.Route{URL: "http://a/", Time: time.Now()},
+		Deleted: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := trash.Put([]byte("a"), b, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := trash.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err = New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	if _, err := os.Stat(filepath.Join(path, legacyTrashDbFilename)); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected %s to be removed, got %v", legacyTrashDbFilename, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	rt, err := backend.Restore(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.URL != "http://a/" {
+		t.Fatalf("expected url of http://a/, got %s", rt.URL)
+	}
+
+	if _, err := backend.Get(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := backend.GetTrash(ctx, "a"); !errors.Is(err, internal.ErrRouteNotFound) {
+		t.Fatalf("expected ErrRouteNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package leveldb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// PutToken stores the API token, replacing any token with the same ID.
+func (backend *Backend) PutToken(ctx context.Context, token *internal.Token) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return backend.tokens.Put([]byte(token.ID), b, &opt.WriteOptions{Sync: true})
+}
+
+// GetToken retrieves the API token with the given ID.
+func (backend *Backend) GetToken(ctx context.Context, id string) (*internal.Token, error) {
+	val, err := backend.tokens.Get([]byte(id), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, internal.ErrTokenNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var t internal.Token
+	if err := json.Unmarshal(val, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTokens returns the API tokens that belong to owner, or all tokens if
+// owner is empty, ordered by ID.
+func (backend *Backend) ListTokens(ctx context.Context, owner string) ([]*internal.Token, error) {
+	it := backend.tokens.NewIterator(nil, nil)
+	defer it.Release()
+
+	var res []*internal.Token
+	for it.Next() {
+		var t internal.Token
+		if err := json.Unmarshal(it.Value(), &t); err != nil {
+			return nil, err
+		}
+
+		if owner == "" || t.Owner == owner {
+			res = append(res, &t)
+		}
+	}
+
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DelToken revokes the API token with the given ID.
+func (backend *Backend) DelToken(ctx context.Context, id string) error {
+	return backend.tokens.Delete([]byte(id), &opt.WriteOptions{Sync: true})
+}
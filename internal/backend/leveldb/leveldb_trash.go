@@ -0,0 +1,143 @@
+package leveldb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/kellegous/go/internal"
+)
+
+// The prefix of the keys of trashed routes, which are followed by the name of
+// the route.
+const trashKeyPrefix = reservedKeyPrefix + "t"
+
+func trashKey(name string) []byte {
+	return []byte(trashKeyPrefix + name)
+}
+
+// Add the deleted route to the batch that deletes it, replacing any earlier
+// deletion of a route with the same name.
+func putTrash(batch *leveldb.Batch, t *internal.TrashedRoute) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	batch.Put(trashKey(t.Name), b)
+	return nil
+}
+
+// ListTrash returns the deleted routes that can still be restored, ordered by
+// name.
+func (backend *Backend) ListTrash(ctx context.Context) ([]*internal.TrashedRoute, error) {
+	iter := backend.db.NewIterator(util.BytesPrefix([]byte(trashKeyPrefix)), nil)
+	defer iter.Release()
+
+	var res []*internal.TrashedRoute
+	for iter.Next() {
+		var t internal.TrashedRoute
+		if err := json.Unmarshal(iter.Value(), &t); err != nil {
+			return nil, err
+		}
+		res = append(res, &t)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetTrash returns the deleted route with the given name if it can still be
+// restored. It fails with internal.ErrRouteNotFound otherwise.
+func (backend *Backend) GetTrash(ctx context.Context, name string) (*internal.TrashedRoute, error) {
+	val, err := backend.db.Get(trashKey(name), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, internal.ErrRouteNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var t internal.TrashedRoute
+	if err := json.Unmarshal(val, &t); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Restore moves the named route out of the trash, returning it as it was when
+// it was deleted. It fails with internal.ErrRouteExists if another route with
+// the same name has been created since.
+func (backend *Backend) Restore(ctx context.Context, name string) (*internal.Route, error) {
+	backend.mlck.Lock()
+	defer backend.mlck.Unlock()
+
+	t, err := backend.GetTrash(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := backend.Get(ctx, name); err == nil {
+		return nil, internal.ErrRouteExists
+	} else if !errors.Is(err, internal.ErrRouteNotFound) {
+		return nil, err
+	}
+
+	var batch leveldb.Batch
+	if err := backend.putRoute(ctx, &batch, name, nil, t.Route); err != nil {
+		return nil, err
+	}
+	batch.Delete(trashKey(name))
+
+	if err := backend.db.Write(&batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return nil, err
+	}
+
+	return t.Route, nil
+}
+
+// PurgeTrash permanently removes the routes that were deleted before the
+// given time, returning the names of those that were removed in order.
+func (backend *Backend) PurgeTrash(ctx context.Context, before time.Time) ([]string, error) {
+	backend.mlck.Lock()
+	defer backend.mlck.Unlock()
+
+	iter := backend.db.NewIterator(util.BytesPrefix([]byte(trashKeyPrefix)), nil)
+	defer iter.Release()
+
+	var batch leveldb.Batch
+	var names []string
+	for iter.Next() {
+		var t internal.TrashedRoute
+		if err := json.Unmarshal(iter.Value(), &t); err != nil {
+			return nil, err
+		}
+
+		if t.Deleted.Before(before) {
+			batch.Delete(trashKey(t.Name))
+			names = append(names, t.Name)
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	if err := backend.db.Write(&batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
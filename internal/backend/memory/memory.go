@@ -0,0 +1,247 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+)
+
+var _ backend.Backend = (*Backend)(nil)
+
+// Backend keeps everything in memory, so it is lost when the process exits.
+// It is meant for tests and for demo instances.
+type Backend struct {
+	lck sync.RWMutex
+
+	routes  map[string]*internal.Route
+	history map[string][]*internal.Revision
+	hits    map[string]*internal.Hits
+
+	// clicks are keyed by route name and then the start of the bucket in
+	// seconds.
+	clicks map[string]map[int64]*internal.Clicks
+
+	tokens map[string]*internal.Token
+	audit  []*internal.AuditEvent
+	trash  map[string]*internal.TrashedRoute
+	id     uint64
+}
+
+// New instantiates a new, empty Backend.
+func New() *Backend {
+	return &Backend{
+		routes:  map[string]*internal.Route{},
+		history: map[string][]*internal.Revision{},
+		hits:    map[string]*internal.Hits{},
+		clicks:  map[string]map[int64]*internal.Clicks{},
+		tokens:  map[string]*internal.Token{},
+		trash:   map[string]*internal.TrashedRoute{},
+	}
+}
+
+// Copy the route so that callers can't change what is stored.
+func cloneRoute(rt *internal.Route) *internal.Route {
+	if rt == nil {
+		return nil
+	}
+
+	c := *rt
+	c.CoOwners = slices.Clone(rt.CoOwners)
+	c.Tags = slices.Clone(rt.Tags)
+	return &c
+}
+
+// Close the resources associated with this backend.
+func (backend *Backend) Close() error {
+	return nil
+}
+
+// Get retreives a shortcut from the data store.
+func (backend *Backend) Get(ctx context.Context, name string) (*internal.Route, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	rt, ok := backend.routes[name]
+	if !ok {
+		return nil, internal.ErrRouteNotFound
+	}
+
+	return cloneRoute(rt), nil
+}
+
+// Put stores a new shortcut in the data store.
+func (backend *Backend) Put(ctx context.Context, key string, rt *internal.Route) error {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	backend.write(ctx, key, backend.routes[key], rt)
+	return nil
+}
+
+// PutIf stores the shortcut only if the existing shortcut is at revision rev.
+// It fails with internal.ErrRouteNotFound if there is no existing shortcut and
+// with internal.ErrRevisionMismatch if it has since changed.
+func (backend *Backend) PutIf(ctx context.Context, key string, rt *internal.Route, rev int64) error {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	old, ok := backend.routes[key]
+	if !ok {
+		return internal.ErrRouteNotFound
+	}
+
+	if old.Revision != rev {
+		return internal.ErrRevisionMismatch
+	}
+
+	backend.write(ctx, key, old, rt)
+	return nil
+}
+
+// Create stores the shortcut only if there is no existing shortcut with the
+// same name. It fails with internal.ErrRouteExists otherwise.
+func (backend *Backend) Create(ctx context.Context, key string, rt *internal.Route) error {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	if _, ok := backend.routes[key]; ok {
+		return internal.ErrRouteExists
+	}
+
+	backend.write(ctx, key, nil, rt)
+	return nil
+}
+
+// PutBatch stores all of the routes at once, doing what the policy says with
+// those that already exist.
+func (backend *Backend) PutBatch(
+	ctx context.Context,
+	routes []*internal.NamedRoute,
+	policy internal.ConflictPolicy,
+) (*internal.BatchResult, error) {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	res, writes, err := internal.PlanBatch(routes, policy, func(name string) (*internal.Route, error) {
+		return backend.routes[name], nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	for _, w := range writes {
+		backend.write(ctx, w.Name, w.Old, w.Route)
+	}
+
+	return res, nil
+}
+
+// Write rt as the named route, replacing old, and record the change as a new
+// revision. The caller must hold backend.lck.
+func (backend *Backend) write(ctx context.Context, key string, old, rt *internal.Route) {
+	rt.Revision = backend.lastRevisionID(key) + 1
+	backend.routes[key] = cloneRoute(rt)
+	backend.record(ctx, key, old, rt)
+}
+
+// Del moves an existing shortcut from the data store into the trash.
+func (backend *Backend) Del(ctx context.Context, key string) error {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	old, ok := backend.routes[key]
+	if !ok {
+		return nil
+	}
+
+	backend.trash[key] = &internal.TrashedRoute{
+		Name:      key,
+		Route:     old,
+		Deleted:   time.Now(),
+		DeletedBy: internal.ActorFrom(ctx),
+	}
+
+	delete(backend.routes, key)
+	backend.record(ctx, key, old, nil)
+	return nil
+}
+
+// List all routes in an iterator, starting with the key prefix of start (which can also be nil).
+// The iterator sees the routes as they were when it was created.
+func (backend *Backend) List(ctx context.Context, start string) (internal.RouteIterator, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	names := make([]string, 0, len(backend.routes))
+	for name := range backend.routes {
+		if name >= start {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	routes := make([]*internal.Route, 0, len(names))
+	for _, name := range names {
+		routes = append(routes, cloneRoute(backend.routes[name]))
+	}
+
+	return &RouteIterator{
+		names:  names,
+		routes: routes,
+		idx:    -1,
+	}, nil
+}
+
+// Dump iterates over every route, in order of name, as they all were when
+// Dump was called.
+func (backend *Backend) Dump(ctx context.Context) (internal.RouteIterator, error) {
+	return backend.List(ctx, "")
+}
+
+// GetAll gets everything in the db to dump it out for backup purposes
+func (backend *Backend) GetAll(ctx context.Context) (map[string]internal.Route, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	golinks := make(map[string]internal.Route, len(backend.routes))
+	for name, rt := range backend.routes {
+		golinks[name] = *cloneRoute(rt)
+	}
+
+	return golinks, nil
+}
+
+// NextID generates the next numeric ID to be used for an auto-named shortcut.
+func (backend *Backend) NextID(ctx context.Context) (uint64, error) {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	backend.id++
+	return backend.id, nil
+}
+
+// LastID returns the last ID generated by NextID, or 0 if there hasn't been
+// one.
+func (backend *Backend) LastID(ctx context.Context) (uint64, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	return backend.id, nil
+}
+
+// AdvanceID ensures that NextID only generates IDs after id. It never moves
+// the counter backwards.
+func (backend *Backend) AdvanceID(ctx context.Context, id uint64) error {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	if id > backend.id {
+		backend.id = id
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// AppendAudit appends the event to the audit log, assigning its ID.
+func (backend *Backend) AppendAudit(ctx context.Context, event *internal.AuditEvent) error {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	e := *event
+	e.ID = fmt.Sprintf("%020d", len(backend.audit)+1)
+	backend.audit = append(backend.audit, &e)
+
+	event.ID = e.ID
+	return nil
+}
+
+// ListAudit returns the events selected by the filter in the order they were
+// appended.
+func (backend *Backend) ListAudit(
+	ctx context.Context,
+	filter *internal.AuditFilter,
+) ([]*internal.AuditEvent, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	var res []*internal.AuditEvent
+	for _, e := range backend.audit {
+		if !filter.Matches(e) {
+			continue
+		}
+
+		c := *e
+		res = append(res, &c)
+		if filter.Limit > 0 && len(res) >= filter.Limit {
+			break
+		}
+	}
+
+	return res, nil
+}
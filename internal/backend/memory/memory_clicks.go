@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Copy the counts so that callers can't change what is stored.
+func cloneClicks(c *internal.Clicks) *internal.Clicks {
+	r := *c
+	r.Referrers = maps.Clone(c.Referrers)
+	r.Agents = maps.Clone(c.Agents)
+	return &r
+}
+
+// AddClicks adds the given visits to the stored buckets of each route.
+func (backend *Backend) AddClicks(ctx context.Context, clicks map[string][]*internal.Clicks) error {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	for name, cs := range clicks {
+		buckets, ok := backend.clicks[name]
+		if !ok {
+			buckets = map[int64]*internal.Clicks{}
+			backend.clicks[name] = buckets
+		}
+
+		for _, c := range cs {
+			key := c.Start.Unix()
+
+			cur, ok := buckets[key]
+			if !ok {
+				cur = &internal.Clicks{
+					Start: c.Start,
+				}
+				buckets[key] = cur
+			}
+
+			cur.Add(c)
+		}
+	}
+
+	return nil
+}
+
+// GetClicks returns the stored buckets of the named route that start in the
+// range [from, to), in order of time.
+func (backend *Backend) GetClicks(ctx context.Context, name string, from, to time.Time) ([]*internal.Clicks, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	var res []*internal.Clicks
+	for key, c := range backend.clicks[name] {
+		if key >= from.Unix() && key < to.Unix() {
+			res = append(res, cloneClicks(c))
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Start.Before(res[j].Start)
+	})
+
+	return res, nil
+}
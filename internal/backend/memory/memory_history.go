@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Find the ID of the most recent revision of the named route, or 0 if there
+// are none. The caller must hold backend.lck.
+func (backend *Backend) lastRevisionID(name string) int64 {
+	revs := backend.history[name]
+	if len(revs) == 0 {
+		return 0
+	}
+	return revs[len(revs)-1].ID
+}
+
+// Record a change to the named route from old to rt. Either may be nil to
+// indicate a creation or a deletion. The caller must hold backend.lck.
+func (backend *Backend) record(ctx context.Context, name string, old, rt *internal.Route) {
+	rev := &internal.Revision{
+		ID:    backend.lastRevisionID(name) + 1,
+		Time:  time.Now(),
+		By:    internal.ActorFrom(ctx),
+		Route: cloneRoute(rt),
+	}
+
+	if old != nil {
+		rev.OldURL = old.URL
+	}
+
+	if rt != nil {
+		rev.NewURL = rt.URL
+	}
+
+	backend.history[name] = append(backend.history[name], rev)
+}
+
+// History returns the revisions of the named route, oldest first.
+func (backend *Backend) History(ctx context.Context, name string) ([]*internal.Revision, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	var revs []*internal.Revision
+	for _, rev := range backend.history[name] {
+		r := *rev
+		r.Route = cloneRoute(rev.Route)
+		revs = append(revs, &r)
+	}
+
+	return revs, nil
+}
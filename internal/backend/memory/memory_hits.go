@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/kellegous/go/internal"
+)
+
+// AddHits adds the given visits to the totals of each route.
+func (backend *Backend) AddHits(ctx context.Context, hits map[string]*internal.Hits) error {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	for name, h := range hits {
+		cur, ok := backend.hits[name]
+		if !ok {
+			cur = &internal.Hits{}
+			backend.hits[name] = cur
+		}
+		cur.Add(h)
+	}
+
+	return nil
+}
+
+// GetHits returns the visits to each of the named routes. Routes that have
+// never been visited are omitted.
+func (backend *Backend) GetHits(ctx context.Context, names []string) (map[string]*internal.Hits, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	res := map[string]*internal.Hits{}
+	for _, name := range names {
+		if h, ok := backend.hits[name]; ok {
+			c := *h
+			res[name] = &c
+		}
+	}
+	return res, nil
+}
+
+// GetAllHits returns the visits to every route that has been visited.
+func (backend *Backend) GetAllHits(ctx context.Context) (map[string]*internal.Hits, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	res := make(map[string]*internal.Hits, len(backend.hits))
+	for name, h := range backend.hits {
+		c := *h
+		res[name] = &c
+	}
+	return res, nil
+}
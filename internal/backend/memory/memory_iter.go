@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/kellegous/go/internal"
+)
+
+// RouteIterator allows iteration of the named routes in memory, in order of
+// name.
+type RouteIterator struct {
+	names  []string
+	routes []*internal.Route
+	idx    int
+}
+
+// Valid indicates whether the current values of the iterator are valid.
+func (i *RouteIterator) Valid() bool {
+	return i.idx >= 0 && i.idx < len(i.names)
+}
+
+// Next advances the iterator to the next value.
+func (i *RouteIterator) Next() bool {
+	if i.idx < len(i.names) {
+		i.idx++
+	}
+	return i.Valid()
+}
+
+// Seek moves the iterator to the first route whose name is at or after cur.
+func (i *RouteIterator) Seek(cur string) bool {
+	i.idx = sort.SearchStrings(i.names, cur)
+	return i.Valid()
+}
+
+// Error returns any active error that has stopped the iterator.
+func (i *RouteIterator) Error() error {
+	return nil
+}
+
+// Name is the name of the current route.
+func (i *RouteIterator) Name() string {
+	if !i.Valid() {
+		return ""
+	}
+	return i.names[i.idx]
+}
+
+// Route is the current route.
+func (i *RouteIterator) Route() *internal.Route {
+	if !i.Valid() {
+		return nil
+	}
+	return cloneRoute(i.routes[i.idx])
+}
+
+// Release disposes of the resources in the iterator.
+func (i *RouteIterator) Release() {
+	i.names = nil
+	i.routes = nil
+	i.idx = 0
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/kellegous/go/internal"
+)
+
+// A dump in the form returned by /api/urls/, as used by dump-loader.
+type listDump struct {
+	Ok     *bool `json:"ok"`
+	Routes []*struct {
+		Name string `json:"name"`
+		*internal.Route
+	} `json:"routes"`
+}
+
+// Load seeds the backend with the routes in a dump, which is either the
+// object of routes keyed by name returned by /admin/dumps or the list of
+// routes returned by /api/urls/. Loaded routes replace any existing routes
+// with the same names.
+func (backend *Backend) Load(r io.Reader) error {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return err
+	}
+
+	routes := map[string]*internal.Route{}
+
+	var ld listDump
+	if err := json.Unmarshal(raw, &ld); err == nil && ld.Ok != nil {
+		for _, rt := range ld.Routes {
+			if rt.Route != nil {
+				routes[rt.Name] = rt.Route
+			}
+		}
+	} else if err := json.Unmarshal(raw, &routes); err != nil {
+		return err
+	}
+
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	ctx := context.Background()
+	for name, rt := range routes {
+		backend.write(ctx, name, backend.routes[name], rt)
+	}
+
+	return nil
+}
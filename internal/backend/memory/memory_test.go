@@ -0,0 +1,239 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+	"github.com/kellegous/go/internal/backend/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) backend.Backend {
+		return New()
+	})
+}
+
+func putRoutes(ctx context.Context, backend *Backend, names ...string) error {
+	for _, name := range names {
+		if err := backend.Put(ctx, name, &internal.Route{
+			URL:  "http://" + name + "/",
+			Time: time.Now(),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func namesOf(iter internal.RouteIterator) []string {
+	var names []string
+	for iter.Next() {
+		names = append(names, iter.Name())
+	}
+	return names
+}
+
+func TestGetPut(t *testing.T) {
+	backend := New()
+	defer backend.Close()
+
+	ctx := context.Background()
+
+	if _, err := backend.Get(ctx, "not_found"); !errors.Is(err, internal.ErrRouteNotFound) {
+		t.Fatalf("expected ErrRouteNotFound, got %v", err)
+	}
+
+	a := &internal.Route{
+		URL:  "http://www.kellegous.com/",
+		Time: time.Now(),
+		Tags: []string{"blog"},
+	}
+
+	if err := backend.Put(ctx, "key", a); err != nil {
+		t.Fatal(err)
+	}
+
+	// changing the route after it has been stored doesn't change the store.
+	a.Tags[0] = "changed"
+
+	b, err := backend.Get(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.URL != a.URL || !b.Time.Equal(a.Time) || b.Revision != 1 {
+		t.Fatalf("expected %v, got %v", a, b)
+	}
+
+	if !reflect.DeepEqual(b.Tags, []string{"blog"}) {
+		t.Fatalf("expected tags of [blog], got %v", b.Tags)
+	}
+}
+
+func TestList(t *testing.T) {
+	backend := New()
+	defer backend.Close()
+
+	ctx := context.Background()
+
+	if err := putRoutes(ctx, backend, "c", "a", "e", "b", "d"); err != nil {
+		t.Fatal(err)
+	}
+
+	iter, err := backend.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Release()
+
+	if iter.Valid() {
+		t.Fatal("expected iterator to be invalid before Next")
+	}
+
+	if names := namesOf(iter); !reflect.DeepEqual(names, []string{"a", "b", "c", "d", "e"}) {
+		t.Fatalf("expected routes in order, got %v", names)
+	}
+
+	iter, err = backend.List(ctx, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Release()
+
+	if names := namesOf(iter); !reflect.DeepEqual(names, []string{"c", "d", "e"}) {
+		t.Fatalf("expected routes from c, got %v", names)
+	}
+}
+
+func TestSeek(t *testing.T) {
+	backend := New()
+	defer backend.Close()
+
+	ctx := context.Background()
+
+	if err := putRoutes(ctx, backend, "a", "c", "e"); err != nil {
+		t.Fatal(err)
+	}
+
+	iter, err := backend.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Release()
+
+	// seeking to a name that exists positions the iterator on it.
+	if !iter.Seek("c") || iter.Name() != "c" || iter.Route().URL != "http://c/" {
+		t.Fatalf("expected to be at c, got %q", iter.Name())
+	}
+
+	if !iter.Next() || iter.Name() != "e" {
+		t.Fatalf("expected to be at e, got %q", iter.Name())
+	}
+
+	// seeking between names positions the iterator on the next one.
+	if !iter.Seek("b") || iter.Name() != "c" {
+		t.Fatalf("expected to be at c, got %q", iter.Name())
+	}
+
+	// seeking backwards is allowed.
+	if !iter.Seek("") || iter.Name() != "a" {
+		t.Fatalf("expected to be at a, got %q", iter.Name())
+	}
+
+	if iter.Seek("f") || iter.Valid() {
+		t.Fatal("expected seeking past the end to invalidate the iterator")
+	}
+
+	if iter.Next() {
+		t.Fatal("expected no more routes")
+	}
+}
+
+func TestListIsSnapshot(t *testing.T) {
+	backend := New()
+	defer backend.Close()
+
+	ctx := context.Background()
+
+	if err := putRoutes(ctx, backend, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	iter, err := backend.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Release()
+
+	if err := backend.Del(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := putRoutes(ctx, backend, "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := namesOf(iter); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Fatalf("expected the routes as they were, got %v", names)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+	}{
+		{
+			"admin dump",
+			`{"a": {"url": "http://a/", "time": "2024-01-02T03:04:05Z"}, "b": {"url": "http://b/"}}`,
+		},
+		{
+			"list",
+			`{"ok": true, "routes": [
+				{"name": "a", "url": "http://a/", "time": "2024-01-02T03:04:05Z"},
+				{"name": "b", "url": "http://b/"}
+			], "next": ""}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend := New()
+			defer backend.Close()
+
+			ctx := context.Background()
+
+			if err := backend.Load(strings.NewReader(test.dump)); err != nil {
+				t.Fatal(err)
+			}
+
+			rt, err := backend.Get(ctx, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rt.URL != "http://a/" || !rt.Time.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+				t.Fatalf("unexpected route: %v", rt)
+			}
+
+			all, err := backend.GetAll(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(all) != 2 {
+				t.Fatalf("expected 2 routes, got %d", len(all))
+			}
+		})
+	}
+
+	if err := New().Load(strings.NewReader(`[1, 2]`)); err == nil {
+		t.Fatal("expected an invalid dump to fail")
+	}
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Copy the token so that callers can't change what is stored.
+func cloneToken(t *internal.Token) *internal.Token {
+	c := *t
+	c.Groups = slices.Clone(t.Groups)
+	c.Scopes = slices.Clone(t.Scopes)
+	c.Hash = slices.Clone(t.Hash)
+	return &c
+}
+
+// PutToken stores the API token, replacing any token with the same ID.
+func (backend *Backend) PutToken(ctx context.Context, token *internal.Token) error {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	backend.tokens[token.ID] = cloneToken(token)
+	return nil
+}
+
+// GetToken retrieves the API token with the given ID.
+func (backend *Backend) GetToken(ctx context.Context, id string) (*internal.Token, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	t, ok := backend.tokens[id]
+	if !ok {
+		return nil, internal.ErrTokenNotFound
+	}
+	return cloneToken(t), nil
+}
+
+// ListTokens returns the API tokens that belong to owner, or all tokens if
+// owner is empty, ordered by ID.
+func (backend *Backend) ListTokens(ctx context.Context, owner string) ([]*internal.Token, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	var res []*internal.Token
+	for _, t := range backend.tokens {
+		if owner == "" || t.Owner == owner {
+			res = append(res, cloneToken(t))
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	return res, nil
+}
+
+// DelToken revokes the API token with the given ID.
+func (backend *Backend) DelToken(ctx context.Context, id string) error {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	delete(backend.tokens, id)
+	return nil
+}
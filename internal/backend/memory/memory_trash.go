@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/kellegous/go/internal"
+)
+
+// ListTrash returns the deleted routes that can still be restored, ordered by
+// name.
+func (backend *Backend) ListTrash(ctx context.Context) ([]*internal.TrashedRoute, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	var res []*internal.TrashedRoute
+	for _, t := range backend.trash {
+		c := *t
+		c.Route = cloneRoute(t.Route)
+		res = append(res, &c)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
+
+// GetTrash returns the deleted route with the given name if it can still be
+// restored. It fails with internal.ErrRouteNotFound otherwise.
+func (backend *Backend) GetTrash(ctx context.Context, name string) (*internal.TrashedRoute, error) {
+	backend.lck.RLock()
+	defer backend.lck.RUnlock()
+
+	t, ok := backend.trash[name]
+	if !ok {
+		return nil, internal.ErrRouteNotFound
+	}
+
+	c := *t
+	c.Route = cloneRoute(t.Route)
+	return &c, nil
+}
+
+// Restore moves the named route out of the trash, returning it as it was when
+// it was deleted. It fails with internal.ErrRouteExists if another route with
+// the same name has been created since.
+func (backend *Backend) Restore(ctx context.Context, name string) (*internal.Route, error) {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	t, ok := backend.trash[name]
+	if !ok {
+		return nil, internal.ErrRouteNotFound
+	}
+
+	if _, ok := backend.routes[name]; ok {
+		return nil, internal.ErrRouteExists
+	}
+
+	rt := cloneRoute(t.Route)
+	backend.write(ctx, name, nil, rt)
+	delete(backend.trash, name)
+
+	return rt, nil
+}
+
+// PurgeTrash permanently removes the routes that were deleted before the
+// given time, returning the names of those that were removed in order.
+func (backend *Backend) PurgeTrash(ctx context.Context, before time.Time) ([]string, error) {
+	backend.lck.Lock()
+	defer backend.lck.Unlock()
+
+	var names []string
+	for name, t := range backend.trash {
+		if t.Deleted.Before(before) {
+			delete(backend.trash, name)
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
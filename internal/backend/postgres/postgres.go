@@ -0,0 +1,339 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+)
+
+// The class of the advisory locks that serialize changes to each route. The
+// second key of each lock is a hash of the route's name.
+const routeLockClass int32 = 0x676f
+
+// The advisory lock that changes to single routes hold shared and batches of
+// routes hold exclusively, so that a batch doesn't need a lock for each of its
+// routes.
+const batchLockKey int64 = 0x676f2d6261746368
+
+var _ backend.Backend = (*Backend)(nil)
+
+// Backend provides access to a PostgreSQL database.
+type Backend struct {
+	db *pgxpool.Pool
+}
+
+// New instantiates a new Backend connected to the database described by
+// connString, which is either a URL or a list of keyword/value pairs. Anything
+// it leaves out is taken from the standard PG* environment variables. The
+// schema is migrated to the latest version before New returns.
+func New(ctx context.Context, connString string) (*Backend, error) {
+	db, err := pgxpool.New(ctx, connString)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Backend{db: db}, nil
+}
+
+// Close the resources associated with this backend.
+func (backend *Backend) Close() error {
+	backend.db.Close()
+	return nil
+}
+
+// Serialize changes to the named route for the rest of the transaction.
+func lockRoute(ctx context.Context, tx pgx.Tx, name string) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock_shared($1)`, batchLockKey); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, routeLockClass, name)
+	return err
+}
+
+// Read the named route as part of the transaction, returning nil if it does
+// not exist.
+func getRoute(ctx context.Context, tx pgx.Tx, name string) (*internal.Route, error) {
+	var rt internal.Route
+	if err := tx.QueryRow(
+		ctx,
+		`SELECT route FROM routes WHERE name = $1`,
+		name,
+	).Scan(&rt); errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+// Get retreives a shortcut from the data store.
+func (backend *Backend) Get(ctx context.Context, name string) (*internal.Route, error) {
+	var rt internal.Route
+	if err := backend.db.QueryRow(
+		ctx,
+		`SELECT route FROM routes WHERE name = $1`,
+		name,
+	).Scan(&rt); errors.Is(err, pgx.ErrNoRows) {
+		return nil, internal.ErrRouteNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+// Run fn in a transaction that holds the lock on the named route, passing it
+// the route as it currently is, or nil.
+func (backend *Backend) update(
+	ctx context.Context,
+	name string,
+	fn func(tx pgx.Tx, old *internal.Route) error,
+) error {
+	return pgx.BeginFunc(ctx, backend.db, func(tx pgx.Tx) error {
+		if err := lockRoute(ctx, tx, name); err != nil {
+			return err
+		}
+
+		old, err := getRoute(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		return fn(tx, old)
+	})
+}
+
+// Put stores a new shortcut in the data store.
+func (backend *Backend) Put(ctx context.Context, key string, rt *internal.Route) error {
+	return backend.update(ctx, key, func(tx pgx.Tx, old *internal.Route) error {
+		return write(ctx, tx, key, old, rt)
+	})
+}
+
+// PutIf stores the shortcut only if the existing shortcut is at revision rev.
+// It fails with internal.ErrRouteNotFound if there is no existing shortcut and
+// with internal.ErrRevisionMismatch if it has since changed.
+func (backend *Backend) PutIf(ctx context.Context, key string, rt *internal.Route, rev int64) error {
+	return backend.update(ctx, key, func(tx pgx.Tx, old *internal.Route) error {
+		if old == nil {
+			return internal.ErrRouteNotFound
+		}
+
+		if old.Revision != rev {
+			return internal.ErrRevisionMismatch
+		}
+
+		return write(ctx, tx, key, old, rt)
+	})
+}
+
+// Create stores the shortcut only if there is no existing shortcut with the
+// same name. It fails with internal.ErrRouteExists otherwise.
+func (backend *Backend) Create(ctx context.Context, key string, rt *internal.Route) error {
+	return backend.update(ctx, key, func(tx pgx.Tx, old *internal.Route) error {
+		if old != nil {
+			return internal.ErrRouteExists
+		}
+
+		return write(ctx, tx, key, nil, rt)
+	})
+}
+
+// PutBatch stores all of the routes in a single transaction, doing what the
+// policy says with those that already exist. Changes to other routes wait
+// until the batch is done.
+func (backend *Backend) PutBatch(
+	ctx context.Context,
+	routes []*internal.NamedRoute,
+	policy internal.ConflictPolicy,
+) (*internal.BatchResult, error) {
+	var res *internal.BatchResult
+	err := pgx.BeginFunc(ctx, backend.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, batchLockKey); err != nil {
+			return err
+		}
+
+		var writes []*internal.BatchWrite
+		var err error
+		res, writes, err = internal.PlanBatch(routes, policy, func(name string) (*internal.Route, error) {
+			return getRoute(ctx, tx, name)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, w := range writes {
+			if err := write(ctx, tx, w.Name, w.Old, w.Route); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return res, err
+}
+
+// Write rt as the named route, replacing old, as part of a transaction that
+// holds the route's lock. The change is recorded as a new revision.
+func write(ctx context.Context, tx pgx.Tx, key string, old, rt *internal.Route) error {
+	id, err := lastRevisionID(ctx, tx, key)
+	if err != nil {
+		return err
+	}
+
+	rt.Revision = id + 1
+
+	b, err := json.Marshal(rt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO routes (name, route) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET route = excluded.route`,
+		key,
+		b,
+	); err != nil {
+		return err
+	}
+
+	return record(ctx, tx, key, id, old, rt)
+}
+
+// Del moves an existing shortcut from the data store into the trash.
+func (backend *Backend) Del(ctx context.Context, key string) error {
+	return backend.update(ctx, key, func(tx pgx.Tx, old *internal.Route) error {
+		if old == nil {
+			return nil
+		}
+
+		id, err := lastRevisionID(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+
+		if err := putTrash(ctx, tx, &internal.TrashedRoute{
+			Name:      key,
+			Route:     old,
+			Deleted:   time.Now(),
+			DeletedBy: internal.ActorFrom(ctx),
+		}); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM routes WHERE name = $1`, key); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, key, id, old, nil)
+	})
+}
+
+// List all routes in an iterator, starting with the key prefix of start (which can also be nil).
+func (backend *Backend) List(ctx context.Context, start string) (internal.RouteIterator, error) {
+	return &RouteIterator{
+		ctx:   ctx,
+		db:    backend.db,
+		start: start,
+		key:   start,
+		incl:  true,
+		more:  true,
+	}, nil
+}
+
+// Dump iterates over every route, in order of name, as they all were when
+// Dump was called. The iterator reads in a read-only transaction that holds a
+// connection until it is released.
+func (backend *Backend) Dump(ctx context.Context) (internal.RouteIterator, error) {
+	tx, err := backend.db.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RouteIterator{
+		ctx:  ctx,
+		db:   tx,
+		tx:   tx,
+		incl: true,
+		more: true,
+	}, nil
+}
+
+// GetAll gets everything in the db to dump it out for backup purposes
+func (backend *Backend) GetAll(ctx context.Context) (map[string]internal.Route, error) {
+	rows, err := backend.db.Query(ctx, `SELECT name, route FROM routes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	golinks := map[string]internal.Route{}
+	for rows.Next() {
+		var name string
+		var rt internal.Route
+		if err := rows.Scan(&name, &rt); err != nil {
+			return nil, err
+		}
+		golinks[name] = rt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return golinks, nil
+}
+
+// NextID generates the next numeric ID to be used for an auto-named shortcut.
+func (backend *Backend) NextID(ctx context.Context) (uint64, error) {
+	var id int64
+	if err := backend.db.QueryRow(ctx, `SELECT nextval('route_ids')`).Scan(&id); err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+// The last value of the sequence, or 0 if nextval has never been called.
+const lastIDQuery = `SELECT CASE WHEN is_called THEN last_value ELSE 0 END FROM route_ids`
+
+// LastID returns the last ID generated by NextID, or 0 if there hasn't been
+// one.
+func (backend *Backend) LastID(ctx context.Context) (uint64, error) {
+	var id int64
+	if err := backend.db.QueryRow(ctx, lastIDQuery).Scan(&id); err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+// AdvanceID ensures that NextID only generates IDs after id. It never moves
+// the counter backwards, but an ID generated while it runs may be reused, so
+// it is meant for when nothing else is generating IDs, such as a migration.
+func (backend *Backend) AdvanceID(ctx context.Context, id uint64) error {
+	if id == 0 {
+		return nil
+	}
+
+	_, err := backend.db.Exec(
+		ctx,
+		`SELECT setval('route_ids', greatest($1, (`+lastIDQuery+`)))`,
+		int64(id),
+	)
+	return err
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kellegous/go/internal"
+)
+
+// AppendAudit appends the event to the audit log, assigning its ID from a
+// sequence so that events sort in the order they were appended.
+func (backend *Backend) AppendAudit(ctx context.Context, event *internal.AuditEvent) error {
+	var seq int64
+	if err := backend.db.QueryRow(ctx, `SELECT nextval('audit_seq')`).Scan(&seq); err != nil {
+		return err
+	}
+
+	e := *event
+	e.ID = fmt.Sprintf("%020d", seq)
+
+	b, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+
+	if _, err := backend.db.Exec(
+		ctx,
+		`INSERT INTO audit (seq, event) VALUES ($1, $2)`,
+		seq,
+		b,
+	); err != nil {
+		return err
+	}
+
+	event.ID = e.ID
+	return nil
+}
+
+// ListAudit returns the events selected by the filter in the order they were
+// appended.
+func (backend *Backend) ListAudit(
+	ctx context.Context,
+	filter *internal.AuditFilter,
+) ([]*internal.AuditEvent, error) {
+	rows, err := backend.db.Query(ctx, `SELECT event FROM audit ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []*internal.AuditEvent
+	for rows.Next() {
+		var e internal.AuditEvent
+		if err := rows.Scan(&e); err != nil {
+			return nil, err
+		}
+
+		if !filter.Matches(&e) {
+			continue
+		}
+
+		res = append(res, &e)
+		if filter.Limit > 0 && len(res) >= filter.Limit {
+			break
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
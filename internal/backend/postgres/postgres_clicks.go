@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kellegous/go/internal"
+)
+
+// AddClicks adds the given visits to the stored buckets of each route. Buckets
+// are keyed by the route's name and the start of the bucket in seconds.
+func (backend *Backend) AddClicks(ctx context.Context, clicks map[string][]*internal.Clicks) error {
+	return pgx.BeginFunc(ctx, backend.db, func(tx pgx.Tx) error {
+		for name, cs := range clicks {
+			for _, c := range cs {
+				start := c.Start.Unix()
+
+				empty, err := json.Marshal(&internal.Clicks{Start: c.Start})
+				if err != nil {
+					return err
+				}
+
+				// create the bucket first, so that there is a row to lock even
+				// when another transaction is adding its first clicks too.
+				if _, err := tx.Exec(ctx, `
+					INSERT INTO clicks (name, start, clicks) VALUES ($1, $2, $3)
+					ON CONFLICT (name, start) DO NOTHING`,
+					name,
+					start,
+					empty,
+				); err != nil {
+					return err
+				}
+
+				var cur internal.Clicks
+				if err := tx.QueryRow(
+					ctx,
+					`SELECT clicks FROM clicks WHERE name = $1 AND start = $2 FOR UPDATE`,
+					name,
+					start,
+				).Scan(&cur); err != nil {
+					return err
+				}
+
+				cur.Add(c)
+
+				b, err := json.Marshal(&cur)
+				if err != nil {
+					return err
+				}
+
+				if _, err := tx.Exec(
+					ctx,
+					`UPDATE clicks SET clicks = $3 WHERE name = $1 AND start = $2`,
+					name,
+					start,
+					b,
+				); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// GetClicks returns the stored buckets of the named route that start in the
+// range [from, to), in order of time.
+func (backend *Backend) GetClicks(ctx context.Context, name string, from, to time.Time) ([]*internal.Clicks, error) {
+	rows, err := backend.db.Query(
+		ctx,
+		`SELECT clicks FROM clicks WHERE name = $1 AND start >= $2 AND start < $3 ORDER BY start`,
+		name,
+		from.Unix(),
+		to.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []*internal.Clicks
+	for rows.Next() {
+		var c internal.Clicks
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		res = append(res, &c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
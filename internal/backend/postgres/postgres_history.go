@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Find the ID of the most recent revision of the named route, or 0 if there
+// are none.
+func lastRevisionID(ctx context.Context, tx pgx.Tx, name string) (int64, error) {
+	var id int64
+	if err := tx.QueryRow(
+		ctx,
+		`SELECT coalesce(max(id), 0) FROM history WHERE name = $1`,
+		name,
+	).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Record a change to the named route from old to rt, following the revision
+// with the given ID. Either route may be nil to indicate a creation or a
+// deletion. The transaction must hold the route's lock.
+func record(ctx context.Context, tx pgx.Tx, name string, id int64, old, rt *internal.Route) error {
+	rev := internal.Revision{
+		ID:    id + 1,
+		Time:  time.Now(),
+		By:    internal.ActorFrom(ctx),
+		Route: rt,
+	}
+
+	if old != nil {
+		rev.OldURL = old.URL
+	}
+
+	if rt != nil {
+		rev.NewURL = rt.URL
+	}
+
+	b, err := json.Marshal(&rev)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO history (name, id, revision) VALUES ($1, $2, $3)`,
+		name,
+		rev.ID,
+		b,
+	)
+	return err
+}
+
+// History returns the revisions of the named route, oldest first.
+func (backend *Backend) History(ctx context.Context, name string) ([]*internal.Revision, error) {
+	rows, err := backend.db.Query(
+		ctx,
+		`SELECT revision FROM history WHERE name = $1 ORDER BY id`,
+		name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revs []*internal.Revision
+	for rows.Next() {
+		var rev internal.Revision
+		if err := rows.Scan(&rev); err != nil {
+			return nil, err
+		}
+		revs = append(revs, &rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revs, nil
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kellegous/go/internal"
+)
+
+// AddHits adds the given visits to the totals of each route.
+func (backend *Backend) AddHits(ctx context.Context, hits map[string]*internal.Hits) error {
+	if len(hits) == 0 {
+		return nil
+	}
+
+	var batch pgx.Batch
+	for name, h := range hits {
+		batch.Queue(`
+			INSERT INTO hits (name, count, last) VALUES ($1, $2, $3)
+			ON CONFLICT (name) DO UPDATE SET
+				count = hits.count + excluded.count,
+				last = greatest(hits.last, excluded.last)`,
+			name,
+			h.Count,
+			h.Last,
+		)
+	}
+
+	return pgx.BeginFunc(ctx, backend.db, func(tx pgx.Tx) error {
+		return tx.SendBatch(ctx, &batch).Close()
+	})
+}
+
+// GetHits returns the visits to each of the named routes. Routes that have
+// never been visited are omitted.
+func (backend *Backend) GetHits(ctx context.Context, names []string) (map[string]*internal.Hits, error) {
+	return backend.queryHits(
+		ctx,
+		`SELECT name, count, last FROM hits WHERE name = any($1)`,
+		names,
+	)
+}
+
+// GetAllHits returns the visits to every route that has been visited.
+func (backend *Backend) GetAllHits(ctx context.Context) (map[string]*internal.Hits, error) {
+	return backend.queryHits(ctx, `SELECT name, count, last FROM hits`)
+}
+
+func (backend *Backend) queryHits(ctx context.Context, q string, args ...any) (map[string]*internal.Hits, error) {
+	rows, err := backend.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := map[string]*internal.Hits{}
+	for rows.Next() {
+		var name string
+		var count int64
+		var last time.Time
+		if err := rows.Scan(&name, &count, &last); err != nil {
+			return nil, err
+		}
+		res[name] = &internal.Hits{
+			Count: count,
+			Last:  last,
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
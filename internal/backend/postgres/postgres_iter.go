@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kellegous/go/internal"
+)
+
+// How many routes the iterator reads in each query.
+const iterBatchSize = 100
+
+// Either a pool or a transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+type namedRoute struct {
+	name string
+	rt   *internal.Route
+}
+
+// RouteIterator allows iteration of the named routes in the store. Routes are
+// read a page at a time, with each page starting after the name that ended
+// the last one, so that no connection is held for the life of the iterator.
+type RouteIterator struct {
+	ctx   context.Context
+	db    querier
+	start string
+
+	// the transaction in which every page is read, if the iterator is a
+	// snapshot.
+	tx pgx.Tx
+
+	// where the next page starts and whether that name is included.
+	key  string
+	incl bool
+	more bool
+
+	buf []namedRoute
+	pos int
+	err error
+}
+
+// Read the next page of routes.
+func (i *RouteIterator) fetch() error {
+	i.buf, i.pos = nil, 0
+
+	q := `SELECT name, route FROM routes WHERE name > $1 ORDER BY name LIMIT $2`
+	if i.incl {
+		q = `SELECT name, route FROM routes WHERE name >= $1 ORDER BY name LIMIT $2`
+	}
+
+	rows, err := i.db.Query(i.ctx, q, i.key, iterBatchSize)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var nr namedRoute
+		if err := rows.Scan(&nr.name, &nr.rt); err != nil {
+			return err
+		}
+		i.buf = append(i.buf, nr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	i.more = len(i.buf) == iterBatchSize
+	if n := len(i.buf); n > 0 {
+		i.key = i.buf[n-1].name
+		i.incl = false
+	}
+
+	return nil
+}
+
+// Valid indicates whether the current values of the iterator are valid.
+func (i *RouteIterator) Valid() bool {
+	return i.pos < len(i.buf) && i.err == nil
+}
+
+// Next advances the iterator to the next value.
+func (i *RouteIterator) Next() bool {
+	if i.err != nil {
+		return false
+	}
+
+	if i.pos+1 < len(i.buf) {
+		i.pos++
+		return true
+	}
+
+	i.buf, i.pos = nil, 0
+	if !i.more {
+		return false
+	}
+
+	if err := i.fetch(); err != nil {
+		i.err = err
+		return false
+	}
+
+	return i.Valid()
+}
+
+// Seek positions the iterator on the first route whose name is at or after
+// cur, but never before the start of the listing.
+func (i *RouteIterator) Seek(cur string) bool {
+	if i.err != nil {
+		return false
+	}
+
+	if cur < i.start {
+		cur = i.start
+	}
+
+	i.key = cur
+	i.incl = true
+
+	if err := i.fetch(); err != nil {
+		i.err = err
+		return false
+	}
+
+	return i.Valid()
+}
+
+// Error returns any active error that has stopped the iterator.
+func (i *RouteIterator) Error() error {
+	return i.err
+}
+
+// Name is the name of the current route.
+func (i *RouteIterator) Name() string {
+	if !i.Valid() {
+		return ""
+	}
+	return i.buf[i.pos].name
+}
+
+// Route is the current route.
+func (i *RouteIterator) Route() *internal.Route {
+	if !i.Valid() {
+		return nil
+	}
+	return i.buf[i.pos].rt
+}
+
+// Release disposes of the resources in the iterator.
+func (i *RouteIterator) Release() {
+	i.buf = nil
+	i.more = false
+
+	if i.tx != nil {
+		i.tx.Rollback(i.ctx)
+		i.tx = nil
+	}
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The key of the advisory lock that serializes migrations, so that instances
+// starting at the same time don't both apply them.
+const migrationLockKey int64 = 0x676f2d6d69677261
+
+// The migrations that build the schema, in order. The version of the schema
+// is the number of migrations that have been applied. Applied migrations must
+// never change; changes to the schema are made by appending new ones.
+var migrations = []string{
+	// 1: the initial schema. Names are collated bytewise so that routes sort
+	// the same way they do in the other backends.
+	`
+	CREATE TABLE routes (
+		name text COLLATE "C" PRIMARY KEY,
+		route jsonb NOT NULL
+	);
+
+	CREATE TABLE history (
+		name text COLLATE "C" NOT NULL,
+		id bigint NOT NULL,
+		revision jsonb NOT NULL,
+		PRIMARY KEY (name, id)
+	);
+
+	CREATE SEQUENCE route_ids;
+
+	CREATE TABLE hits (
+		name text COLLATE "C" PRIMARY KEY,
+		count bigint NOT NULL,
+		last timestamptz NOT NULL
+	);
+
+	CREATE TABLE clicks (
+		name text COLLATE "C" NOT NULL,
+		start bigint NOT NULL,
+		clicks jsonb NOT NULL,
+		PRIMARY KEY (name, start)
+	);
+
+	CREATE TABLE tokens (
+		id text COLLATE "C" PRIMARY KEY,
+		owner text NOT NULL,
+		token jsonb NOT NULL
+	);
+
+	CREATE INDEX tokens_owner ON tokens (owner);
+
+	CREATE SEQUENCE audit_seq;
+
+	CREATE TABLE audit (
+		seq bigint PRIMARY KEY,
+		event jsonb NOT NULL
+	);
+
+	CREATE TABLE trash (
+		name text COLLATE "C" PRIMARY KEY,
+		deleted timestamptz NOT NULL,
+		trashed jsonb NOT NULL
+	);
+	`,
+}
+
+// Bring the schema up to date by applying the migrations that haven't been
+// applied yet, all in one transaction.
+func migrate(ctx context.Context, db interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}) error {
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockKey); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version integer PRIMARY KEY,
+				applied timestamptz NOT NULL DEFAULT now()
+			)`); err != nil {
+			return err
+		}
+
+		var version int
+		if err := tx.QueryRow(
+			ctx,
+			`SELECT coalesce(max(version), 0) FROM schema_migrations`,
+		).Scan(&version); err != nil {
+			return err
+		}
+
+		if version > len(migrations) {
+			return fmt.Errorf("schema version %d is newer than this version supports (%d)", version, len(migrations))
+		}
+
+		for i := version; i < len(migrations); i++ {
+			if _, err := tx.Exec(ctx, migrations[i]); err != nil {
+				return fmt.Errorf("migration %d: %w", i+1, err)
+			}
+
+			if _, err := tx.Exec(
+				ctx,
+				`INSERT INTO schema_migrations (version) VALUES ($1)`,
+				i+1,
+			); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,241 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+	"github.com/kellegous/go/internal/backend/backendtest"
+)
+
+var databases atomic.Int64
+
+// Skip the test unless there is a server to run it against.
+func needServer(t *testing.T) {
+	if testURL == "" {
+		t.Skip("no postgres server; set " + testURLEnv + " or install postgres")
+	}
+}
+
+// Create a database that no other test uses, so that its backend starts out
+// empty, returning its URL. The database is dropped when the test ends.
+func newDatabase(t *testing.T) string {
+	needServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, err := pgx.Connect(ctx, testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	name := fmt.Sprintf("test_%d_%d", time.Now().UnixNano(), databases.Add(1))
+	if _, err := conn.Exec(ctx, "CREATE DATABASE "+name); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		conn, err := pgx.Connect(ctx, testURL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close(ctx)
+
+		if _, err := conn.Exec(ctx, "DROP DATABASE IF EXISTS "+name); err != nil {
+			t.Error(err)
+		}
+	})
+
+	u, err := url.Parse(testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Path = "/" + name
+	return u.String()
+}
+
+// Create a backend for the database, which is closed when the test ends so
+// that the database can be dropped.
+func newBackendIn(t *testing.T, db string) *Backend {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	backend, err := New(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		backend.Close()
+	})
+
+	return backend
+}
+
+func newTestBackend(t *testing.T) *Backend {
+	return newBackendIn(t, newDatabase(t))
+}
+
+func TestConformance(t *testing.T) {
+	needServer(t)
+
+	backendtest.Run(t, func(t *testing.T) backend.Backend {
+		return newTestBackend(t)
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	db := newDatabase(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// instances that start at the same time migrate the schema only once.
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			backend, err := New(ctx, db)
+			if err == nil {
+				backend.Close()
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := newBackendIn(t, db)
+
+	var version int
+	if err := backend.db.QueryRow(
+		ctx,
+		`SELECT max(version) FROM schema_migrations`,
+	).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+
+	if version != len(migrations) {
+		t.Fatalf("expected schema version %d, got %d", len(migrations), version)
+	}
+
+	// a schema from a newer version isn't touched.
+	if _, err := backend.db.Exec(
+		ctx,
+		`INSERT INTO schema_migrations (version) VALUES ($1)`,
+		len(migrations)+1,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(ctx, db); err == nil {
+		t.Fatal("expected a newer schema to fail")
+	}
+}
+
+func TestNextIDShared(t *testing.T) {
+	// both instances of the service share the sequence.
+	db := newDatabase(t)
+
+	a := newBackendIn(t, db)
+	b := newBackendIn(t, db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	const n = 10
+
+	ids := make([]uint64, 2*n)
+	errs := make([]error, 2*n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		for j, backend := range []*Backend{a, b} {
+			wg.Add(1)
+			go func(k int, backend *Backend) {
+				defer wg.Done()
+				ids[k], errs[k] = backend.NextID(ctx)
+			}(2*i+j, backend)
+		}
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	for i, id := range ids {
+		if id != uint64(i+1) {
+			t.Fatalf("expected ids 1 to %d, got %v", 2*n, ids)
+		}
+	}
+}
+
+func TestListPages(t *testing.T) {
+	backend := newTestBackend(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// enough routes that the iterator has to read several pages.
+	var names []string
+	for i := 0; i < 2*iterBatchSize+5; i++ {
+		name := fmt.Sprintf("r%03d", i)
+		if err := backend.Put(ctx, name, &internal.Route{
+			URL:  "http://" + name + "/",
+			Time: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	iter, err := backend.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Release()
+
+	var got []string
+	for iter.Next() {
+		got = append(got, iter.Name())
+	}
+
+	if err := iter.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, names) {
+		t.Fatalf("expected %d routes in order, got %v", len(names), got)
+	}
+
+	// seeking into a later page reads from there.
+	if !iter.Seek("r150") || iter.Name() != "r150" || iter.Route().URL != "http://r150/" {
+		t.Fatalf("expected to be at r150, got %q", iter.Name())
+	}
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kellegous/go/internal"
+)
+
+// PutToken stores the API token, replacing any token with the same ID.
+func (backend *Backend) PutToken(ctx context.Context, token *internal.Token) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	_, err = backend.db.Exec(ctx, `
+		INSERT INTO tokens (id, owner, token) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, token = excluded.token`,
+		token.ID,
+		token.Owner,
+		b,
+	)
+	return err
+}
+
+// GetToken retrieves the API token with the given ID.
+func (backend *Backend) GetToken(ctx context.Context, id string) (*internal.Token, error) {
+	var t internal.Token
+	if err := backend.db.QueryRow(
+		ctx,
+		`SELECT token FROM tokens WHERE id = $1`,
+		id,
+	).Scan(&t); errors.Is(err, pgx.ErrNoRows) {
+		return nil, internal.ErrTokenNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTokens returns the API tokens that belong to owner, or all tokens if
+// owner is empty, ordered by ID.
+func (backend *Backend) ListTokens(ctx context.Context, owner string) ([]*internal.Token, error) {
+	rows, err := backend.db.Query(
+		ctx,
+		`SELECT token FROM tokens WHERE $1 = '' OR owner = $1 ORDER BY id`,
+		owner,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []*internal.Token
+	for rows.Next() {
+		var t internal.Token
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		res = append(res, &t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DelToken revokes the API token with the given ID.
+func (backend *Backend) DelToken(ctx context.Context, id string) error {
+	_, err := backend.db.Exec(ctx, `DELETE FROM tokens WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kellegous/go/internal"
+)
+
+// Put the deleted route into the trash, replacing any earlier deletion of a
+// route with the same name.
+func putTrash(ctx context.Context, tx pgx.Tx, t *internal.TrashedRoute) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO trash (name, deleted, trashed) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET deleted = excluded.deleted, trashed = excluded.trashed`,
+		t.Name,
+		t.Deleted,
+		b,
+	)
+	return err
+}
+
+// ListTrash returns the deleted routes that can still be restored, ordered by
+// name.
+func (backend *Backend) ListTrash(ctx context.Context) ([]*internal.TrashedRoute, error) {
+	rows, err := backend.db.Query(ctx, `SELECT trashed FROM trash ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []*internal.TrashedRoute
+	for rows.Next() {
+		var t internal.TrashedRoute
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		res = append(res, &t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetTrash returns the deleted route with the given name if it can still be
+// restored. It fails with internal.ErrRouteNotFound otherwise.
+func (backend *Backend) GetTrash(ctx context.Context, name string) (*internal.TrashedRoute, error) {
+	var t internal.TrashedRoute
+	if err := backend.db.QueryRow(
+		ctx,
+		`SELECT trashed FROM trash WHERE name = $1`,
+		name,
+	).Scan(&t); errors.Is(err, pgx.ErrNoRows) {
+		return nil, internal.ErrRouteNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Restore moves the named route out of the trash, returning it as it was when
+// it was deleted. It fails with internal.ErrRouteExists if another route with
+// the same name has been created since.
+func (backend *Backend) Restore(ctx context.Context, name string) (*internal.Route, error) {
+	var t internal.TrashedRoute
+	if err := backend.update(ctx, name, func(tx pgx.Tx, old *internal.Route) error {
+		if err := tx.QueryRow(
+			ctx,
+			`SELECT trashed FROM trash WHERE name = $1`,
+			name,
+		).Scan(&t); errors.Is(err, pgx.ErrNoRows) {
+			return internal.ErrRouteNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if old != nil {
+			return internal.ErrRouteExists
+		}
+
+		if err := write(ctx, tx, name, nil, t.Route); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, `DELETE FROM trash WHERE name = $1`, name)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return t.Route, nil
+}
+
+// PurgeTrash permanently removes the routes that were deleted before the
+// given time, returning the names of those that were removed in order.
+func (backend *Backend) PurgeTrash(ctx context.Context, before time.Time) ([]string, error) {
+	rows, err := backend.db.Query(ctx, `DELETE FROM trash WHERE deleted < $1 RETURNING name`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
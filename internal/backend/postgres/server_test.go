@@ -0,0 +1,186 @@
+//go:build unix
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The environment variable that points the tests at an existing server, as a
+// URL to a database in which the tests may create other databases.
+const testURLEnv = "POSTGRES_TEST_URL"
+
+// How long to wait for the server to start accepting connections.
+const serverStartTimeout = 30 * time.Second
+
+// The URL of the server the tests run against, or empty if there isn't one.
+var testURL string
+
+// Run the tests against a PostgreSQL server. If POSTGRES_TEST_URL isn't set,
+// a throwaway server is started with initdb and postgres when they are
+// installed. Otherwise, the tests that need a server are skipped.
+func TestMain(m *testing.M) {
+	if testURL = os.Getenv(testURLEnv); testURL != "" {
+		os.Exit(m.Run())
+	}
+
+	url, stop, err := startServer()
+	if err != nil {
+		log.Printf("not starting a postgres server: %s", err)
+		os.Exit(m.Run())
+	}
+
+	testURL = url
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+// Find one of the server's programs, which are often installed outside of the
+// PATH.
+func lookPath(name string) (string, error) {
+	if p, err := exec.LookPath(name); err == nil {
+		return p, nil
+	}
+
+	for _, pattern := range []string{
+		"/usr/lib/postgresql/*/bin",
+		"/usr/local/pgsql/bin",
+		"/opt/homebrew/opt/postgresql*/bin",
+	} {
+		dirs, _ := filepath.Glob(pattern)
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if p, err := exec.LookPath(filepath.Join(dirs[i], name)); err == nil {
+				return p, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%s not found", name)
+}
+
+// Find a local port that is free to listen on.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Start a server with a new cluster in a temporary directory, returning the
+// URL of its postgres database and a function that stops it and removes the
+// cluster.
+func startServer() (string, func(), error) {
+	initdb, err := lookPath("initdb")
+	if err != nil {
+		return "", nil, err
+	}
+
+	postgres, err := lookPath("postgres")
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "go-postgres-test-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	data := filepath.Join(dir, "data")
+	if out, err := exec.Command(
+		initdb,
+		"--pgdata="+data,
+		"--username=postgres",
+		"--auth=trust",
+		"--no-sync",
+	).CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("initdb: %w: %s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	cmd := exec.Command(
+		postgres,
+		"-D", data,
+		"-p", fmt.Sprint(port),
+		"-k", dir,
+		"-c", "listen_addresses=127.0.0.1",
+		"-c", "fsync=off",
+	)
+
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	stop := func() {
+		// SIGINT is a fast shutdown, which doesn't wait for clients.
+		cmd.Process.Signal(syscall.SIGINT)
+		select {
+		case <-exited:
+		case <-time.After(10 * time.Second):
+			cmd.Process.Kill()
+			<-exited
+		}
+		os.RemoveAll(dir)
+	}
+
+	url := fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverStartTimeout)
+	defer cancel()
+
+	if err := waitForServer(ctx, url, exited); err != nil {
+		stop()
+		return "", nil, err
+	}
+
+	return url, stop, nil
+}
+
+// Wait until the server at url accepts connections.
+func waitForServer(ctx context.Context, url string, exited <-chan error) error {
+	t := time.NewTicker(100 * time.Millisecond)
+	defer t.Stop()
+
+	for {
+		if conn, err := pgx.Connect(ctx, url); err == nil {
+			return conn.Close(ctx)
+		}
+
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return fmt.Errorf("postgres: %w", err)
+		case <-ctx.Done():
+			return fmt.Errorf("postgres did not start: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
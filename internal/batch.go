@@ -0,0 +1,103 @@
+package internal
+
+import "fmt"
+
+// ConflictPolicy determines what a batch of routes does with the routes that
+// already exist.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip leaves existing routes as they are.
+	ConflictSkip ConflictPolicy = "skip"
+
+	// ConflictOverwrite replaces existing routes.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+
+	// ConflictFail writes nothing if any of the routes exist.
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// Valid indicates whether this is a known ConflictPolicy.
+func (p ConflictPolicy) Valid() bool {
+	switch p {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return true
+	}
+	return false
+}
+
+// NamedRoute is a route along with its name.
+type NamedRoute struct {
+	Name  string
+	Route *Route
+}
+
+// BatchResult describes what a batch of routes did to the routes in the store.
+type BatchResult struct {
+	Created []string
+	Updated []string
+
+	// Skipped are the routes that already existed and were left alone. When a
+	// batch fails under ConflictFail, they are the routes that existed.
+	Skipped []string
+}
+
+// BatchWrite is a route that a batch writes, along with the route it
+// replaces, if any.
+type BatchWrite struct {
+	Name  string
+	Old   *Route
+	Route *Route
+}
+
+// PlanBatch decides what a batch of routes does under the policy. get returns
+// the named route as it currently is, or nil if there is none. It fails with
+// ErrRouteExists, and the routes that exist as Skipped, if the policy is
+// ConflictFail and any of the routes exist.
+func PlanBatch(
+	routes []*NamedRoute,
+	policy ConflictPolicy,
+	get func(name string) (*Route, error),
+) (*BatchResult, []*BatchWrite, error) {
+	if !policy.Valid() {
+		return nil, nil, fmt.Errorf("invalid conflict policy %q", policy)
+	}
+
+	res := &BatchResult{}
+	var writes []*BatchWrite
+
+	seen := map[string]bool{}
+	for _, nr := range routes {
+		if seen[nr.Name] {
+			return nil, nil, fmt.Errorf("route %q appears more than once in the batch", nr.Name)
+		}
+		seen[nr.Name] = true
+
+		old, err := get(nr.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch {
+		case old == nil:
+			res.Created = append(res.Created, nr.Name)
+		case policy == ConflictOverwrite:
+			res.Updated = append(res.Updated, nr.Name)
+		default:
+			res.Skipped = append(res.Skipped, nr.Name)
+			continue
+		}
+
+		writes = append(writes, &BatchWrite{
+			Name:  nr.Name,
+			Old:   old,
+			Route: nr.Route,
+		})
+	}
+
+	if policy == ConflictFail && len(res.Skipped) > 0 {
+		return &BatchResult{Skipped: res.Skipped}, nil, ErrRouteExists
+	}
+
+	return res, writes, nil
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// ClickBucketSize is the period of time covered by each stored Clicks.
+	ClickBucketSize = time.Hour
+
+	// MaxReferrers is the number of referring hosts counted in each Clicks.
+	// Visits from the less common hosts are counted under OtherReferrer, so
+	// that a flood of distinct Referer headers can't grow the buckets without
+	// bound.
+	MaxReferrers = 50
+
+	// OtherReferrer counts the visits from hosts beyond MaxReferrers.
+	OtherReferrer = "other"
+)
+
+// Clicks summarizes the visits to a route during a period of time.
+type Clicks struct {
+	// Start is the beginning of the period.
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+
+	// Referrers counts visits by the host of the referring page, keeping at
+	// most MaxReferrers hosts.
+	Referrers map[string]int64 `json:"referrers,omitempty"`
+
+	// Agents counts visits by the class of user agent, e.g. browser or script.
+	Agents map[string]int64 `json:"agents,omitempty"`
+}
+
+// Add merges the visits in o into these Clicks.
+func (c *Clicks) Add(o *Clicks) {
+	c.Count += o.Count
+	c.Referrers = foldReferrers(addCounts(c.Referrers, o.Referrers))
+	c.Agents = addCounts(c.Agents, o.Agents)
+}
+
+// Keep the MaxReferrers hosts with the most visits, moving the visits from the
+// rest to OtherReferrer.
+func foldReferrers(m map[string]int64) map[string]int64 {
+	n := len(m)
+	if _, ok := m[OtherReferrer]; ok {
+		n--
+	}
+
+	if n <= MaxReferrers {
+		return m
+	}
+
+	hosts := make([]string, 0, n)
+	for k := range m {
+		if k != OtherReferrer {
+			hosts = append(hosts, k)
+		}
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		if m[hosts[i]] != m[hosts[j]] {
+			return m[hosts[i]] > m[hosts[j]]
+		}
+		return hosts[i] < hosts[j]
+	})
+
+	for _, k := range hosts[MaxReferrers:] {
+		m[OtherReferrer] += m[k]
+		delete(m, k)
+	}
+	return m
+}
+
+func addCounts(a, b map[string]int64) map[string]int64 {
+	if len(b) == 0 {
+		return a
+	}
+
+	if a == nil {
+		a = make(map[string]int64, len(b))
+	}
+
+	for k, v := range b {
+		a[k] += v
+	}
+	return a
+}
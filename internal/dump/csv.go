@@ -0,0 +1,237 @@
+package dump
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kellegous/go/internal"
+)
+
+// The columns of a CSV dump, in the order they are written.
+var csvColumns = []string{
+	"name",
+	"url",
+	"description",
+	"owner",
+	"co_owners",
+	"tags",
+	"passthrough",
+	"modified_by",
+	"created",
+	"time",
+	"revision",
+}
+
+// Separates the items of a list in a single CSV field. Within an item, the
+// separator and backslashes are escaped with a backslash.
+const csvListSep = ';'
+
+var csvListEscaper = strings.NewReplacer(`\`, `\\`, string(csvListSep), `\`+string(csvListSep))
+
+func formatList(l []string) string {
+	items := make([]string, 0, len(l))
+	for _, v := range l {
+		items = append(items, csvListEscaper.Replace(v))
+	}
+	return strings.Join(items, string(csvListSep))
+}
+
+// Split a list written by formatList. A backslash that isn't followed by the
+// separator or another backslash is kept, so lists written by hand needn't
+// escape them.
+func parseList(s string) []string {
+	var l []string
+	var b strings.Builder
+
+	add := func() {
+		if v := strings.TrimSpace(b.String()); v != "" {
+			l = append(l, v)
+		}
+		b.Reset()
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '\\' || s[i+1] == csvListSep):
+			i++
+			b.WriteByte(s[i])
+		case c == csvListSep:
+			add()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	add()
+
+	return l
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+// Write the header, if it hasn't been written yet.
+func (w *csvWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	return w.w.Write(csvColumns)
+}
+
+func (w *csvWriter) Write(e *Entry) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	rt := e.Route
+	if rt == nil {
+		rt = &internal.Route{}
+	}
+
+	return w.w.Write([]string{
+		e.Name,
+		rt.URL,
+		rt.Description,
+		rt.Owner,
+		formatList(rt.CoOwners),
+		formatList(rt.Tags),
+		string(rt.Passthrough),
+		rt.ModifiedBy,
+		formatTime(rt.Created),
+		formatTime(rt.Time),
+		strconv.FormatInt(rt.Revision, 10),
+	})
+}
+
+// Close writes the header if nothing else was written, so that even an empty
+// dump says what its columns are.
+func (w *csvWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type csvReader struct {
+	r *csv.Reader
+
+	// the index of each column, from the header.
+	cols map[string]int
+	n    int
+}
+
+func newCSVReader(r io.Reader) *csvReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	return &csvReader{r: cr}
+}
+
+// Read the header and find the columns in it. Columns may be in any order
+// and all but name and url may be left out.
+func (r *csvReader) readHeader() error {
+	header, err := r.r.Read()
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, c := range csvColumns {
+		known[c] = true
+	}
+
+	r.cols = map[string]int{}
+	for i, c := range header {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if !known[c] {
+			return fmt.Errorf("unknown column %q", c)
+		}
+		r.cols[c] = i
+	}
+
+	for _, c := range []string{"name", "url"} {
+		if _, ok := r.cols[c]; !ok {
+			return fmt.Errorf("missing column %q", c)
+		}
+	}
+
+	return nil
+}
+
+func (r *csvReader) Read() (*Entry, error) {
+	if r.cols == nil {
+		if err := r.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+
+	rec, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	r.n++
+
+	field := func(c string) string {
+		if i, ok := r.cols[c]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	e := &Entry{
+		Name: field("name"),
+		Route: &internal.Route{
+			URL:         field("url"),
+			Description: field("description"),
+			Owner:       field("owner"),
+			CoOwners:    parseList(field("co_owners")),
+			Tags:        parseList(field("tags")),
+			Passthrough: internal.Passthrough(field("passthrough")),
+			ModifiedBy:  field("modified_by"),
+		},
+	}
+
+	if e.Created, err = parseTime(field("created")); err != nil {
+		return nil, fmt.Errorf("entry %d: invalid created: %w", r.n, err)
+	}
+
+	if e.Time, err = parseTime(field("time")); err != nil {
+		return nil, fmt.Errorf("entry %d: invalid time: %w", r.n, err)
+	}
+
+	if s := field("revision"); s != "" {
+		if e.Revision, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("entry %d: invalid revision: %w", r.n, err)
+		}
+	}
+
+	if err := validate(e, r.n); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
@@ -0,0 +1,200 @@
+// Package dump reads and writes routes in the formats used to export and
+// import them: JSON lines, CSV and YAML. Every format keeps all of a route's
+// fields, including its timestamps, so that a route survives being exported
+// and imported again.
+package dump
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+)
+
+// Format is one of the formats in which routes can be exported.
+type Format string
+
+const (
+	// JSONL is one JSON object per line, with the name alongside the fields
+	// of the route.
+	JSONL Format = "jsonl"
+
+	// CSV is a table with a header row naming the columns. Lists are
+	// separated by semicolons.
+	CSV Format = "csv"
+
+	// YAML is a list of routes, meant to be edited by hand.
+	YAML Format = "yaml"
+)
+
+// Formats lists every supported format.
+var Formats = []Format{JSONL, CSV, YAML}
+
+// ErrUnknownFormat is returned for formats that are not supported.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ParseFormat finds the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case JSONL, CSV, YAML:
+		return f, nil
+	case "ndjson":
+		return JSONL, nil
+	case "yml":
+		return YAML, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+}
+
+// FormatOf finds the format of a file from its extension.
+func FormatOf(filename string) (Format, error) {
+	ext := filepath.Ext(filename)
+	if ext == "" {
+		return "", fmt.Errorf("%w: %s has no extension", ErrUnknownFormat, filename)
+	}
+	return ParseFormat(ext[1:])
+}
+
+// ContentType is the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv;charset=utf-8"
+	case YAML:
+		return "application/yaml"
+	}
+	return "application/x-ndjson"
+}
+
+// Entry is a route along with its name.
+type Entry struct {
+	Name string `json:"name"`
+	*internal.Route
+}
+
+// Writer writes entries in one of the formats.
+type Writer interface {
+	Write(e *Entry) error
+
+	// Close flushes anything that is buffered. It does not close the
+	// underlying writer.
+	Close() error
+}
+
+// Reader reads entries in one of the formats.
+type Reader interface {
+	// Read returns the next entry, or io.EOF when there are no more.
+	Read() (*Entry, error)
+}
+
+// NewWriter creates a Writer that writes entries to w in the format.
+func NewWriter(w io.Writer, f Format) (Writer, error) {
+	switch f {
+	case JSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	case CSV:
+		return newCSVWriter(w), nil
+	case YAML:
+		return &yamlWriter{w: w}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, f)
+}
+
+// NewReader creates a Reader that reads entries from r in the format.
+func NewReader(r io.Reader, f Format) (Reader, error) {
+	switch f {
+	case JSONL:
+		return &jsonlReader{dec: json.NewDecoder(r)}, nil
+	case CSV:
+		return newCSVReader(r), nil
+	case YAML:
+		return &yamlReader{r: r}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, f)
+}
+
+// WriteAll writes every route in the iterator and returns how many there
+// were. The writer is not closed.
+func WriteAll(w Writer, iter internal.RouteIterator) (int, error) {
+	var n int
+	for iter.Next() {
+		if err := w.Write(&Entry{
+			Name:  iter.Name(),
+			Route: iter.Route(),
+		}); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, iter.Error()
+}
+
+// Ensure that the nth entry read has a name and a URL.
+func validate(e *Entry, n int) error {
+	if e.Name == "" {
+		return fmt.Errorf("entry %d has no name", n)
+	}
+
+	if e.Route == nil || e.URL == "" {
+		return fmt.Errorf("entry %d (%s) has no url", n, e.Name)
+	}
+
+	return nil
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (w *jsonlWriter) Write(e *Entry) error {
+	return w.enc.Encode(e)
+}
+
+func (w *jsonlWriter) Close() error {
+	return nil
+}
+
+type jsonlReader struct {
+	dec *json.Decoder
+	n   int
+}
+
+func (r *jsonlReader) Read() (*Entry, error) {
+	var e Entry
+	if err := r.dec.Decode(&e); err != nil {
+		return nil, err
+	}
+
+	r.n++
+	if err := validate(&e, r.n); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// Load puts every entry in the reader into the backend, replacing any routes
+// with the same names, and returns how many there were. The routes keep their
+// timestamps, but are given new revisions by the backend.
+func Load(ctx context.Context, dst backend.Backend, r Reader) (int, error) {
+	var n int
+	for {
+		e, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+
+		if err := dst.Put(ctx, e.Name, e.Route); err != nil {
+			return n, fmt.Errorf("%s: %w", e.Name, err)
+		}
+		n++
+	}
+}
@@ -0,0 +1,279 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend/memory"
+)
+
+func testEntries() []*Entry {
+	return []*Entry{
+		{
+			Name: "a",
+			Route: &internal.Route{
+				URL:         "http://a/{1}",
+				Time:        time.Date(2024, 1, 2, 3, 4, 5, 6789, time.UTC),
+				Passthrough: internal.PassthroughIgnore,
+				Owner:       "alice",
+				CoOwners:    []string{"bob", "eng"},
+				Description: "a link, with \"quotes\"\nand lines",
+				Tags:        []string{"x", "y;z", `w\`},
+				Created:     time.Date(2023, 1, 2, 3, 4, 5, 0, time.FixedZone("x", -3600)),
+				ModifiedBy:  "bob",
+				Revision:    3,
+			},
+		},
+		{
+			Name:  "b",
+			Route: &internal.Route{URL: "http://b/"},
+		},
+	}
+}
+
+// Compare routes, allowing times to be in another location.
+func mustBeSameEntries(t *testing.T, a, b []*Entry) {
+	t.Helper()
+
+	if len(a) != len(b) {
+		t.Fatalf("expected %d entries, got %d", len(a), len(b))
+	}
+
+	for i := range a {
+		x, y := *a[i].Route, *b[i].Route
+		if a[i].Name != b[i].Name || !x.Time.Equal(y.Time) || !x.Created.Equal(y.Created) {
+			t.Fatalf("expected %s %+v, got %s %+v", a[i].Name, x, b[i].Name, y)
+		}
+
+		x.Time, y.Time, x.Created, y.Created = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+		if !reflect.DeepEqual(x, y) {
+			t.Fatalf("expected %+v, got %+v", x, y)
+		}
+	}
+}
+
+func readAll(t *testing.T, r Reader) []*Entry {
+	t.Helper()
+
+	var entries []*Entry
+	for {
+		e, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return entries
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, f := range Formats {
+		t.Run(string(f), func(t *testing.T) {
+			var buf bytes.Buffer
+
+			w, err := NewWriter(&buf, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries := testEntries()
+			for _, e := range entries {
+				if err := w.Write(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := NewReader(&buf, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mustBeSameEntries(t, entries, readAll(t, r))
+		})
+	}
+}
+
+func TestCSVList(t *testing.T) {
+	tests := []struct {
+		list  []string
+		field string
+	}{
+		{[]string{"a", "b"}, "a;b"},
+		{[]string{"a;b", "c"}, `a\;b;c`},
+		{[]string{`a\`, `b\;`}, `a\\;b\\\;`},
+	}
+
+	for _, test := range tests {
+		if field := formatList(test.list); field != test.field {
+			t.Fatalf("expected %v to be written as %q, got %q", test.list, test.field, field)
+		}
+
+		if l := parseList(test.field); !reflect.DeepEqual(l, test.list) {
+			t.Fatalf("expected %q to be read as %v, got %v", test.field, test.list, l)
+		}
+	}
+
+	// lists written by hand needn't escape backslashes, and empty items are
+	// dropped.
+	if l := parseList(` a\b ;; c `); !reflect.DeepEqual(l, []string{`a\b`, "c"}) {
+		t.Fatalf("unexpected list: %v", l)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	for _, f := range Formats {
+		var buf bytes.Buffer
+
+		w, err := NewWriter(&buf, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewReader(&buf, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if entries := readAll(t, r); len(entries) != 0 {
+			t.Fatalf("%s: expected no entries, got %v", f, entries)
+		}
+	}
+}
+
+func TestReadCSVColumns(t *testing.T) {
+	r, err := NewReader(strings.NewReader("URL,name,tags\nhttp://a/,a,x; y\n"), CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustBeSameEntries(t, readAll(t, r), []*Entry{{
+		Name:  "a",
+		Route: &internal.Route{URL: "http://a/", Tags: []string{"x", "y"}},
+	}})
+
+	for _, s := range []string{
+		"name\na\n",
+		"name,url,colour\na,http://a/,red\n",
+		"name,url,time\na,http://a/,yesterday\n",
+		"name,url\n,http://a/\n",
+	} {
+		r, err := NewReader(strings.NewReader(s), CSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := r.Read(); err == nil || errors.Is(err, io.EOF) {
+			t.Fatalf("expected an error reading %q, got %v", s, err)
+		}
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	tests := []struct {
+		f Format
+		s string
+	}{
+		{JSONL, `{"name": "a"}`},
+		{JSONL, `{"url": "http://a/"}`},
+		{YAML, "- name: a\n"},
+		{YAML, "name: a\n"},
+	}
+
+	for _, test := range tests {
+		r, err := NewReader(strings.NewReader(test.s), test.f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := r.Read(); err == nil || errors.Is(err, io.EOF) {
+			t.Fatalf("%s: expected an error reading %q, got %v", test.f, test.s, err)
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]Format{
+		"links.jsonl":  JSONL,
+		"links.ndjson": JSONL,
+		"LINKS.CSV":    CSV,
+		"links.yml":    YAML,
+		"a/links.yaml": YAML,
+	}
+
+	for filename, expected := range tests {
+		if f, err := FormatOf(filename); err != nil || f != expected {
+			t.Fatalf("%s: expected %s, got %s, %v", filename, expected, f, err)
+		}
+	}
+
+	for _, filename := range []string{"links", "links.json", "links.xml"} {
+		if _, err := FormatOf(filename); !errors.Is(err, ErrUnknownFormat) {
+			t.Fatalf("%s: expected ErrUnknownFormat, got %v", filename, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+
+	src, dst := memory.New(), memory.New()
+	for _, e := range testEntries() {
+		if err := src.Put(ctx, e.Name, e.Route); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	iter, err := src.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Release()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := WriteAll(w, iter); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("expected 2 routes to be written, got %d", n)
+	}
+
+	r, err := NewReader(&buf, YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := Load(ctx, dst, r); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("expected 2 routes to be loaded, got %d", n)
+	}
+
+	rt, err := dst.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := testEntries()[0]; !rt.Time.Equal(e.Time) || !rt.Created.Equal(e.Created) || rt.Owner != e.Owner {
+		t.Fatalf("expected %+v, got %+v", e.Route, rt)
+	}
+}
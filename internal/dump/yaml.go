@@ -0,0 +1,114 @@
+package dump
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/kellegous/go/internal"
+)
+
+// An entry as it appears in a YAML dump.
+type yamlEntry struct {
+	Name        string               `yaml:"name"`
+	URL         string               `yaml:"url"`
+	Description string               `yaml:"description,omitempty"`
+	Owner       string               `yaml:"owner,omitempty"`
+	CoOwners    []string             `yaml:"co_owners,omitempty,flow"`
+	Tags        []string             `yaml:"tags,omitempty,flow"`
+	Passthrough internal.Passthrough `yaml:"passthrough,omitempty"`
+	ModifiedBy  string               `yaml:"modified_by,omitempty"`
+	Created     time.Time            `yaml:"created,omitempty"`
+	Time        time.Time            `yaml:"time,omitempty"`
+	Revision    int64                `yaml:"revision,omitempty"`
+}
+
+type yamlWriter struct {
+	w io.Writer
+}
+
+// Write the entry as a list of one. Lists written one after another form a
+// single list, so the dump never has to be held in memory.
+func (w *yamlWriter) Write(e *Entry) error {
+	rt := e.Route
+	if rt == nil {
+		rt = &internal.Route{}
+	}
+
+	b, err := yaml.Marshal([]*yamlEntry{{
+		Name:        e.Name,
+		URL:         rt.URL,
+		Description: rt.Description,
+		Owner:       rt.Owner,
+		CoOwners:    rt.CoOwners,
+		Tags:        rt.Tags,
+		Passthrough: rt.Passthrough,
+		ModifiedBy:  rt.ModifiedBy,
+		Created:     rt.Created,
+		Time:        rt.Time,
+		Revision:    rt.Revision,
+	}})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.w.Write(b)
+	return err
+}
+
+func (w *yamlWriter) Close() error {
+	return nil
+}
+
+// yamlReader reads the whole list on the first call to Read, since YAML
+// can't be decoded an item at a time.
+type yamlReader struct {
+	r       io.Reader
+	entries []*yamlEntry
+	read    bool
+	n       int
+}
+
+func (r *yamlReader) Read() (*Entry, error) {
+	if !r.read {
+		r.read = true
+		if err := yaml.NewDecoder(r.r).Decode(&r.entries); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+	}
+
+	if r.n >= len(r.entries) {
+		return nil, io.EOF
+	}
+
+	y := r.entries[r.n]
+	r.n++
+
+	if y == nil {
+		y = &yamlEntry{}
+	}
+
+	e := &Entry{
+		Name: y.Name,
+		Route: &internal.Route{
+			URL:         y.URL,
+			Description: y.Description,
+			Owner:       y.Owner,
+			CoOwners:    y.CoOwners,
+			Tags:        y.Tags,
+			Passthrough: y.Passthrough,
+			ModifiedBy:  y.ModifiedBy,
+			Created:     y.Created,
+			Time:        y.Time,
+			Revision:    y.Revision,
+		},
+	}
+
+	if err := validate(e, r.n); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
@@ -0,0 +1,17 @@
+package internal
+
+import "time"
+
+// Hits summarizes how often a route has been visited.
+type Hits struct {
+	Count int64     `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// Add merges the visits in o into these Hits.
+func (h *Hits) Add(o *Hits) {
+	h.Count += o.Count
+	if o.Last.After(h.Last) {
+		h.Last = o.Last
+	}
+}
@@ -0,0 +1,68 @@
+package migrate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint records how far a migration has gotten, so that one that is
+// interrupted can resume after the last route it copied.
+type Checkpoint struct {
+	// From describes the backend the routes are copied from.
+	From string `json:"from"`
+
+	// To describes the backend the routes are copied to.
+	To string `json:"to"`
+
+	// Last is the name of the last route copied.
+	Last string `json:"last"`
+
+	// Copied is how many routes have been copied.
+	Copied int `json:"copied"`
+}
+
+// ReadCheckpoint reads the checkpoint in the file.
+func ReadCheckpoint(filename string) (*Checkpoint, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Checkpoint
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Write the checkpoint to the file, replacing it in one step so that an
+// interruption never leaves a partial checkpoint behind.
+func (c *Checkpoint) Write(filename string) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	w, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(w.Name())
+
+	if _, err := w.Write(b); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Sync(); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(w.Name(), filename)
+}
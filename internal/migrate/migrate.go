@@ -0,0 +1,192 @@
+// Package migrate copies the routes of one backend into another.
+package migrate
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+)
+
+// CheckpointInterval is how many routes Routes copies between checkpoints.
+const CheckpointInterval = 100
+
+// How many of the routes that differ a Report names.
+const maxMismatches = 10
+
+// Routes copies the routes of src into dst in order of name, starting after
+// the route named after, or with the first route if after is empty. Routes are
+// stored exactly as they are in src, including their times, though dst gives
+// them revisions of its own. If save isn't nil, it is called with the name of
+// the last route copied and how many have been copied every
+// CheckpointInterval routes, and once more when all have been. Routes returns
+// how many routes it copied.
+func Routes(
+	ctx context.Context,
+	dst, src backend.Backend,
+	after string,
+	save func(last string, n int) error,
+) (int, error) {
+	iter, err := src.List(ctx, after)
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Release()
+
+	var n int
+	var last string
+	for iter.Next() {
+		name := iter.Name()
+		if after != "" && name == after {
+			continue
+		}
+
+		if err := dst.Put(ctx, name, iter.Route()); err != nil {
+			return n, err
+		}
+
+		n++
+		last = name
+
+		if save != nil && n%CheckpointInterval == 0 {
+			if err := save(last, n); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return n, err
+	}
+
+	if save != nil && n%CheckpointInterval != 0 {
+		if err := save(last, n); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// ID carries the ID counter of src over to dst, so that dst doesn't generate
+// any of the IDs src already has. It returns the last ID generated by src.
+func ID(ctx context.Context, dst, src backend.Backend) (uint64, error) {
+	id, err := src.LastID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, dst.AdvanceID(ctx, id)
+}
+
+// Report is the result of comparing the routes of two backends.
+type Report struct {
+	// Source is how many routes the source has.
+	Source int
+
+	// Target is how many routes the target has.
+	Target int
+
+	// Missing is how many of the source's routes the target doesn't have.
+	Missing int
+
+	// Changed is how many of the source's routes are different in the target.
+	Changed int
+
+	// Extra is how many routes the target has that the source doesn't.
+	Extra int
+
+	// Mismatches names the first of the routes that are missing, changed or
+	// extra.
+	Mismatches []string
+}
+
+// OK indicates whether the target has exactly the routes of the source.
+func (r *Report) OK() bool {
+	return r.Missing == 0 && r.Changed == 0 && r.Extra == 0 && r.Source == r.Target
+}
+
+func (r *Report) mismatch(name string) {
+	if len(r.Mismatches) < maxMismatches {
+		r.Mismatches = append(r.Mismatches, name)
+	}
+}
+
+// Verify compares the routes of dst with those of src, walking both in order
+// of name.
+func Verify(ctx context.Context, dst, src backend.Backend) (*Report, error) {
+	si, err := src.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	defer si.Release()
+
+	di, err := dst.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	defer di.Release()
+
+	var r Report
+
+	sok, dok := si.Next(), di.Next()
+	for sok || dok {
+		switch {
+		case !dok || (sok && si.Name() < di.Name()):
+			r.Source++
+			r.Missing++
+			r.mismatch(si.Name())
+			sok = si.Next()
+		case !sok || di.Name() < si.Name():
+			r.Target++
+			r.Extra++
+			r.mismatch(di.Name())
+			dok = di.Next()
+		default:
+			r.Source++
+			r.Target++
+			if !sameRoute(si.Route(), di.Route()) {
+				r.Changed++
+				r.mismatch(si.Name())
+			}
+			sok, dok = si.Next(), di.Next()
+		}
+	}
+
+	if err := si.Error(); err != nil {
+		return nil, err
+	}
+
+	if err := di.Error(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// Indicates whether the routes are the same, apart from their revisions.
+// Backends may return times in different locations and empty lists as nil, so
+// neither counts as a difference.
+func sameRoute(a, b *internal.Route) bool {
+	if !a.Time.Equal(b.Time) || !a.Created.Equal(b.Created) {
+		return false
+	}
+
+	x, y := normalize(a), normalize(b)
+	return reflect.DeepEqual(x, y)
+}
+
+func normalize(rt *internal.Route) internal.Route {
+	r := *rt
+	r.Revision = 0
+	r.Time = r.Time.UTC()
+	r.Created = r.Created.UTC()
+	if len(r.CoOwners) == 0 {
+		r.CoOwners = nil
+	}
+	if len(r.Tags) == 0 {
+		r.Tags = nil
+	}
+	return r
+}
@@ -0,0 +1,206 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend/memory"
+)
+
+func putRoutes(t *testing.T, ctx context.Context, b *memory.Backend, names ...string) {
+	t.Helper()
+	for i, name := range names {
+		if err := b.Put(ctx, name, &internal.Route{
+			URL:     "http://" + name + "/",
+			Time:    time.Date(2020, 1, 2, 3, 4, i, 0, time.UTC),
+			Created: time.Date(2019, 1, 2, 3, 4, i, 0, time.UTC),
+			Owner:   "alice",
+			Tags:    []string{"t"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	ctx := context.Background()
+
+	src, dst := memory.New(), memory.New()
+
+	var names []string
+	for i := 0; i < CheckpointInterval+5; i++ {
+		names = append(names, fmt.Sprintf("r%03d", i))
+	}
+	putRoutes(t, ctx, src, names...)
+
+	type save struct {
+		last string
+		n    int
+	}
+
+	var saves []save
+	n, err := Routes(ctx, dst, src, "", func(last string, n int) error {
+		saves = append(saves, save{last, n})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != len(names) {
+		t.Fatalf("expected %d routes to be copied, got %d", len(names), n)
+	}
+
+	expected := []save{
+		{names[CheckpointInterval-1], CheckpointInterval},
+		{names[len(names)-1], len(names)},
+	}
+	if !reflect.DeepEqual(saves, expected) {
+		t.Fatalf("expected checkpoints %v, got %v", expected, saves)
+	}
+
+	a, err := src.Get(ctx, "r001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := dst.Get(ctx, "r001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !b.Time.Equal(a.Time) || !b.Created.Equal(a.Created) || b.Owner != a.Owner {
+		t.Fatalf("expected %+v, got %+v", a, b)
+	}
+
+	report, err := Verify(ctx, dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.OK() || report.Source != len(names) || report.Target != len(names) {
+		t.Fatalf("expected the routes to match, got %+v", report)
+	}
+}
+
+func TestRoutesResume(t *testing.T) {
+	ctx := context.Background()
+
+	src, dst := memory.New(), memory.New()
+	putRoutes(t, ctx, src, "a", "b", "c", "d")
+
+	n, err := Routes(ctx, dst, src, "b", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 {
+		t.Fatalf("expected 2 routes to be copied, got %d", n)
+	}
+
+	all, err := dst.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := all["c"]; !ok || len(all) != 2 {
+		t.Fatalf("expected only c and d to be copied, got %v", all)
+	}
+}
+
+func TestID(t *testing.T) {
+	ctx := context.Background()
+
+	src, dst := memory.New(), memory.New()
+	for i := 0; i < 3; i++ {
+		if _, err := src.NextID(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	id, err := ID(ctx, dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 3 {
+		t.Fatalf("expected id 3, got %d", id)
+	}
+
+	if id, err := dst.NextID(ctx); err != nil {
+		t.Fatal(err)
+	} else if id != 4 {
+		t.Fatalf("expected the next id to be 4, got %d", id)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+
+	src, dst := memory.New(), memory.New()
+	putRoutes(t, ctx, src, "a", "b", "c")
+	putRoutes(t, ctx, dst, "a", "b", "d")
+
+	// the same route at another revision with an empty list is still the same.
+	if err := dst.Put(ctx, "a", &internal.Route{
+		URL:      "http://a/",
+		Time:     time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC).In(time.FixedZone("x", 3600)),
+		Created:  time.Date(2019, 1, 2, 3, 4, 0, 0, time.UTC),
+		Owner:    "alice",
+		Tags:     []string{"t"},
+		CoOwners: []string{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dst.Put(ctx, "b", &internal.Route{URL: "http://changed/"}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(ctx, dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Report{
+		Source:     3,
+		Target:     3,
+		Missing:    1,
+		Changed:    1,
+		Extra:      1,
+		Mismatches: []string{"b", "c", "d"},
+	}
+
+	if report.OK() || !reflect.DeepEqual(report, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, report)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "checkpoint")
+
+	c := &Checkpoint{
+		From:   "leveldb:/data",
+		To:     "firestore:project",
+		Last:   "b",
+		Copied: 2,
+	}
+
+	if err := c.Write(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ReadCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(r, c) {
+		t.Fatalf("expected %+v, got %+v", c, r)
+	}
+}
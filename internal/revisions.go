@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// Revision records a single change to a route.
+type Revision struct {
+	// ID identifies the revision among those of the same route. IDs start at 1
+	// and increase with each change. It is signed since firestore can only
+	// store signed integers.
+	ID int64 `json:"id"`
+
+	Time time.Time `json:"time"`
+	By   string    `json:"by,omitempty"`
+
+	OldURL string `json:"old_url,omitempty"`
+	NewURL string `json:"new_url,omitempty"`
+
+	// Route is the route as it was after the change. It is nil if the change
+	// deleted the route.
+	Route *Route `json:"route,omitempty"`
+}
+
+type actorKey struct{}
+
+// WithActor returns a context that attributes any changes made with it to the
+// given actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor associated with the context, if any.
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
@@ -1,43 +1,137 @@
 package internal
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"time"
 )
 
+// Passthrough determines what happens to the path and query that follow the
+// name of a shortcut when it is visited.
+type Passthrough string
+
+const (
+	// PassthroughAppend appends the path and query to the URL of the route.
+	PassthroughAppend Passthrough = "append"
+
+	// PassthroughIgnore discards the path and query.
+	PassthroughIgnore Passthrough = "ignore"
+
+	// PassthroughReject refuses to redirect when a path or query is present.
+	PassthroughReject Passthrough = "reject"
+)
+
+// Valid indicates whether this is a known Passthrough value. The empty value
+// is valid and is treated as PassthroughAppend.
+func (p Passthrough) Valid() bool {
+	switch p {
+	case "", PassthroughAppend, PassthroughIgnore, PassthroughReject:
+		return true
+	}
+	return false
+}
+
 // Route is the value part of a shortcut.
 type Route struct {
-	URL  string    `json:"url"`
+	URL string `json:"url"`
+
+	// Time is when the route was last updated.
 	Time time.Time `json:"time"`
+
+	Passthrough Passthrough `json:"passthrough,omitempty"`
+
+	// Owner is the person or team responsible for the route. Only the owner
+	// may change who owns it.
+	Owner string `json:"owner,omitempty"`
+
+	// CoOwners are other people or groups who may change the route.
+	CoOwners []string `json:"co_owners,omitempty"`
+
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+
+	// Created is when the route was first created.
+	Created time.Time `json:"created"`
+
+	// ModifiedBy is the identity of whoever last updated the route.
+	ModifiedBy string `json:"modified_by,omitempty"`
+
+	// Revision is the ID of the revision that produced this version of the
+	// route. It is assigned by the backend on every change and can be used
+	// to detect that the route changed since it was read. Routes last
+	// written before revisions were tracked have a Revision of 0.
+	Revision int64 `json:"revision"`
 }
 
-// RouteIterator allows iteration of the named routes in the store.
+// RouteIterator allows iteration of the named routes in the store, in order of
+// name. An iterator starts before the first route, so Next must be called
+// before Name and Route.
 type RouteIterator interface {
 	Valid() bool
+
+	// Next advances to the next route, returning false when there are no
+	// more routes or an error stopped the iteration.
 	Next() bool
+
+	// Seek moves to the first route whose name is at or after the given name,
+	// but never before where the iteration started. It returns false if there
+	// is no such route. Either way, Next continues from there.
 	Seek(string) bool
+
 	Error() error
+
+	// Name and Route describe the current route. They are only meaningful
+	// while the iterator is valid.
 	Name() string
 	Route() *Route
+
 	Release()
 }
 
 var ErrRouteNotFound = errors.New("route not found")
 
+var ErrRouteExists = errors.New("route already exists")
+
+// ErrRevisionMismatch is returned by conditional changes when the route is no
+// longer at the expected revision.
+var ErrRevisionMismatch = errors.New("route revision mismatch")
+
+// RouteVersion is the current version of the serialized form of a Route. The
+// payload of a version 1 record is the JSON encoding of the Route, so fields
+// added later are simply ignored by older readers.
+const RouteVersion byte = 1
+
+// Routes were originally serialized as a little-endian timestamp followed by
+// the URL. Versioned records begin with this sentinel where the timestamp
+// would be, which no legacy record can contain, followed by a version byte.
+const routeSentinel int64 = math.MinInt64
+
+// NeedsUpgrade indicates whether the serialized route in b was written in a
+// format older than RouteVersion and should be rewritten.
+func NeedsUpgrade(b []byte) bool {
+	if len(b) < 9 || int64(binary.LittleEndian.Uint64(b)) != routeSentinel {
+		return true
+	}
+	return b[8] < RouteVersion
+}
+
 // Serialize this Route into the given Writer.
 func (o *Route) Write(w io.Writer) error {
-	if err := binary.Write(w, binary.LittleEndian, o.Time.UnixNano()); err != nil {
+	if err := binary.Write(w, binary.LittleEndian, routeSentinel); err != nil {
 		return err
 	}
 
-	if _, err := w.Write([]byte(o.URL)); err != nil {
+	if _, err := w.Write([]byte{RouteVersion}); err != nil {
 		return err
 	}
 
-	return nil
+	return json.NewEncoder(w).Encode(o)
 }
 
 // Deserialize this Route from the given Reader.
@@ -47,12 +141,42 @@ func (o *Route) Read(r io.Reader) error {
 		return err
 	}
 
+	if t != routeSentinel {
+		return o.readLegacy(t, r)
+	}
+
+	var v [1]byte
+	if _, err := io.ReadFull(r, v[:]); err != nil {
+		return err
+	}
+
+	switch v[0] {
+	case 1:
+		*o = Route{}
+		return json.NewDecoder(r).Decode(o)
+	default:
+		return fmt.Errorf("unknown route version: %d", v[0])
+	}
+}
+
+// Deserialize the remainder of a Route that was written before records were
+// versioned. The timestamp has already been read. The URL may be followed by a
+// zero byte and the Passthrough.
+func (o *Route) readLegacy(t int64, r io.Reader) error {
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
 	}
 
-	o.URL = string(b)
-	o.Time = time.Unix(0, t)
+	u, p, _ := bytes.Cut(b, []byte{0})
+
+	// legacy records never tracked a creation time, so the best we can do is
+	// the last update.
+	*o = Route{
+		URL:         string(u),
+		Time:        time.Unix(0, t),
+		Passthrough: Passthrough(p),
+		Created:     time.Unix(0, t),
+	}
 	return nil
 }
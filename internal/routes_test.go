@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRouteReadWrite(t *testing.T) {
+	a := &Route{
+		URL:         "http://www.kellegous.com/",
+		Time:        time.Unix(0, 420),
+		Passthrough: PassthroughReject,
+		Owner:       "kellegous",
+		CoOwners:    []string{"knorton", "eng"},
+		Description: "a blog",
+		Tags:        []string{"blog", "personal"},
+		Created:     time.Unix(0, 42),
+		ModifiedBy:  "knorton",
+	}
+
+	var buf bytes.Buffer
+	if err := a.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var b Route
+	if err := b.Read(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.URL != a.URL ||
+		!b.Time.Equal(a.Time) ||
+		b.Passthrough != a.Passthrough ||
+		b.Owner != a.Owner ||
+		!reflect.DeepEqual(b.CoOwners, a.CoOwners) ||
+		b.Description != a.Description ||
+		!reflect.DeepEqual(b.Tags, a.Tags) ||
+		!b.Created.Equal(a.Created) ||
+		b.ModifiedBy != a.ModifiedBy {
+		t.Fatalf("expected %v, got %v", a, b)
+	}
+}
+
+func TestRouteReadLegacy(t *testing.T) {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, int64(420)); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("http://www.kellegous.com/")
+
+	var rt Route
+	if err := rt.Read(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.URL != "http://www.kellegous.com/" {
+		t.Fatalf("expected URL of http://www.kellegous.com/, got %s", rt.URL)
+	}
+
+	if !rt.Time.Equal(time.Unix(0, 420)) {
+		t.Fatalf("expected Time of %s, got %s", time.Unix(0, 420), rt.Time)
+	}
+
+	if !rt.Created.Equal(rt.Time) {
+		t.Fatalf("expected Created of %s, got %s", rt.Time, rt.Created)
+	}
+
+	if rt.Passthrough != "" {
+		t.Fatalf("expected empty passthrough, got %s", rt.Passthrough)
+	}
+
+	// records that predate versioning may follow the URL with a passthrough.
+	buf.Reset()
+	if err := binary.Write(&buf, binary.LittleEndian, int64(420)); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("http://www.kellegous.com/\x00ignore")
+
+	if err := rt.Read(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.URL != "http://www.kellegous.com/" || rt.Passthrough != PassthroughIgnore {
+		t.Fatalf("expected http://www.kellegous.com/ with passthrough ignore, got %v", rt)
+	}
+}
+
+func TestNeedsUpgrade(t *testing.T) {
+	var legacy bytes.Buffer
+	if err := binary.Write(&legacy, binary.LittleEndian, time.Now().UnixNano()); err != nil {
+		t.Fatal(err)
+	}
+	legacy.WriteString("http://www.kellegous.com/")
+
+	if !NeedsUpgrade(legacy.Bytes()) {
+		t.Fatal("expected legacy record to need an upgrade")
+	}
+
+	var current bytes.Buffer
+	if err := (&Route{URL: "http://www.kellegous.com/"}).Write(&current); err != nil {
+		t.Fatal(err)
+	}
+
+	if NeedsUpgrade(current.Bytes()) {
+		t.Fatal("expected current record not to need an upgrade")
+	}
+}
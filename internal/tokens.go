@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+// Token is a personal API token. Only a hash of the token's secret is kept.
+type Token struct {
+	// ID identifies the token and is the public part of the token's value.
+	ID string `json:"id"`
+
+	// Name describes what the token is used for.
+	Name string `json:"name"`
+
+	// Owner is the identity that created the token and on whose behalf it
+	// acts. The owner's email and groups are captured when the token is
+	// created.
+	Owner  string   `json:"owner"`
+	Email  string   `json:"email,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+
+	// Scopes limit what the token may be used for.
+	Scopes []string `json:"scopes"`
+
+	Created time.Time `json:"created"`
+
+	// Hash is the SHA-256 hash of the token's secret.
+	Hash []byte `json:"hash"`
+}
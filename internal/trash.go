@@ -0,0 +1,12 @@
+package internal
+
+import "time"
+
+// TrashedRoute is a route that has been deleted but can still be restored
+// until it is purged.
+type TrashedRoute struct {
+	Name      string    `json:"name"`
+	Route     *Route    `json:"route"`
+	Deleted   time.Time `json:"deleted"`
+	DeletedBy string    `json:"deleted_by,omitempty"`
+}
@@ -1,18 +1,172 @@
 package web
 
 import (
+	"bufio"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/auth"
 	"github.com/kellegous/go/internal/backend"
+	"github.com/kellegous/go/internal/dump"
 )
 
+// How long a dump may take before it is abandoned.
+const dumpTimeout = 10 * time.Minute
+
 type adminHandler struct {
 	backend backend.Backend
+	audit   *auditLog
+}
+
+// Indicates whether the client will accept a response compressed with gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(enc, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
+
+// Write every route in the iterator to w as a single JSON object that maps
+// names to routes.
+func writeJSONDump(w io.Writer, iter internal.RouteIterator) error {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteByte('{')
+	for n := 0; iter.Next(); n++ {
+		name, err := json.Marshal(iter.Name())
+		if err != nil {
+			return err
+		}
+
+		rt, err := json.Marshal(iter.Route())
+		if err != nil {
+			return err
+		}
+
+		if n > 0 {
+			bw.WriteByte(',')
+		}
+		bw.Write(name)
+		bw.WriteByte(':')
+		bw.Write(rt)
+	}
+	bw.WriteString("}\n")
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	return bw.Flush()
 }
 
-func adminGet(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
+// Write every route in the iterator to w in one of the export formats.
+func writeDump(w io.Writer, iter internal.RouteIterator, f dump.Format) error {
+	bw := bufio.NewWriter(w)
+
+	dw, err := dump.NewWriter(bw, f)
+	if err != nil {
+		return err
+	}
+
+	if _, err := dump.WriteAll(dw, iter); err != nil {
+		return err
+	}
+
+	if err := dw.Close(); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// Stream every route to the client, in order of name, as they all were when
+// the dump began.
+func adminDump(backend backend.Backend, audit *auditLog, w http.ResponseWriter, r *http.Request) {
+	if id := auth.FromContext(r.Context()); id != nil && !isAdmin(id) {
+		writeJSONError(w, "only admins can dump routes", http.StatusForbidden)
+		return
+	}
+
+	// the default is the original object of routes keyed by name.
+	var f dump.Format
+	if v := r.FormValue("format"); v != "" && v != "json" {
+		var err error
+		if f, err = dump.ParseFormat(v); err != nil {
+			writeJSONError(w, "invalid format value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), dumpTimeout)
+	defer cancel()
+
+	iter, err := backend.Dump(ctx)
+	if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+	defer iter.Release()
+
+	audit.record(ctx, internal.AuditDump, "", "")
+
+	if f != "" {
+		w.Header().Set("Content-Type", f.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="dump.%s"`, f))
+	} else {
+		w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	}
+	w.Header().Set("Vary", "Accept-Encoding")
+
+	var gz *gzip.Writer
+	var out io.Writer = w
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+
+	write := writeJSONDump
+	if f != "" {
+		write = func(w io.Writer, iter internal.RouteIterator) error {
+			return writeDump(w, iter, f)
+		}
+	}
+
+	// once the response has started, the only way to tell the client that it
+	// is incomplete is to abort it.
+	if err := write(out, iter); err != nil {
+		log.Printf("[error] dumping routes: %s", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			log.Printf("[error] dumping routes: %s", err)
+		}
+	}
+}
+
+func adminGet(backend backend.Backend, audit *auditLog, w http.ResponseWriter, r *http.Request) {
 	p := parseName("/admin/", r.URL.Path)
 
 	if p == "" {
@@ -20,16 +174,8 @@ func adminGet(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	defer cancel()
-
 	if p == "dumps" {
-		if golinks, err := backend.GetAll(ctx); err != nil {
-			writeJSONBackendError(w, err)
-			return
-		} else {
-			writeJSON(w, golinks, http.StatusOK)
-		}
+		adminDump(backend, audit, w, r)
 	}
 
 }
@@ -37,7 +183,7 @@ func adminGet(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
 func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		adminGet(h.backend, w, r)
+		adminGet(h.backend, h.audit, w, r)
 	default:
 		writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusOK) // fix
 	}
@@ -7,11 +7,13 @@ import (
 	"errors"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/auth"
 	"github.com/kellegous/go/internal/backend"
 )
 
@@ -75,11 +77,35 @@ func validateURL(r *http.Request, s string) error {
 	return nil
 }
 
-func apiURLPost(backend backend.Backend, host string, w http.ResponseWriter, r *http.Request) {
+// Normalize a list of tags or owners by trimming whitespace and dropping
+// empty and duplicate entries.
+func normalizeList(tags []string) []string {
+	seen := map[string]bool{}
+	res := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		res = append(res, tag)
+	}
+	return res
+}
+
+func apiURLPost(backend backend.Backend, audit *auditLog, host string, w http.ResponseWriter, r *http.Request) {
 	p := parseName("/api/url/", r.URL.Path)
 
+	// Optional fields are pointers (or nil slices) so that a missing field
+	// leaves the existing value of the route untouched.
 	var req struct {
-		URL string `json:"url"`
+		URL         string               `json:"url"`
+		Passthrough internal.Passthrough `json:"passthrough"`
+		Owner       *string              `json:"owner"`
+		CoOwners    []string             `json:"co_owners"`
+		Description *string              `json:"description"`
+		Tags        []string             `json:"tags"`
+		Revision    *int64               `json:"revision"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -87,45 +113,142 @@ func apiURLPost(backend backend.Backend, host string, w http.ResponseWriter, r *
 		return
 	}
 
+	// the change can be made conditional on the route not having changed
+	// since it was read or on there being no route at all.
+	cond, err := parsePrecondition(r, req.Revision)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if req.URL == "" {
 		writeJSONError(w, "url required", http.StatusBadRequest)
 		return
 	}
 
+	if req.Owner != nil && strings.TrimSpace(*req.Owner) == "" {
+		writeJSONError(w, "owner cannot be empty", http.StatusBadRequest)
+		return
+	}
+
 	if isBannedName(p) {
 		writeJSONError(w, "name cannot be used", http.StatusBadRequest)
 		return
 	}
 
-	if err := validateURL(r, req.URL); err != nil {
+	if err := validateTemplate(r, req.URL); err != nil {
 		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	if !req.Passthrough.Valid() {
+		writeJSONError(w, "invalid passthrough", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
 	defer cancel()
 
+	id := auth.FromContext(ctx)
+
+	now := time.Now()
+	rt := internal.Route{
+		Created: now,
+	}
+	action := internal.AuditCreate
+
+	var cur *internal.Route
+
 	// If no name is specified, an ID must be generated.
 	if p == "" {
-		var err error
 		p, err = nextEncodedID(ctx, backend)
 		if err != nil {
 			writeJSONBackendError(w, err)
 			return
 		}
+	} else if old, err := backend.Get(ctx, p); err == nil {
+		cur = old
+		rt = *old
+		action = internal.AuditUpdate
+	} else if !errors.Is(err, internal.ErrRouteNotFound) {
+		writeJSONBackendError(w, err)
+		return
 	}
 
-	rt := internal.Route{
-		URL:  req.URL,
-		Time: time.Now(),
+	if !cond.matches(cur) {
+		writeJSONError(w, cond.failure(), cond.status)
+		return
+	}
+
+	if !canEdit(id, &rt) {
+		writeJSONError(w, errMsgNotEditor, http.StatusForbidden)
+		return
+	}
+
+	owner := rt.Owner
+	if req.Owner != nil {
+		owner = strings.TrimSpace(*req.Owner)
+	} else if cur == nil && id != nil {
+		// whoever creates a route owns it. Routes that predate ownership stay
+		// unowned until someone takes them by giving an owner, rather than
+		// belonging to whoever happens to change them first.
+		owner = id.Name
+	}
+
+	coOwners := rt.CoOwners
+	if req.CoOwners != nil {
+		coOwners = normalizeList(req.CoOwners)
+	}
+
+	if (owner != rt.Owner || !sameOwners(coOwners, rt.CoOwners)) && !canTransfer(id, &rt) {
+		writeJSONError(w, errMsgNotOwner, http.StatusForbidden)
+		return
+	}
+
+	// routes that predate creation times were, at the latest, created when
+	// they were last updated.
+	if rt.Created.IsZero() {
+		rt.Created = rt.Time
+	}
+
+	rt.URL = req.URL
+	rt.Time = now
+	rt.ModifiedBy = internal.ActorFrom(ctx)
+
+	if req.Passthrough != "" {
+		rt.Passthrough = req.Passthrough
+	} else if rt.Passthrough == "" {
+		rt.Passthrough = internal.PassthroughAppend
+	}
+
+	rt.Owner = owner
+	rt.CoOwners = coOwners
+
+	if req.Description != nil {
+		rt.Description = strings.TrimSpace(*req.Description)
 	}
 
-	if err := backend.Put(ctx, p, &rt); err != nil {
+	if req.Tags != nil {
+		rt.Tags = normalizeList(req.Tags)
+	}
+
+	// the change is only made if the route is still the one that was checked,
+	// whether or not the request gave a precondition.
+	if err := putUnchanged(ctx, backend, p, cur, &rt); isChanged(err) {
+		if cond != nil {
+			writeJSONError(w, cond.failure(), cond.status)
+		} else {
+			writeJSONError(w, errMsgRevisionMismatch, http.StatusConflict)
+		}
+		return
+	} else if err != nil {
 		writeJSONBackendError(w, err)
 		return
 	}
 
-	writeJSONRoute(w, p, &rt, host)
+	audit.record(ctx, action, p, rt.URL)
+
+	writeJSONRoute(w, p, &rt, host, nil)
 }
 
 func apiURLGet(backend backend.Backend, host string, w http.ResponseWriter, r *http.Request) {
@@ -136,7 +259,7 @@ func apiURLGet(backend backend.Backend, host string, w http.ResponseWriter, r *h
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
 	defer cancel()
 
 	rt, err := backend.Get(ctx, p)
@@ -148,10 +271,21 @@ func apiURLGet(backend backend.Backend, host string, w http.ResponseWriter, r *h
 		return
 	}
 
-	writeJSONRoute(w, p, rt, host)
+	hits, err := backend.GetHits(ctx, []string{p})
+	if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	h := hits[p]
+	if h == nil {
+		h = &internal.Hits{}
+	}
+
+	writeJSONRoute(w, p, rt, host, h)
 }
 
-func apiURLDelete(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
+func apiURLDelete(backend backend.Backend, audit *auditLog, w http.ResponseWriter, r *http.Request) {
 	p := parseName("/api/url/", r.URL.Path)
 
 	if p == "" {
@@ -159,14 +293,30 @@ func apiURLDelete(backend backend.Backend, w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
 	defer cancel()
 
+	rt, err := backend.Get(ctx, p)
+	if errors.Is(err, internal.ErrRouteNotFound) {
+		writeJSONOk(w)
+		return
+	} else if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	if !canEdit(auth.FromContext(ctx), rt) {
+		writeJSONError(w, errMsgNotEditor, http.StatusForbidden)
+		return
+	}
+
 	if err := backend.Del(ctx, p); err != nil {
 		writeJSONBackendError(w, err)
 		return
 	}
 
+	audit.record(ctx, internal.AuditDelete, p, rt.URL)
+
 	writeJSONOk(w)
 }
 
@@ -227,20 +377,34 @@ func apiURLsGet(backend backend.Backend, host string, w http.ResponseWriter, r *
 		return
 	}
 
+	// only routes whose names start with prefix are listed.
+	prefix := r.FormValue("prefix")
+
+	switch r.FormValue("sort") {
+	case "", "name":
+	case "hits", "last-hit":
+		apiURLsGetByHits(backend, host, w, r, string(c), lim, ig, prefix)
+		return
+	default:
+		writeJSONError(w, "invalid sort value", http.StatusBadRequest)
+		return
+	}
+
 	res := msgRoutes{
 		Ok: true,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
 	defer cancel()
 
-	iter, err := backend.List(ctx, string(c))
+	iter, err := backend.List(ctx, max(string(c), prefix))
 	if err != nil {
 		writeJSONBackendError(w, err)
 		return
 	}
 	defer iter.Release()
 
+	more := false
 	for iter.Next() {
 		// if we should be ignoring generated links, skip over that range.
 		if !ig && isGenerated(iter.Name()) {
@@ -250,6 +414,16 @@ func apiURLsGet(backend backend.Backend, host string, w http.ResponseWriter, r *
 			}
 		}
 
+		// names are in order, so none of the rest have the prefix either.
+		if !strings.HasPrefix(iter.Name(), prefix) {
+			break
+		}
+
+		if len(res.Routes) == lim {
+			more = true
+			break
+		}
+
 		r := routeWithName{
 			Name:  iter.Name(),
 			Route: iter.Route(),
@@ -260,14 +434,103 @@ func apiURLsGet(backend backend.Backend, host string, w http.ResponseWriter, r *
 		}
 
 		res.Routes = append(res.Routes, &r)
+	}
 
-		if len(res.Routes) == lim {
-			break
+	if more {
+		res.Next = base64.URLEncoding.EncodeToString([]byte(iter.Name()))
+	}
+
+	if err := iter.Error(); err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	if err := addHits(ctx, backend, res.Routes); err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	writeJSON(w, &res, http.StatusOK)
+}
+
+// Attach the visits of each of the given routes.
+func addHits(ctx context.Context, backend backend.Backend, rts []*routeWithName) error {
+	names := make([]string, 0, len(rts))
+	for _, rt := range rts {
+		names = append(names, rt.Name)
+	}
+
+	hits, err := backend.GetHits(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	attachHits(rts, hits)
+	return nil
+}
+
+// Attach the visits in hits to each of the given routes. Routes that have not
+// been visited get empty Hits.
+func attachHits(rts []*routeWithName, hits map[string]*internal.Hits) {
+	for _, rt := range rts {
+		rt.Hits = hits[rt.Name]
+		if rt.Hits == nil {
+			rt.Hits = &internal.Hits{}
 		}
 	}
+}
 
-	if iter.Next() {
-		res.Next = base64.URLEncoding.EncodeToString([]byte(iter.Name()))
+// List routes ordered by how often, or how recently, they have been visited
+// with the most visited first. The backends only order routes by name, so this
+// has to visit every route and the cursor is simply an offset into the sorted
+// list.
+func apiURLsGetByHits(
+	backend backend.Backend,
+	host string,
+	w http.ResponseWriter,
+	r *http.Request,
+	cursor string,
+	lim int,
+	ig bool,
+	prefix string,
+) {
+	off, err := parseInt(cursor, 0)
+	if err != nil || off < 0 {
+		writeJSONError(w, "invalid cursor value", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	iter, err := backend.List(ctx, prefix)
+	if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+	defer iter.Release()
+
+	var rts []*routeWithName
+	for iter.Next() {
+		// if we should be ignoring generated links, skip over that range.
+		if !ig && isGenerated(iter.Name()) {
+			iter.Seek(string(postGenCursor))
+			if !iter.Valid() {
+				break
+			}
+		}
+
+		if !strings.HasPrefix(iter.Name(), prefix) {
+			break
+		}
+
+		rt := routeWithName{
+			Name:       iter.Name(),
+			SourceHost: host,
+			Route:      iter.Route(),
+		}
+
+		rts = append(rts, &rt)
 	}
 
 	if err := iter.Error(); err != nil {
@@ -275,17 +538,63 @@ func apiURLsGet(backend backend.Backend, host string, w http.ResponseWriter, r *
 		return
 	}
 
+	hits, err := backend.GetAllHits(ctx)
+	if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+	attachHits(rts, hits)
+
+	byLast := r.FormValue("sort") == "last-hit"
+	sort.SliceStable(rts, func(i, j int) bool {
+		if byLast {
+			return rts[i].Hits.Last.After(rts[j].Hits.Last)
+		}
+		return rts[i].Hits.Count > rts[j].Hits.Count
+	})
+
+	res := msgRoutes{
+		Ok: true,
+	}
+
+	if off < len(rts) {
+		res.Routes = rts[off:min(off+lim, len(rts))]
+	}
+
+	if off+lim < len(rts) {
+		res.Next = base64.URLEncoding.EncodeToString([]byte(strconv.Itoa(off + lim)))
+	}
+
 	writeJSON(w, &res, http.StatusOK)
 }
 
-func apiURL(backend backend.Backend, host string, w http.ResponseWriter, r *http.Request) {
+func apiURL(backend backend.Backend, audit *auditLog, host string, w http.ResponseWriter, r *http.Request) {
+	switch parseSubresource("/api/url/", r.URL.Path) {
+	case "":
+	case "history":
+		apiURLHistory(backend, w, r)
+		return
+	case "revert":
+		apiURLRevert(backend, audit, host, w, r)
+		return
+	case "stats":
+		apiURLStats(backend, w, r)
+		return
+	case "owner":
+		apiURLOwner(backend, audit, w, r)
+		return
+	default:
+		writeJSONError(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
 	switch r.Method {
 	case "POST":
-		apiURLPost(backend, host, w, r)
+		apiURLPost(backend, audit, host, w, r)
 	case "GET":
 		apiURLGet(backend, host, w, r)
 	case "DELETE":
-		apiURLDelete(backend, w, r)
+		apiURLDelete(backend, audit, w, r)
 	default:
 		writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusOK) // fix
 	}
@@ -302,11 +611,29 @@ func apiURLs(backend backend.Backend, host string, w http.ResponseWriter, r *htt
 
 // Setup ...
 func Setup(m *http.ServeMux, backend backend.Backend, host string) {
+	audit := newAuditLog(backend, nil)
+
 	m.HandleFunc("/api/url/", func(w http.ResponseWriter, r *http.Request) {
-		apiURL(backend, host, w, r)
+		apiURL(backend, audit, host, w, r)
 	})
 
 	m.HandleFunc("/api/urls/", func(w http.ResponseWriter, r *http.Request) {
 		apiURLs(backend, host, w, r)
 	})
+
+	m.HandleFunc("/api/tokens/", func(w http.ResponseWriter, r *http.Request) {
+		apiTokens(backend, audit, w, r)
+	})
+
+	m.HandleFunc("/api/audit", func(w http.ResponseWriter, r *http.Request) {
+		apiAudit(backend, w, r)
+	})
+
+	m.HandleFunc("/api/import", func(w http.ResponseWriter, r *http.Request) {
+		apiImport(backend, audit, w, r)
+	})
+
+	m.HandleFunc("/api/trash/", func(w http.ResponseWriter, r *http.Request) {
+		apiTrash(backend, audit, defaultTrashRetention, host, w, r)
+	})
 }
@@ -8,17 +8,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/kellegous/go/internal"
 	"github.com/kellegous/go/internal/backend"
-	"github.com/kellegous/go/internal/backend/leveldb"
+	"github.com/kellegous/go/internal/backend/memory"
 )
 
 type urlReq struct {
@@ -27,12 +25,12 @@ type urlReq struct {
 
 type env struct {
 	mux     *http.ServeMux
-	dir     string
 	backend backend.Backend
+	hits    *hitCounter
 }
 
 func (e *env) destroy() {
-	os.RemoveAll(e.dir)
+	e.backend.Close()
 }
 
 func (e *env) get(path string) (*mockResponse, error) {
@@ -73,16 +71,7 @@ func (e *env) call(method, path string, body io.Reader) (*mockResponse, error) {
 }
 
 func newEnv(host string) (*env, error) {
-	dir, err := ioutil.TempDir("", "")
-	if err != nil {
-		return nil, err
-	}
-
-	backend, err := leveldb.New(filepath.Join(dir, "data"))
-	if err != nil {
-		os.RemoveAll(dir)
-		return nil, err
-	}
+	backend := memory.New()
 
 	mux := http.NewServeMux()
 
@@ -90,8 +79,8 @@ func newEnv(host string) (*env, error) {
 
 	return &env{
 		mux:     mux,
-		dir:     dir,
 		backend: backend,
+		hits:    newHitCounter(backend),
 	}, nil
 }
 
@@ -218,6 +207,10 @@ func TestAPIPutThenGet(t *testing.T) {
 	mustBeOk(t, pm.Ok)
 	mustBeNamedRouteOf(t, pm.Route, "xxx", "http://ex.com/", "")
 
+	if pm.Route.Passthrough != internal.PassthroughAppend {
+		t.Fatalf("expected passthrough of %s, got %s", internal.PassthroughAppend, pm.Route.Passthrough)
+	}
+
 	res, err = e.get("/api/url/xxx")
 	if err != nil {
 		t.Fatal(err)
@@ -310,6 +303,188 @@ func TestBadPuts(t *testing.T) {
 		t.Fatal(err)
 	}
 	mustBeErr(t, &m)
+
+	res, err = e.post("/api/url/yyy", map[string]string{
+		"url":         "http://ex.com/",
+		"passthrough": "sometimes",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusBadRequest)
+
+	if err := json.NewDecoder(res).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	mustBeErr(t, &m)
+}
+
+func TestAPIPutTemplate(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	res, err := e.post("/api/url/gh", &urlReq{"https://github.com/{1}/{2}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	var pm msgRoute
+	if err := json.NewDecoder(res).Decode(&pm); err != nil {
+		t.Fatal(err)
+	}
+	mustBeOk(t, pm.Ok)
+	mustBeNamedRouteOf(t, pm.Route, "gh", "https://github.com/{1}/{2}", "")
+
+	res, err = e.post("/api/url/gh", &urlReq{"https://{1}.github.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusBadRequest)
+
+	var m msgErr
+	if err := json.NewDecoder(res).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	mustBeErr(t, &m)
+}
+
+func TestAPIPutKeepsPassthrough(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	res, err := e.post("/api/url/xxx", map[string]string{
+		"url":         "http://ex.com/",
+		"passthrough": "ignore",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	res, err = e.post("/api/url/xxx", &urlReq{URL: "http://ex.com/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	var pm msgRoute
+	if err := json.NewDecoder(res).Decode(&pm); err != nil {
+		t.Fatal(err)
+	}
+	mustBeOk(t, pm.Ok)
+	mustBeNamedRouteOf(t, pm.Route, "xxx", "http://ex.com/a", "")
+
+	if pm.Route.Passthrough != internal.PassthroughIgnore {
+		t.Fatalf("expected passthrough of %s, got %s", internal.PassthroughIgnore, pm.Route.Passthrough)
+	}
+
+	// the passthrough is stored along with the route.
+	res, err = e.redirect("/xxx/extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustRedirectTo(t, res, "http://ex.com/a")
+
+	res, err = e.post("/api/url/yyy", map[string]string{
+		"url":         "http://ex.com/",
+		"passthrough": "reject",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	res, err = e.redirect("/yyy/extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusNotFound)
+}
+
+func TestAPIPutMetadata(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	res, err := e.post("/api/url/xxx", map[string]interface{}{
+		"url":         "http://ex.com/",
+		"owner":       "kellegous",
+		"description": " an example ",
+		"tags":        []string{"a", " b", "", "a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	var am msgRoute
+	if err := json.NewDecoder(res).Decode(&am); err != nil {
+		t.Fatal(err)
+	}
+	mustBeOk(t, am.Ok)
+	mustBeNamedRouteOf(t, am.Route, "xxx", "http://ex.com/", "")
+
+	if am.Route.Owner != "kellegous" {
+		t.Fatalf("expected owner of kellegous, got %s", am.Route.Owner)
+	}
+
+	if am.Route.Description != "an example" {
+		t.Fatalf("expected description of \"an example\", got \"%s\"", am.Route.Description)
+	}
+
+	if !reflect.DeepEqual(am.Route.Tags, []string{"a", "b"}) {
+		t.Fatalf("expected tags of [a b], got %v", am.Route.Tags)
+	}
+
+	if !am.Route.Created.Equal(am.Route.Time) {
+		t.Fatalf("expected created of %s, got %s", am.Route.Time, am.Route.Created)
+	}
+
+	res, err = e.post("/api/url/xxx", map[string]interface{}{
+		"url":  "http://ex.com/a",
+		"tags": []string{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	var bm msgRoute
+	if err := json.NewDecoder(res).Decode(&bm); err != nil {
+		t.Fatal(err)
+	}
+	mustBeOk(t, bm.Ok)
+	mustBeNamedRouteOf(t, bm.Route, "xxx", "http://ex.com/a", "")
+
+	if bm.Route.Owner != "kellegous" || bm.Route.Description != "an example" {
+		t.Fatalf("expected owner and description to be kept, got %v", bm.Route)
+	}
+
+	if len(bm.Route.Tags) != 0 {
+		t.Fatalf("expected tags to be cleared, got %v", bm.Route.Tags)
+	}
+
+	if !bm.Route.Created.Equal(am.Route.Created) {
+		t.Fatalf("expected created of %s, got %s", am.Route.Created, bm.Route.Created)
+	}
+
+	if bm.Route.Time.Before(am.Route.Time) {
+		t.Fatalf("expected time after %s, got %s", am.Route.Time, bm.Route.Time)
+	}
+
+	// everything is stored, not just echoed back.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	rt, err := e.backend.Get(ctx, "xxx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.URL != "http://ex.com/a" || rt.Owner != "kellegous" || rt.Description != "an example" ||
+		len(rt.Tags) != 0 || !rt.Created.Equal(am.Route.Created) || !rt.Time.Equal(bm.Route.Time) {
+		t.Fatalf("unexpected stored route: %+v", rt)
+	}
 }
 
 func TestAPIDel(t *testing.T) {
@@ -347,6 +522,85 @@ func TestAPIDel(t *testing.T) {
 	}
 }
 
+func TestAPIHistoryAndRevert(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	for _, u := range []string{"http://a.com/", "http://b.com/"} {
+		res, err := e.post("/api/url/xxx", &urlReq{URL: u})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustHaveStatus(t, res, http.StatusOK)
+	}
+
+	res, err := e.call("DELETE", "/api/url/xxx", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	res, err = e.get("/api/url/xxx/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	var hm msgRevisions
+	if err := json.NewDecoder(res).Decode(&hm); err != nil {
+		t.Fatal(err)
+	}
+	mustBeOk(t, hm.Ok)
+
+	if len(hm.Revisions) != 3 {
+		t.Fatalf("expected 3 revisions, got %d", len(hm.Revisions))
+	}
+
+	if hm.Revisions[1].OldURL != "http://a.com/" || hm.Revisions[1].NewURL != "http://b.com/" {
+		t.Fatalf("unexpected revision: %v", hm.Revisions[1])
+	}
+
+	res, err = e.post("/api/url/xxx/revert", map[string]int64{"revision": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusBadRequest)
+
+	res, err = e.post("/api/url/xxx/revert", map[string]int64{"revision": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusNotFound)
+
+	res, err = e.post("/api/url/xxx/revert", map[string]int64{"revision": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	var rm msgRoute
+	if err := json.NewDecoder(res).Decode(&rm); err != nil {
+		t.Fatal(err)
+	}
+	mustBeOk(t, rm.Ok)
+	mustBeNamedRouteOf(t, rm.Route, "xxx", "http://a.com/", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	rt, err := e.backend.Get(ctx, "xxx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustBeRouteOf(t, rt, "http://a.com/")
+
+	res, err = e.get("/api/url/nothing/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusNotFound)
+}
+
 func TestAPIPutThenGetAuto(t *testing.T) {
 	e := needEnv(t, "")
 	defer e.destroy()
@@ -558,6 +812,31 @@ func TestAPIList(t *testing.T) {
 			}),
 			Pages: [][]*routeWithName{nil},
 		},
+		&listTest{
+			Params: url.Values(map[string][]string{
+				"prefix": {"a"},
+			}),
+			Pages: [][]*routeWithName{
+				[]*routeWithName{rts[4]},
+			},
+		},
+		&listTest{
+			Params: url.Values(map[string][]string{
+				"limit":                   {"1"},
+				"include-generated-names": {"true"},
+				"prefix":                  {":"},
+			}),
+			Pages: [][]*routeWithName{
+				[]*routeWithName{rts[2]},
+				[]*routeWithName{rts[3]},
+			},
+		},
+		&listTest{
+			Params: url.Values(map[string][]string{
+				"prefix": {":"},
+			}),
+			Pages: [][]*routeWithName{nil},
+		},
 	}
 
 	for _, test := range tests {
@@ -610,6 +889,15 @@ func TestBadList(t *testing.T) {
 		url.Values{
 			"include-generated-names": {"butter"},
 		}.Encode(): http.StatusBadRequest,
+
+		url.Values{
+			"sort": {"butter"},
+		}.Encode(): http.StatusBadRequest,
+
+		url.Values{
+			"sort":   {"hits"},
+			"cursor": {base64.URLEncoding.EncodeToString([]byte("-1"))},
+		}.Encode(): http.StatusBadRequest,
 	}
 
 	for params, status := range tests {
@@ -628,3 +916,77 @@ func TestBadList(t *testing.T) {
 		mustBeErr(t, &m)
 	}
 }
+
+// racingBackend changes a route right after it is read, as another request
+// could between a handler's read and its write.
+type racingBackend struct {
+	backend.Backend
+	race func()
+}
+
+func (b *racingBackend) Get(ctx context.Context, name string) (*internal.Route, error) {
+	rt, err := b.Backend.Get(ctx, name)
+	if race := b.race; race != nil {
+		b.race = nil
+		race()
+	}
+	return rt, err
+}
+
+func TestAPIConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+
+	rb := &racingBackend{Backend: memory.New()}
+	defer rb.Close()
+
+	mux := http.NewServeMux()
+	Setup(mux, rb, "")
+	e := &env{mux: mux, backend: rb}
+
+	for _, u := range []string{"http://a.com/", "http://b.com/"} {
+		res, err := e.post("/api/url/xxx", &urlReq{URL: u})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustHaveStatus(t, res, http.StatusOK)
+	}
+
+	tests := map[string]interface{}{
+		"/api/url/xxx":        &urlReq{URL: "http://c.com/"},
+		"/api/url/xxx/owner":  map[string]string{"owner": "alice"},
+		"/api/url/xxx/revert": map[string]int64{"revision": 1},
+	}
+
+	for path, body := range tests {
+		rb.race = func() {
+			if err := rb.Backend.Put(ctx, "xxx", &internal.Route{URL: "http://d.com/", Time: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		res, err := e.post(path, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustHaveStatus(t, res, http.StatusConflict)
+
+		rt, err := rb.Backend.Get(ctx, "xxx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustBeRouteOf(t, rt, "http://d.com/")
+	}
+
+	// a route created while another request is creating it isn't replaced.
+	rb.race = func() {
+		if err := rb.Backend.Put(ctx, "yyy", &internal.Route{URL: "http://d.com/", Time: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := e.post("/api/url/yyy", &urlReq{URL: "http://c.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusConflict)
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/auth"
+	"github.com/kellegous/go/internal/backend"
+)
+
+const (
+	defaultAuditLimit = 1000
+	maxAuditLimit     = 10000
+)
+
+// auditLog records who changed what in the backend's audit log and,
+// optionally, as JSON lines written to a file.
+type auditLog struct {
+	backend backend.Backend
+	lck     sync.Mutex
+	w       io.Writer
+}
+
+func newAuditLog(backend backend.Backend, w io.Writer) *auditLog {
+	return &auditLog{
+		backend: backend,
+		w:       w,
+	}
+}
+
+// Open the file to which audit events are appended as JSON lines.
+func openAuditFile(filename string) (*os.File, error) {
+	return os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+}
+
+// Record that the caller took the action on the named route or token. The
+// change has already been made, so failures are logged rather than returned.
+func (a *auditLog) record(ctx context.Context, action, name, detail string) {
+	e := &internal.AuditEvent{
+		Time:   time.Now(),
+		Actor:  internal.ActorFrom(ctx),
+		Action: action,
+		Name:   name,
+		Detail: detail,
+	}
+
+	if err := a.backend.AppendAudit(ctx, e); err != nil {
+		log.Printf("[error] audit %s %s: %s", action, name, err)
+	}
+
+	if a.w == nil {
+		return
+	}
+
+	a.lck.Lock()
+	defer a.lck.Unlock()
+	if err := json.NewEncoder(a.w).Encode(e); err != nil {
+		log.Printf("[error] audit %s %s: %s", action, name, err)
+	}
+}
+
+func apiAuditGet(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
+	if id := auth.FromContext(r.Context()); id != nil && !isAdmin(id) {
+		writeJSONError(w, "only admins can read the audit log", http.StatusForbidden)
+		return
+	}
+
+	export := r.FormValue("format") == "jsonl"
+	if f := r.FormValue("format"); f != "" && f != "json" && !export {
+		writeJSONError(w, "invalid format value", http.StatusBadRequest)
+		return
+	}
+
+	since, err := parseTime(r.FormValue("since"), time.Time{})
+	if err != nil {
+		writeJSONError(w, "invalid since value", http.StatusBadRequest)
+		return
+	}
+
+	filter := internal.AuditFilter{
+		Actor: r.FormValue("actor"),
+		Name:  r.FormValue("name"),
+		Since: since,
+	}
+
+	// exports include everything unless asked otherwise.
+	def := defaultAuditLimit
+	if export {
+		def = 0
+	}
+
+	lim, err := parseInt(r.FormValue("limit"), def)
+	if err != nil || lim < 0 || (!export && (lim == 0 || lim > maxAuditLimit)) {
+		writeJSONError(w, "invalid limit value", http.StatusBadRequest)
+		return
+	}
+	filter.Limit = lim
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	events, err := backend.ListAudit(ctx, &filter)
+	if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	if export {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		w.WriteHeader(http.StatusOK)
+
+		enc := json.NewEncoder(w)
+		for _, e := range events {
+			if err := enc.Encode(e); err != nil {
+				log.Printf("[error] exporting audit log: %s", err)
+				return
+			}
+		}
+		return
+	}
+
+	if events == nil {
+		events = []*internal.AuditEvent{}
+	}
+
+	writeJSON(w, &msgAudit{
+		Ok:     true,
+		Events: events,
+	}, http.StatusOK)
+}
+
+func apiAudit(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		apiAuditGet(backend, w, r)
+	default:
+		writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
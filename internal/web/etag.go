@@ -0,0 +1,186 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+)
+
+const (
+	errMsgRevisionMismatch = "the link has changed since it was read"
+	errMsgRouteExists      = "a link with that name already exists"
+)
+
+// anyRevision is the expected revision of If-Match: *, which is satisfied by
+// any existing route.
+const anyRevision int64 = -1
+
+// precondition is a request's expectation of the revision of a route, given
+// either with an If-Match header or as the revision in the body, or its
+// expectation that there is no route at all.
+type precondition struct {
+	rev int64
+
+	// Whether the route must not exist, i.e. the request only creates.
+	absent bool
+
+	// The status of the response when the precondition fails.
+	status int
+}
+
+// The entity tag of a route is its quoted revision.
+func routeETag(rt *internal.Route) string {
+	return strconv.Quote(strconv.FormatInt(rt.Revision, 10))
+}
+
+// Parse the value of an If-Match header, which must be * or a single strong
+// entity tag of a route. The returned precondition is nil if the header is
+// empty.
+func parseIfMatch(v string) (*precondition, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+
+	if v == "*" {
+		return &precondition{
+			rev:    anyRevision,
+			status: http.StatusPreconditionFailed,
+		}, nil
+	}
+
+	s, err := strconv.Unquote(v)
+	if err != nil || !strings.HasPrefix(v, `"`) {
+		return nil, errors.New("invalid If-Match header")
+	}
+
+	rev, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || rev < 0 {
+		return nil, errors.New("invalid If-Match header")
+	}
+
+	return &precondition{
+		rev:    rev,
+		status: http.StatusPreconditionFailed,
+	}, nil
+}
+
+// Parse the value of an If-None-Match header. Only * is supported, which
+// makes the request create-only. The returned precondition is nil if the
+// header is empty.
+func parseIfNoneMatch(v string) (*precondition, error) {
+	switch strings.TrimSpace(v) {
+	case "":
+		return nil, nil
+	case "*":
+		return &precondition{
+			absent: true,
+			status: http.StatusPreconditionFailed,
+		}, nil
+	}
+	return nil, errors.New("invalid If-None-Match header")
+}
+
+// Find the precondition of a request to change a route, given the revision
+// in the body of the request, if any. A route can be expected to be at a
+// revision with If-Match or the revision in the body, or to not exist with
+// If-None-Match: * or create=true. At most one of these can be given.
+func parsePrecondition(r *http.Request, rev *int64) (*precondition, error) {
+	var conds []*precondition
+
+	c, err := parseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		return nil, err
+	} else if c != nil {
+		conds = append(conds, c)
+	}
+
+	c, err = parseIfNoneMatch(r.Header.Get("If-None-Match"))
+	if err != nil {
+		return nil, err
+	} else if c != nil {
+		conds = append(conds, c)
+	}
+
+	if rev != nil {
+		conds = append(conds, &precondition{
+			rev:    *rev,
+			status: http.StatusConflict,
+		})
+	}
+
+	create, err := parseBool(r.URL.Query().Get("create"), false)
+	if err != nil {
+		return nil, errors.New("invalid create value")
+	} else if create {
+		conds = append(conds, &precondition{
+			absent: true,
+			status: http.StatusConflict,
+		})
+	}
+
+	switch len(conds) {
+	case 0:
+		return nil, nil
+	case 1:
+		return conds[0], nil
+	}
+
+	return nil, errors.New("only one of If-Match, If-None-Match, revision and create can be given")
+}
+
+// The message of the error returned when the precondition fails.
+func (c *precondition) failure() string {
+	if c.absent {
+		return errMsgRouteExists
+	}
+	return errMsgRevisionMismatch
+}
+
+// Indicates whether the precondition is satisfied by the current route,
+// which is nil if there is none. A nil precondition is always satisfied.
+func (c *precondition) matches(rt *internal.Route) bool {
+	if c == nil {
+		return true
+	}
+
+	if c.absent {
+		return rt == nil
+	}
+
+	if rt == nil {
+		return false
+	}
+
+	return c.rev == anyRevision || c.rev == rt.Revision
+}
+
+// Store rt as the named route, but only if the route is still cur, which is
+// nil if there was none when it was read. Changes that are checked against
+// the current route must be stored this way so that they can't overwrite a
+// change made after the check.
+func putUnchanged(
+	ctx context.Context,
+	backend backend.Backend,
+	name string,
+	cur *internal.Route,
+	rt *internal.Route,
+) error {
+	if cur == nil {
+		return backend.Create(ctx, name, rt)
+	}
+	return backend.PutIf(ctx, name, rt, cur.Revision)
+}
+
+// Indicates whether err means that a route changed while it was being
+// changed by a conditional write.
+func isChanged(err error) bool {
+	return errors.Is(err, internal.ErrRevisionMismatch) ||
+		errors.Is(err, internal.ErrRouteNotFound) ||
+		errors.Is(err, internal.ErrRouteExists)
+}
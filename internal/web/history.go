@@ -0,0 +1,170 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/auth"
+	"github.com/kellegous/go/internal/backend"
+)
+
+func apiURLHistoryGet(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
+	p := parseName("/api/url/", r.URL.Path)
+
+	if p == "" {
+		writeJSONError(w, "name required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	revs, err := backend.History(ctx, p)
+	if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	if len(revs) == 0 {
+		writeJSONError(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, &msgRevisions{
+		Ok:        true,
+		Revisions: revs,
+	}, http.StatusOK)
+}
+
+// Restore a route to the way it was after the given revision.
+func apiURLRevertPost(backend backend.Backend, audit *auditLog, host string, w http.ResponseWriter, r *http.Request) {
+	p := parseName("/api/url/", r.URL.Path)
+
+	if p == "" {
+		writeJSONError(w, "name required", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Revision int64 `json:"revision"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	if req.Revision <= 0 {
+		writeJSONError(w, "revision required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	revs, err := backend.History(ctx, p)
+	if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	var rev *internal.Revision
+	for _, r := range revs {
+		if r.ID == req.Revision {
+			rev = r
+			break
+		}
+	}
+
+	if rev == nil {
+		writeJSONError(w, "revision not found", http.StatusNotFound)
+		return
+	}
+
+	if rev.Route == nil {
+		writeJSONError(w, "cannot revert to a deletion", http.StatusBadRequest)
+		return
+	}
+
+	rt := *rev.Route
+	rt.Time = time.Now()
+	rt.ModifiedBy = internal.ActorFrom(ctx)
+
+	// the route may have been deleted and recreated since the revision. Either
+	// way, reverting doesn't change who owns it.
+	var trashed bool
+	cur, err := backend.Get(ctx, p)
+	owned := cur
+	if errors.Is(err, internal.ErrRouteNotFound) {
+		cur, owned = nil, rev.Route
+		if t, err := backend.GetTrash(ctx, p); err == nil {
+			owned, trashed = t.Route, true
+		} else if !errors.Is(err, internal.ErrRouteNotFound) {
+			writeJSONBackendError(w, err)
+			return
+		}
+	} else if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	} else {
+		rt.Created = cur.Created
+	}
+
+	if !canEdit(auth.FromContext(ctx), owned) {
+		writeJSONError(w, errMsgNotEditor, http.StatusForbidden)
+		return
+	}
+
+	// a deleted route that is still in the trash is restored and then
+	// reverted, since recreating it would leave it in the trash where it could
+	// no longer be restored.
+	if trashed {
+		cur, err = backend.Restore(ctx, p)
+		if isChanged(err) {
+			writeJSONError(w, errMsgRevisionMismatch, http.StatusConflict)
+			return
+		} else if err != nil {
+			writeJSONBackendError(w, err)
+			return
+		}
+		rt.Created = cur.Created
+	}
+
+	rt.Owner = owned.Owner
+	rt.CoOwners = owned.CoOwners
+
+	if err := putUnchanged(ctx, backend, p, cur, &rt); isChanged(err) {
+		writeJSONError(w, errMsgRevisionMismatch, http.StatusConflict)
+		return
+	} else if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	audit.record(ctx, internal.AuditRevert, p, fmt.Sprintf("revision %d: %s", rev.ID, rt.URL))
+
+	writeJSONRoute(w, p, &rt, host, nil)
+}
+
+func apiURLHistory(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		apiURLHistoryGet(backend, w, r)
+	default:
+		writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func apiURLRevert(backend backend.Backend, audit *auditLog, host string, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		apiURLRevertPost(backend, audit, host, w, r)
+	default:
+		writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
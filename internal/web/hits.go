@@ -0,0 +1,195 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+)
+
+// The referrer recorded for visits that have no Referer header.
+const directReferrer = "direct"
+
+// hitCounter aggregates visits to routes in memory and periodically flushes
+// them to the backend so that redirects never wait on a write.
+type hitCounter struct {
+	backend backend.Backend
+	lck     sync.Mutex
+	hits    map[string]*internal.Hits
+	clicks  map[string][]*internal.Clicks
+}
+
+func newHitCounter(backend backend.Backend) *hitCounter {
+	return &hitCounter{
+		backend: backend,
+		hits:    map[string]*internal.Hits{},
+		clicks:  map[string][]*internal.Clicks{},
+	}
+}
+
+// Determine the host of the page that referred the request.
+func referrerOf(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil || u.Hostname() == "" {
+		return directReferrer
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// Substrings of the User-Agent header that identify scripts and command line
+// tools.
+var scriptAgents = []string{
+	"curl/",
+	"wget/",
+	"go-http-client/",
+	"python-",
+	"python/",
+	"okhttp/",
+	"libwww-perl/",
+	"httpie/",
+	"axios/",
+	"node-fetch/",
+	"java/",
+}
+
+// Classify the user agent of the request as one of slack, script, bot,
+// browser or other.
+func agentOf(r *http.Request) string {
+	ua := strings.ToLower(r.UserAgent())
+
+	if strings.Contains(ua, "slack") {
+		return "slack"
+	}
+
+	for _, s := range scriptAgents {
+		if strings.Contains(ua, s) {
+			return "script"
+		}
+	}
+
+	if strings.Contains(ua, "bot") ||
+		strings.Contains(ua, "crawler") ||
+		strings.Contains(ua, "spider") {
+		return "bot"
+	}
+
+	if strings.HasPrefix(ua, "mozilla/") {
+		return "browser"
+	}
+
+	return "other"
+}
+
+// Merge the clicks in c into the buckets of cs, returning the new buckets.
+func addClicks(cs []*internal.Clicks, c *internal.Clicks) []*internal.Clicks {
+	for _, b := range cs {
+		if b.Start.Equal(c.Start) {
+			b.Add(c)
+			return cs
+		}
+	}
+	return append(cs, c)
+}
+
+// Record a visit to the named route made by the request r at time t.
+func (c *hitCounter) add(name string, r *http.Request, t time.Time) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	h := c.hits[name]
+	if h == nil {
+		h = &internal.Hits{}
+		c.hits[name] = h
+	}
+
+	h.Add(&internal.Hits{Count: 1, Last: t})
+
+	c.clicks[name] = addClicks(c.clicks[name], &internal.Clicks{
+		Start:     t.UTC().Truncate(internal.ClickBucketSize),
+		Count:     1,
+		Referrers: map[string]int64{referrerOf(r): 1},
+		Agents:    map[string]int64{agentOf(r): 1},
+	})
+}
+
+// Write all buffered visits to the backend. If a write fails, the visits are
+// kept so that they can be retried on the next flush.
+func (c *hitCounter) flush(ctx context.Context) error {
+	c.lck.Lock()
+	hits, clicks := c.hits, c.clicks
+	c.hits = map[string]*internal.Hits{}
+	c.clicks = map[string][]*internal.Clicks{}
+	c.lck.Unlock()
+
+	var errs []error
+
+	if len(hits) > 0 {
+		if err := c.backend.AddHits(ctx, hits); err != nil {
+			c.restoreHits(hits)
+			errs = append(errs, err)
+		}
+	}
+
+	if len(clicks) > 0 {
+		if err := c.backend.AddClicks(ctx, clicks); err != nil {
+			c.restoreClicks(clicks)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Put hits that could not be written back into the buffer.
+func (c *hitCounter) restoreHits(hits map[string]*internal.Hits) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	for name, h := range c.hits {
+		if p := hits[name]; p != nil {
+			p.Add(h)
+		} else {
+			hits[name] = h
+		}
+	}
+	c.hits = hits
+}
+
+// Put clicks that could not be written back into the buffer.
+func (c *hitCounter) restoreClicks(clicks map[string][]*internal.Clicks) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	for name, cs := range c.clicks {
+		for _, b := range cs {
+			clicks[name] = addClicks(clicks[name], b)
+		}
+	}
+	c.clicks = clicks
+}
+
+// Flush the buffered visits every interval until the context is done.
+func (c *hitCounter) run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			fctx, cancel := context.WithTimeout(ctx, time.Minute)
+			if err := c.flush(fctx); err != nil {
+				log.Printf("[error] flushing hits: %s", err)
+			}
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/auth"
+	"github.com/kellegous/go/internal/backend"
+	"github.com/kellegous/go/internal/dump"
+)
+
+const (
+	// The most routes that can be imported in a single request.
+	maxImportRoutes = 10000
+
+	// The largest body an import request may have.
+	maxImportBytes = 64 << 20
+)
+
+// Decode the numeric ID from a generated name, the inverse of encodeID.
+func decodeID(name string) (uint64, bool) {
+	if len(name) < 2 || name[0] != genURLPrefix {
+		return 0, false
+	}
+
+	var id uint64
+	for i := len(name) - 1; i > 0; i-- {
+		d := strings.IndexByte(alpha, name[i])
+		if d < 0 {
+			return 0, false
+		}
+		id = id*uint64(len(alpha)) + uint64(d)
+	}
+
+	return id, true
+}
+
+// Find the format of an import from the format parameter or, failing that,
+// the Content-Type of the request. JSON lines is the default.
+func importFormat(r *http.Request) (dump.Format, error) {
+	if v := r.URL.Query().Get("format"); v != "" {
+		return dump.ParseFormat(v)
+	}
+
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch ct {
+	case "text/csv":
+		return dump.CSV, nil
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		return dump.YAML, nil
+	}
+	return dump.JSONL, nil
+}
+
+// Read the routes to import from the body of the request, checking each one
+// and filling in what it leaves out as if it had been created by the caller
+// just now.
+func readImport(ctx context.Context, r *http.Request, dr dump.Reader) ([]*internal.NamedRoute, error) {
+	now := time.Now()
+
+	var routes []*internal.NamedRoute
+	for {
+		e, err := dr.Read()
+		if errors.Is(err, io.EOF) {
+			return routes, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if len(routes) == maxImportRoutes {
+			return nil, fmt.Errorf("too many routes; at most %d can be imported at once", maxImportRoutes)
+		}
+
+		if strings.Contains(e.Name, "/") || isBannedName(e.Name) {
+			return nil, fmt.Errorf("%s: name cannot be used", e.Name)
+		}
+
+		if err := validateTemplate(r, e.URL); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name, err)
+		}
+
+		if !e.Passthrough.Valid() {
+			return nil, fmt.Errorf("%s: invalid passthrough", e.Name)
+		}
+
+		rt := e.Route
+		if rt.Time.IsZero() {
+			rt.Time = now
+		}
+
+		if rt.Created.IsZero() {
+			rt.Created = rt.Time
+		}
+
+		if rt.ModifiedBy == "" {
+			rt.ModifiedBy = internal.ActorFrom(ctx)
+		}
+
+		routes = append(routes, &internal.NamedRoute{
+			Name:  e.Name,
+			Route: rt,
+		})
+	}
+}
+
+// Describe the result of an import, which failed if there is an error.
+func newMsgImport(res *internal.BatchResult, err string) *msgImport {
+	m := &msgImport{
+		Ok:      err == "",
+		Error:   err,
+		Created: res.Created,
+		Updated: res.Updated,
+		Skipped: res.Skipped,
+	}
+
+	for _, l := range []*[]string{&m.Created, &m.Updated, &m.Skipped} {
+		if *l == nil {
+			*l = []string{}
+		}
+	}
+
+	return m
+}
+
+// Import a batch of routes, which keep the owners and timestamps they are
+// given. Only admins may import once authentication is enabled.
+func apiImportPost(backend backend.Backend, audit *auditLog, w http.ResponseWriter, r *http.Request) {
+	if id := auth.FromContext(r.Context()); id != nil && !canEditAll(id) {
+		writeJSONError(w, "only admins can import routes", http.StatusForbidden)
+		return
+	}
+
+	policy := internal.ConflictFail
+	if v := r.URL.Query().Get("conflict"); v != "" {
+		policy = internal.ConflictPolicy(v)
+	}
+
+	if !policy.Valid() {
+		writeJSONError(w, "invalid conflict value", http.StatusBadRequest)
+		return
+	}
+
+	f, err := importFormat(r)
+	if err != nil {
+		writeJSONError(w, "invalid format value", http.StatusBadRequest)
+		return
+	}
+
+	dr, err := dump.NewReader(http.MaxBytesReader(w, r.Body, maxImportBytes), f)
+	if err != nil {
+		writeJSONError(w, "invalid format value", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), dumpTimeout)
+	defer cancel()
+
+	routes, err := readImport(ctx, r, dr)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if len(routes) == 0 {
+		writeJSONError(w, "no routes to import", http.StatusBadRequest)
+		return
+	}
+
+	byName := map[string]*internal.Route{}
+	for _, nr := range routes {
+		if _, ok := byName[nr.Name]; ok {
+			writeJSONError(w, fmt.Sprintf("%s: appears more than once", nr.Name), http.StatusBadRequest)
+			return
+		}
+		byName[nr.Name] = nr.Route
+	}
+
+	res, err := backend.PutBatch(ctx, routes, policy)
+	if errors.Is(err, internal.ErrRouteExists) {
+		writeJSON(w, newMsgImport(res, "routes already exist"), http.StatusConflict)
+		return
+	} else if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	// generated names that were imported must never be generated again.
+	var last uint64
+	for _, name := range append(res.Created, res.Updated...) {
+		if id, ok := decodeID(name); ok && id > last {
+			last = id
+		}
+	}
+
+	if last > 0 {
+		if err := backend.AdvanceID(ctx, last); err != nil {
+			writeJSONBackendError(w, err)
+			return
+		}
+	}
+
+	// each route that was written is audited as if it had been posted, along
+	// with a summary of the whole import.
+	for _, name := range res.Created {
+		audit.record(ctx, internal.AuditCreate, name, byName[name].URL)
+	}
+
+	for _, name := range res.Updated {
+		audit.record(ctx, internal.AuditUpdate, name, byName[name].URL)
+	}
+
+	audit.record(ctx, internal.AuditImport, "", fmt.Sprintf(
+		"created %d, updated %d, skipped %d",
+		len(res.Created),
+		len(res.Updated),
+		len(res.Skipped)))
+
+	writeJSON(w, newMsgImport(res, ""), http.StatusOK)
+}
+
+func apiImport(backend backend.Backend, audit *auditLog, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		apiImportPost(backend, audit, w, r)
+	default:
+		writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
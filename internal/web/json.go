@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/auth"
 )
 
 // Used as an API response, this is a route with its associated shortcut name.
@@ -13,6 +15,7 @@ type routeWithName struct {
 	Name       string `json:"name"`
 	SourceHost string `json:"source_host"`
 	*internal.Route
+	Hits *internal.Hits `json:"hits,omitempty"`
 }
 
 // The response type for all API responses.
@@ -36,6 +39,75 @@ type msgRoutes struct {
 	Next   string           `json:"next"`
 }
 
+type msgRevisions struct {
+	Ok        bool                 `json:"ok"`
+	Revisions []*internal.Revision `json:"revisions"`
+}
+
+type msgClicks struct {
+	Ok      bool               `json:"ok"`
+	Bucket  string             `json:"bucket"`
+	Buckets []*internal.Clicks `json:"buckets"`
+}
+
+// Used as an API response, this describes who owns a route.
+type msgOwners struct {
+	Ok       bool     `json:"ok"`
+	Owner    string   `json:"owner"`
+	CoOwners []string `json:"co_owners,omitempty"`
+}
+
+// Used as an API response, this describes an API token without its secret.
+type tokenInfo struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Owner   string    `json:"owner"`
+	Scopes  []string  `json:"scopes"`
+	Created time.Time `json:"created"`
+}
+
+type msgToken struct {
+	Ok     bool       `json:"ok"`
+	Token  *tokenInfo `json:"token"`
+	Secret string     `json:"secret,omitempty"`
+}
+
+type msgTokens struct {
+	Ok     bool         `json:"ok"`
+	Tokens []*tokenInfo `json:"tokens"`
+}
+
+type msgImport struct {
+	Ok      bool     `json:"ok"`
+	Error   string   `json:"error,omitempty"`
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Skipped []string `json:"skipped"`
+}
+
+type msgAudit struct {
+	Ok     bool                   `json:"ok"`
+	Events []*internal.AuditEvent `json:"events"`
+}
+
+// Used as an API response, this is a deleted route with when it will be
+// purged.
+type trashedRoute struct {
+	*internal.TrashedRoute
+	Expires time.Time `json:"expires"`
+}
+
+type msgTrash struct {
+	Ok     bool            `json:"ok"`
+	Routes []*trashedRoute `json:"routes"`
+}
+
+type msgMe struct {
+	Ok          bool           `json:"ok"`
+	AuthEnabled bool           `json:"auth_enabled"`
+	Identity    *auth.Identity `json:"identity,omitempty"`
+}
+
 // Encode the given data to JSON and send it to the client.
 func writeJSON(w http.ResponseWriter, data interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json;charset=utf-8")
@@ -66,17 +138,21 @@ func writeJSONBackendError(w http.ResponseWriter, err error) {
 	writeJSONError(w, "backend error", http.StatusInternalServerError)
 }
 
-// Encode the given named route as a msg and send it to the client.
-func writeJSONRoute(w http.ResponseWriter, name string, rt *internal.Route, host string) {
+// Encode the given named route as a msg and send it to the client. The visits
+// to the route are only included if hits is non-nil.
+func writeJSONRoute(w http.ResponseWriter, name string, rt *internal.Route, host string, hits *internal.Hits) {
 	r := routeWithName{
 		Name:  name,
 		Route: rt,
+		Hits:  hits,
 	}
 
 	if host != "" {
 		r.SourceHost = host
 	}
 
+	w.Header().Set("ETag", routeETag(rt))
+
 	writeJSON(w, &msgRoute{
 		Ok:    true,
 		Route: &r,
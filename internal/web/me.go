@@ -0,0 +1,22 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/kellegous/go/internal/auth"
+)
+
+// Describes the caller of the request, which lets the UI know who is signed
+// in.
+func apiMe(authn *auth.Authenticator, w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, &msgMe{
+		Ok:          true,
+		AuthEnabled: authn.Enabled(),
+		Identity:    auth.FromContext(r.Context()),
+	}, http.StatusOK)
+}
@@ -8,6 +8,7 @@ const encodedIDPrefix = ":"
 
 var bannedNames = map[string]bool{
 	"api":     true,
+	"auth":    true,
 	"edit":    true,
 	"healthz": true,
 	"links":   true,
@@ -26,6 +27,18 @@ func parseName(base, path string) string {
 	return t[:ix]
 }
 
+// Parse the sub-resource that follows the shortcut name in the given URL path,
+// given the base URL that is handling the request. For instance, the
+// sub-resource of /api/url/foo/history is "history".
+func parseSubresource(base, path string) string {
+	t := path[len(base):]
+	ix := strings.Index(t, "/")
+	if ix == -1 {
+		return ""
+	}
+	return strings.Trim(t[ix+1:], "/")
+}
+
 // Clean a shortcut name. Currently this just means stripping any leading
 // ":" to avoid collisions with auto generated names.
 func cleanName(name string) string {
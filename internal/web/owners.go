@@ -0,0 +1,182 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/auth"
+	"github.com/kellegous/go/internal/backend"
+)
+
+const (
+	errMsgNotEditor = "you do not have permission to change this link"
+	errMsgNotOwner  = "only the owner can change who owns this link"
+)
+
+// Indicates whether the route has no owners, which is the case for routes
+// created before ownership was enforced. Anyone may change those.
+func isUnowned(rt *internal.Route) bool {
+	return rt.Owner == "" && len(rt.CoOwners) == 0
+}
+
+// Indicates whether the caller may change every route.
+func canEditAll(id *auth.Identity) bool {
+	return id.Admin && id.Allows(auth.ScopeWriteAll)
+}
+
+// Indicates whether the caller may change the route. Without authentication
+// there is no caller and everyone may change everything.
+func canEdit(id *auth.Identity, rt *internal.Route) bool {
+	if id == nil {
+		return true
+	}
+
+	if !id.Allows(auth.ScopeWriteOwn) {
+		return false
+	}
+
+	if canEditAll(id) || isUnowned(rt) || id.Is(rt.Owner) {
+		return true
+	}
+
+	for _, p := range rt.CoOwners {
+		if id.Is(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Indicates whether the caller may change who owns the route.
+func canTransfer(id *auth.Identity, rt *internal.Route) bool {
+	if id == nil {
+		return true
+	}
+
+	if !id.Allows(auth.ScopeWriteOwn) {
+		return false
+	}
+
+	return canEditAll(id) || isUnowned(rt) || id.Is(rt.Owner)
+}
+
+// Indicates whether the two lists of principals are the same.
+func sameOwners(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func apiURLOwnerGet(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
+	p := parseName("/api/url/", r.URL.Path)
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	rt, err := backend.Get(ctx, p)
+	if errors.Is(err, internal.ErrRouteNotFound) {
+		writeJSONError(w, "Not Found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	writeJSON(w, &msgOwners{
+		Ok:       true,
+		Owner:    rt.Owner,
+		CoOwners: rt.CoOwners,
+	}, http.StatusOK)
+}
+
+// Transfer the ownership of a route, replacing its owner, co-owners or both.
+func apiURLOwnerPost(backend backend.Backend, audit *auditLog, w http.ResponseWriter, r *http.Request) {
+	p := parseName("/api/url/", r.URL.Path)
+
+	var req struct {
+		Owner    *string  `json:"owner"`
+		CoOwners []string `json:"co_owners"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	if req.Owner != nil && strings.TrimSpace(*req.Owner) == "" {
+		writeJSONError(w, "owner cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	cur, err := backend.Get(ctx, p)
+	if errors.Is(err, internal.ErrRouteNotFound) {
+		writeJSONError(w, "Not Found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	if !canTransfer(auth.FromContext(ctx), cur) {
+		writeJSONError(w, errMsgNotOwner, http.StatusForbidden)
+		return
+	}
+
+	rt := *cur
+	if req.Owner != nil {
+		rt.Owner = strings.TrimSpace(*req.Owner)
+	}
+
+	if req.CoOwners != nil {
+		rt.CoOwners = normalizeList(req.CoOwners)
+	}
+
+	rt.Time = time.Now()
+	rt.ModifiedBy = internal.ActorFrom(ctx)
+
+	if err := putUnchanged(ctx, backend, p, cur, &rt); isChanged(err) {
+		writeJSONError(w, errMsgRevisionMismatch, http.StatusConflict)
+		return
+	} else if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	audit.record(ctx, internal.AuditTransfer, p, fmt.Sprintf("owner: %s, co-owners: %s",
+		rt.Owner, strings.Join(rt.CoOwners, ", ")))
+
+	writeJSON(w, &msgOwners{
+		Ok:       true,
+		Owner:    rt.Owner,
+		CoOwners: rt.CoOwners,
+	}, http.StatusOK)
+}
+
+func apiURLOwner(backend backend.Backend, audit *auditLog, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		apiURLOwnerGet(backend, w, r)
+	case "POST":
+		apiURLOwnerPost(backend, audit, w, r)
+	default:
+		writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
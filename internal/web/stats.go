@@ -0,0 +1,124 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/backend"
+)
+
+// The largest number of buckets that will be returned in one response.
+const maxStatsBuckets = 10000
+
+// The sizes of the buckets that stats can be aggregated into.
+var bucketSizes = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+}
+
+// Parse a time given either as RFC 3339 or as a date.
+func parseTime(v string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, v)
+}
+
+// Round t up to a multiple of d.
+func roundUp(t time.Time, d time.Duration) time.Time {
+	if r := t.Truncate(d); !r.Equal(t) {
+		return r.Add(d)
+	}
+	return t
+}
+
+func apiURLStatsGet(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
+	p := parseName("/api/url/", r.URL.Path)
+
+	if p == "" {
+		writeJSONError(w, "name required", http.StatusBadRequest)
+		return
+	}
+
+	bucket := r.FormValue("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+
+	size, ok := bucketSizes[bucket]
+	if !ok {
+		writeJSONError(w, "invalid bucket value", http.StatusBadRequest)
+		return
+	}
+
+	to, err := parseTime(r.FormValue("to"), time.Now())
+	if err != nil {
+		writeJSONError(w, "invalid to value", http.StatusBadRequest)
+		return
+	}
+
+	from, err := parseTime(r.FormValue("from"), to.Add(-7*24*time.Hour))
+	if err != nil {
+		writeJSONError(w, "invalid from value", http.StatusBadRequest)
+		return
+	}
+
+	// widen the range so that it covers whole buckets.
+	from = from.UTC().Truncate(size)
+	to = roundUp(to.UTC(), size)
+
+	if !from.Before(to) {
+		writeJSONError(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	n := int(to.Sub(from) / size)
+	if n > maxStatsBuckets {
+		writeJSONError(w, "range is too large", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	cs, err := backend.GetClicks(ctx, p, from, to)
+	if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	buckets := make([]*internal.Clicks, n)
+	for i := range buckets {
+		buckets[i] = &internal.Clicks{
+			Start: from.Add(time.Duration(i) * size),
+		}
+	}
+
+	for _, c := range cs {
+		if i := int(c.Start.Sub(from) / size); i >= 0 && i < n {
+			buckets[i].Add(c)
+		}
+	}
+
+	writeJSON(w, &msgClicks{
+		Ok:      true,
+		Bucket:  bucket,
+		Buckets: buckets,
+	}, http.StatusOK)
+}
+
+func apiURLStats(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		apiURLStatsGet(backend, w, r)
+	default:
+		writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
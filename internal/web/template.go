@@ -0,0 +1,188 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The placeholder that is replaced by every path segment following the
+// shortcut name.
+const allArgsPlaceholder = "*"
+
+// Returned for templates with placeholders in the scheme or host, which would
+// let whoever visits the shortcut choose where it goes.
+var errPlaceholderInHost = errors.New("placeholders can only be used in the path, query or fragment")
+
+// Indicates whether key, the text between a pair of braces, names a
+// placeholder.
+func isPlaceholderKey(key string) bool {
+	if key == allArgsPlaceholder {
+		return true
+	}
+
+	if key == "" || strings.Trim(key, "0123456789") != "" {
+		return false
+	}
+
+	n, err := strconv.Atoi(key)
+	return err == nil && n >= 1
+}
+
+// Find the next placeholder in s, returning the offsets of its opening and
+// closing braces, or -1 for both if there is none. Braces that don't enclose a
+// placeholder are simply part of the URL.
+func nextPlaceholder(s string) (int, int) {
+	for off := 0; ; {
+		i := strings.IndexByte(s[off:], '{')
+		if i == -1 {
+			return -1, -1
+		}
+		i += off
+
+		j := strings.IndexByte(s[i+1:], '}')
+		if j == -1 {
+			return -1, -1
+		}
+		j += i + 1
+
+		if isPlaceholderKey(s[i+1 : j]) {
+			return i, j
+		}
+		off = i + 1
+	}
+}
+
+// Indicates whether the given route URL contains placeholders that must be
+// expanded at redirect time.
+func hasPlaceholders(s string) bool {
+	i, _ := nextPlaceholder(s)
+	return i != -1
+}
+
+// Replace each placeholder in the template s with the value returned by fn.
+// A placeholder is either a 1-based index into the path segments that follow
+// the shortcut name, e.g. {1}, or {*}, which stands for all of the segments.
+// fn is also told whether the placeholder follows the start of the query, so
+// that it can escape the value accordingly.
+func expandTemplate(s string, fn func(key string, query bool) string) string {
+	var b strings.Builder
+	var query bool
+	for {
+		i, j := nextPlaceholder(s)
+		if i == -1 {
+			b.WriteString(s)
+			return b.String()
+		}
+
+		b.WriteString(s[:i])
+		query = query || strings.ContainsAny(s[:i], "?#")
+		b.WriteString(fn(s[i+1:j], query))
+		s = s[j+1:]
+	}
+}
+
+// Check that the given URL, which may be a template, is suitable as a
+// shortcut link.
+func validateTemplate(r *http.Request, s string) error {
+	if !hasPlaceholders(s) {
+		return validateURL(r, s)
+	}
+
+	expand := func(v string) (*url.URL, error) {
+		return url.Parse(expandTemplate(s, func(string, bool) string {
+			return v
+		}))
+	}
+
+	a, err := expand("a")
+	if err != nil {
+		return errInvalidURL
+	}
+
+	b, err := expand("b")
+	if err != nil {
+		return errInvalidURL
+	}
+
+	// whatever the placeholders expand to, the URL must go to the same place.
+	if a.Scheme != b.Scheme || a.User.String() != b.User.String() || a.Host != b.Host || a.Opaque != b.Opaque {
+		return errPlaceholderInHost
+	}
+
+	return validateURL(r, a.String())
+}
+
+// Split the portion of the request path that follows the shortcut name into
+// its segments. The segments retain their original escaping.
+func pathArgs(r *http.Request) []string {
+	segs := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+
+	var args []string
+	for _, seg := range segs[1:] {
+		if seg != "" {
+			args = append(args, seg)
+		}
+	}
+	return args
+}
+
+// Append the given raw query to the query of the URL u.
+func appendQuery(u, query string) (string, error) {
+	if query == "" {
+		return u, nil
+	}
+
+	p, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+
+	if p.RawQuery == "" {
+		p.RawQuery = query
+	} else {
+		p.RawQuery += "&" + query
+	}
+
+	return p.String(), nil
+}
+
+// Escape a path segment, which is already escaped for use in a path, for use
+// in a query instead.
+func queryEscapeSegment(seg string) string {
+	if v, err := url.PathUnescape(seg); err == nil {
+		seg = v
+	}
+	return url.QueryEscape(seg)
+}
+
+// Expand the route URL template s using the path segments and query of the
+// request r. Placeholders that refer to missing segments expand to an empty
+// string. Segments keep their escaping in the path, and are escaped as query
+// values after the ?, so that they can't add parameters of their own.
+func expandURL(s string, r *http.Request) (string, error) {
+	args := pathArgs(r)
+
+	u := expandTemplate(s, func(key string, query bool) string {
+		var segs []string
+		if key == allArgsPlaceholder {
+			segs = args
+		} else if n, _ := strconv.Atoi(key); n <= len(args) {
+			segs = args[n-1 : n]
+		}
+
+		if !query {
+			return strings.Join(segs, "/")
+		}
+
+		escaped := make([]string, len(segs))
+		for i, seg := range segs {
+			escaped[i] = queryEscapeSegment(seg)
+		}
+		return strings.Join(escaped, "/")
+	})
+
+	return appendQuery(u, r.URL.RawQuery)
+}
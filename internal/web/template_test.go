@@ -0,0 +1,66 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	r, err := http.NewRequest("POST", "http://go/api/url/x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]error{
+		"https://github.com/":              nil,
+		"https://github.com/{1}/{2}":       nil,
+		"https://github.com/{*}":           nil,
+		"https://github.com/{1}?q={*}&x=1": nil,
+		"https://github.com/#{1}":          nil,
+		"https://{1}.example.com/":         errPlaceholderInHost,
+		"https://{1}/":                     errPlaceholderInHost,
+		"https://example.com{1}":           errPlaceholderInHost,
+		"https://example.com:{1}/":         errInvalidURL,
+		"https://{1}@example.com/":         errPlaceholderInHost,
+		"{1}://github.com/":                errPlaceholderInHost,
+		"mailto:{1}@example.com":           errPlaceholderInHost,
+		"https://go/{1}":                   errRedirectLoop,
+
+		// braces that aren't placeholders are part of the URL.
+		"https://github.com/{0}":                                  nil,
+		"https://github.com/{x}":                                  nil,
+		"https://github.com/{}":                                   nil,
+		"https://github.com/{1":                                   nil,
+		"https://github.com/1}":                                   nil,
+		"https://github.com/{{1}}":                                nil,
+		`https://grafana.example.com/explore?left={"queries":[]}`: nil,
+	}
+
+	for tmpl, expected := range tests {
+		if err := validateTemplate(r, tmpl); err != expected {
+			t.Fatalf("for %s, expected %v, got %v", tmpl, expected, err)
+		}
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com/{1}/{2}":                 "https://github.com/[1]/[2]",
+		"https://github.com/{*}":                     "https://github.com/[*]",
+		"https://github.com/{x}/{}/{-1}/{+1}/{0}":    "https://github.com/{x}/{}/{-1}/{+1}/{0}",
+		"https://github.com/{{1}}":                   "https://github.com/{[1]}",
+		"https://github.com/{1":                      "https://github.com/{1",
+		`https://grafana.example.com/?left={"q":[]}`: `https://grafana.example.com/?left={"q":[]}`,
+		`https://x.com/{1}?left={"q":{2}}`:           `https://x.com/[1]?left={"q":[2]}`,
+	}
+
+	for tmpl, expected := range tests {
+		u := expandTemplate(tmpl, func(key string, query bool) string {
+			return "[" + key + "]"
+		})
+
+		if u != expected {
+			t.Fatalf("for %s, expected %s, got %s", tmpl, expected, u)
+		}
+	}
+}
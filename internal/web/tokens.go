@@ -0,0 +1,288 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/auth"
+	"github.com/kellegous/go/internal/backend"
+)
+
+// API tokens look like go_<id>_<secret>. The prefix makes them easy to spot,
+// e.g. by secret scanners, and lets them be told apart from other bearer
+// tokens without a lookup.
+const apiTokenPrefix = "go_"
+
+// Generate a new API token, returning the token to store and the value to
+// give to the caller.
+func newAPIToken(id *auth.Identity, name string, scopes []string) (*internal.Token, string, error) {
+	var tid [8]byte
+	if _, err := rand.Read(tid[:]); err != nil {
+		return nil, "", err
+	}
+
+	var secret [32]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return nil, "", err
+	}
+
+	t := &internal.Token{
+		ID:      hex.EncodeToString(tid[:]),
+		Name:    name,
+		Owner:   id.Name,
+		Email:   id.Email,
+		Groups:  id.Groups,
+		Scopes:  scopes,
+		Created: time.Now(),
+	}
+
+	s := base64.RawURLEncoding.EncodeToString(secret[:])
+	h := sha256.Sum256([]byte(s))
+	t.Hash = h[:]
+
+	return t, apiTokenPrefix + t.ID + "_" + s, nil
+}
+
+// Split the value of an API token into its ID and secret.
+func parseAPIToken(v string) (string, string, bool) {
+	if !strings.HasPrefix(v, apiTokenPrefix) {
+		return "", "", false
+	}
+
+	id, secret, ok := strings.Cut(v[len(apiTokenPrefix):], "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+
+	return id, secret, true
+}
+
+// tokenProvider identifies callers by the API tokens stored in the backend.
+type tokenProvider struct {
+	backend backend.Backend
+}
+
+// Authenticate returns the identity of the owner of the request's API token,
+// limited to the token's scopes.
+func (p *tokenProvider) Authenticate(r *http.Request) (*auth.Identity, error) {
+	tid, secret, ok := parseAPIToken(auth.BearerToken(r))
+	if !ok {
+		return nil, nil
+	}
+
+	t, err := p.backend.GetToken(r.Context(), tid)
+	if errors.Is(err, internal.ErrTokenNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	h := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(h[:], t.Hash) != 1 {
+		return nil, nil
+	}
+
+	return &auth.Identity{
+		Name:   t.Owner,
+		Email:  t.Email,
+		Groups: t.Groups,
+
+		// never nil, since that would be unrestricted.
+		Scopes: append([]string{}, t.Scopes...),
+	}, nil
+}
+
+// Indicates whether the caller may create and revoke API tokens. Tokens can
+// only be managed with a token that has the admin scope, so that a leaked
+// token cannot be used to mint others.
+func canManageTokens(id *auth.Identity) bool {
+	return id.Scopes == nil || id.Allows(auth.ScopeAdmin)
+}
+
+// Indicates whether the caller may act as an admin, which includes managing
+// the tokens of everyone and reading the audit log.
+func isAdmin(id *auth.Identity) bool {
+	return id.Admin && id.Allows(auth.ScopeAdmin)
+}
+
+func toTokenInfo(t *internal.Token) *tokenInfo {
+	return &tokenInfo{
+		ID:      t.ID,
+		Name:    t.Name,
+		Owner:   t.Owner,
+		Scopes:  t.Scopes,
+		Created: t.Created,
+	}
+}
+
+func apiTokensGet(backend backend.Backend, w http.ResponseWriter, r *http.Request) {
+	id := auth.FromContext(r.Context())
+
+	all, err := parseBool(r.FormValue("all"), false)
+	if err != nil {
+		writeJSONError(w, "invalid all value", http.StatusBadRequest)
+		return
+	}
+
+	owner := id.Name
+	if all {
+		if !isAdmin(id) {
+			writeJSONError(w, "only admins can list all tokens", http.StatusForbidden)
+			return
+		}
+		owner = ""
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	tokens, err := backend.ListTokens(ctx, owner)
+	if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	res := msgTokens{
+		Ok:     true,
+		Tokens: make([]*tokenInfo, 0, len(tokens)),
+	}
+
+	for _, t := range tokens {
+		res.Tokens = append(res.Tokens, toTokenInfo(t))
+	}
+
+	writeJSON(w, &res, http.StatusOK)
+}
+
+func apiTokensPost(backend backend.Backend, audit *auditLog, w http.ResponseWriter, r *http.Request) {
+	id := auth.FromContext(r.Context())
+
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	if !canManageTokens(id) {
+		writeJSONError(w, "tokens cannot be created with this token", http.StatusForbidden)
+		return
+	}
+
+	scopes := normalizeList(req.Scopes)
+	if len(scopes) == 0 {
+		writeJSONError(w, "scopes required", http.StatusBadRequest)
+		return
+	}
+
+	for _, s := range scopes {
+		if !auth.ValidScope(s) {
+			writeJSONError(w, "invalid scope: "+s, http.StatusBadRequest)
+			return
+		}
+
+		if (s == auth.ScopeWriteAll || s == auth.ScopeAdmin) && !id.Admin {
+			writeJSONError(w, "only admins can create tokens with scope "+s, http.StatusForbidden)
+			return
+		}
+	}
+
+	t, val, err := newAPIToken(id, strings.TrimSpace(req.Name), scopes)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	if err := backend.PutToken(ctx, t); err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	audit.record(ctx, internal.AuditTokenCreate, t.ID, "scopes: "+strings.Join(t.Scopes, ", "))
+
+	// this is the only time the token's value is revealed.
+	writeJSON(w, &msgToken{
+		Ok:     true,
+		Token:  toTokenInfo(t),
+		Secret: val,
+	}, http.StatusOK)
+}
+
+func apiTokenDelete(backend backend.Backend, audit *auditLog, w http.ResponseWriter, r *http.Request) {
+	id := auth.FromContext(r.Context())
+	tid := parseName("/api/tokens/", r.URL.Path)
+
+	if !canManageTokens(id) {
+		writeJSONError(w, "tokens cannot be revoked with this token", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	t, err := backend.GetToken(ctx, tid)
+	if errors.Is(err, internal.ErrTokenNotFound) {
+		writeJSONError(w, "Not Found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	if t.Owner != id.Name && !isAdmin(id) {
+		// don't reveal that the token exists.
+		writeJSONError(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if err := backend.DelToken(ctx, tid); err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	audit.record(ctx, internal.AuditTokenRevoke, tid, "owner: "+t.Owner)
+
+	writeJSONOk(w)
+}
+
+func apiTokens(backend backend.Backend, audit *auditLog, w http.ResponseWriter, r *http.Request) {
+	if auth.FromContext(r.Context()) == nil {
+		writeJSONError(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	if parseName("/api/tokens/", r.URL.Path) != "" {
+		switch r.Method {
+		case "DELETE":
+			apiTokenDelete(backend, audit, w, r)
+		default:
+			writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		apiTokensGet(backend, w, r)
+	case "POST":
+		apiTokensPost(backend, audit, w, r)
+	default:
+		writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
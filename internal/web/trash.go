@@ -0,0 +1,163 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/auth"
+	"github.com/kellegous/go/internal/backend"
+)
+
+const (
+	// How long deleted routes are kept in the trash by default.
+	defaultTrashRetention = 30 * 24 * time.Hour
+
+	// How often the trash is checked for routes to purge.
+	trashPurgeInterval = time.Hour
+)
+
+func apiTrashGet(backend backend.Backend, retention time.Duration, w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	trash, err := backend.ListTrash(ctx)
+	if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	res := msgTrash{
+		Ok:     true,
+		Routes: make([]*trashedRoute, 0, len(trash)),
+	}
+
+	for _, t := range trash {
+		res.Routes = append(res.Routes, &trashedRoute{
+			TrashedRoute: t,
+			Expires:      t.Deleted.Add(retention),
+		})
+	}
+
+	writeJSON(w, &res, http.StatusOK)
+}
+
+func apiTrashRestorePost(
+	backend backend.Backend,
+	audit *auditLog,
+	host string,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	p := parseName("/api/trash/", r.URL.Path)
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	t, err := backend.GetTrash(ctx, p)
+	if errors.Is(err, internal.ErrRouteNotFound) {
+		writeJSONError(w, "Not Found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	if !canEdit(auth.FromContext(ctx), t.Route) {
+		writeJSONError(w, errMsgNotEditor, http.StatusForbidden)
+		return
+	}
+
+	rt, err := backend.Restore(ctx, p)
+	if errors.Is(err, internal.ErrRouteNotFound) {
+		writeJSONError(w, "Not Found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, internal.ErrRouteExists) {
+		writeJSONError(w, errMsgRouteExists, http.StatusConflict)
+		return
+	} else if err != nil {
+		writeJSONBackendError(w, err)
+		return
+	}
+
+	audit.record(ctx, internal.AuditRestore, p, rt.URL)
+
+	writeJSONRoute(w, p, rt, host, nil)
+}
+
+func apiTrash(
+	backend backend.Backend,
+	audit *auditLog,
+	retention time.Duration,
+	host string,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if parseName("/api/trash/", r.URL.Path) == "" {
+		switch r.Method {
+		case "GET":
+			apiTrashGet(backend, retention, w, r)
+		default:
+			writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if parseSubresource("/api/trash/", r.URL.Path) != "restore" {
+		writeJSONError(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		apiTrashRestorePost(backend, audit, host, w, r)
+	default:
+		writeJSONError(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// Permanently remove the routes that have been in the trash for longer than
+// the retention period.
+func purgeTrash(ctx context.Context, backend backend.Backend, audit *auditLog, retention time.Duration) error {
+	before := time.Now().Add(-retention)
+
+	names, err := backend.PurgeTrash(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		audit.record(ctx, internal.AuditPurge, name,
+			fmt.Sprintf("deleted before %s", before.Format(time.RFC3339)))
+	}
+
+	return nil
+}
+
+// Purge the trash periodically until the context is done.
+func runTrashPurger(
+	ctx context.Context,
+	backend backend.Backend,
+	audit *auditLog,
+	retention time.Duration,
+	interval time.Duration,
+) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if err := purgeTrash(ctx, backend, audit, retention); err != nil {
+			log.Printf("[error] purging trash: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -4,21 +4,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/kellegous/glue/metrics"
 	"github.com/spf13/viper"
 
 	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/auth"
 	"github.com/kellegous/go/internal/backend"
 )
 
+var errPassthroughRejected = errors.New("path and query not allowed")
+
 // The default handler responds to most requests. It is responsible for the
 // shortcut redirects and for sending unmapped shortcuts to the edit page.
 func getDefault(
 	backend backend.Backend,
+	hits *hitCounter,
 	assets http.Handler,
 	w http.ResponseWriter,
 	r *http.Request,
@@ -31,7 +38,7 @@ func getDefault(
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
 	defer cancel()
 
 	rt, err := backend.Get(ctx, p)
@@ -44,41 +51,165 @@ func getDefault(
 		log.Panic(err)
 	}
 
+	u, err := targetURL(rt, r)
+	if errors.Is(err, errPassthroughRejected) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("[error] %s: %s", p, err)
+		http.Error(w, "invalid URL", http.StatusInternalServerError)
+		return
+	}
+
+	hits.add(p, r, time.Now())
+
 	http.Redirect(w, r,
-		rt.URL,
+		u,
 		http.StatusTemporaryRedirect)
 }
 
+// Determine where a request for the given route should be redirected. The
+// path and query following the shortcut name are either expanded into the
+// URL template or handled according to the route's Passthrough.
+func targetURL(rt *internal.Route, r *http.Request) (string, error) {
+	if hasPlaceholders(rt.URL) {
+		return expandURL(rt.URL, r)
+	}
+
+	args := pathArgs(r)
+	if len(args) == 0 && r.URL.RawQuery == "" {
+		return rt.URL, nil
+	}
+
+	switch rt.Passthrough {
+	case internal.PassthroughIgnore:
+		return rt.URL, nil
+	case internal.PassthroughReject:
+		return "", errPassthroughRejected
+	}
+
+	u, err := url.Parse(rt.URL)
+	if err != nil {
+		return "", err
+	}
+
+	// opaque URLs, like mailto:, have no path to append to.
+	if u.Opaque != "" {
+		return rt.URL, nil
+	}
+
+	if len(args) > 0 {
+		u = u.JoinPath(args...)
+	}
+
+	return appendQuery(u.String(), r.URL.RawQuery)
+}
+
+// Indicates whether the request changes something, reads the audit log or is
+// for an admin endpoint, and so must come from an authenticated caller when
+// authentication is enabled.
+func requiresAuth(r *http.Request) bool {
+	if r.URL.Path == "/api/audit" || strings.HasPrefix(r.URL.Path, "/admin/") {
+		return true
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
 // ListenAndServe sets up all web routes, binds the port and handles incoming
 // web requests.
 func ListenAndServe(
 	backend backend.Backend,
 	assets http.Handler,
+	authn *auth.Authenticator,
 ) error {
 	addr := viper.GetString("addr")
 	admin := viper.GetBool("admin")
 	version := viper.GetString("version")
 	host := viper.GetString("host")
 	enableMetrics := viper.GetBool("metrics")
+	hitsInterval := viper.GetDuration("hits-flush-interval")
+	trashRetention := viper.GetDuration("trash-retention")
+
+	// API tokens are only useful when changes require authentication.
+	if authn.Enabled() {
+		authn = authn.With(&tokenProvider{backend})
+	}
+
+	var auditFile io.Writer
+	if filename := viper.GetString("audit-file"); filename != "" {
+		f, err := openAuditFile(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		auditFile = f
+	}
+	audit := newAuditLog(backend, auditFile)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go runTrashPurger(ctx, backend, audit, trashRetention, trashPurgeInterval)
+
+	hits := newHitCounter(backend)
+	go hits.run(ctx, hitsInterval)
+
+	// the visits buffered since the last flush are written before returning, so
+	// that they aren't lost when the server stops.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := hits.flush(ctx); err != nil {
+			log.Printf("[error] flushing hits: %s", err)
+		}
+	}()
 
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/api/url/", func(w http.ResponseWriter, r *http.Request) {
-		apiURL(backend, host, w, r)
+		apiURL(backend, audit, host, w, r)
 	})
 
 	mux.HandleFunc("/api/urls/", func(w http.ResponseWriter, r *http.Request) {
 		apiURLs(backend, host, w, r)
 	})
 
+	mux.HandleFunc("/api/tokens/", func(w http.ResponseWriter, r *http.Request) {
+		apiTokens(backend, audit, w, r)
+	})
+
+	mux.HandleFunc("/api/audit", func(w http.ResponseWriter, r *http.Request) {
+		apiAudit(backend, w, r)
+	})
+
+	mux.HandleFunc("/api/import", func(w http.ResponseWriter, r *http.Request) {
+		apiImport(backend, audit, w, r)
+	})
+
+	mux.HandleFunc("/api/trash/", func(w http.ResponseWriter, r *http.Request) {
+		apiTrash(backend, audit, trashRetention, host, w, r)
+	})
+
 	mux.HandleFunc("/api/config", func(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, struct {
 			Host string `json:"host"`
 		}{host}, http.StatusOK)
 	})
 
+	mux.HandleFunc("/api/me", func(w http.ResponseWriter, r *http.Request) {
+		apiMe(authn, w, r)
+	})
+
+	mux.Handle("/auth/", authn)
+
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		getDefault(backend, assets, w, r)
+		getDefault(backend, hits, assets, w, r)
 	})
 
 	mux.HandleFunc("/edit/", func(w http.ResponseWriter, r *http.Request) {
@@ -110,12 +241,12 @@ func ListenAndServe(
 
 	// TODO(knorton): Remove the admin handler.
 	if admin {
-		mux.Handle("/admin/", &adminHandler{backend})
+		mux.Handle("/admin/", &adminHandler{backend, audit})
 	}
 
-	var hdr http.Handler = mux
+	var hdr http.Handler = authn.Handler(mux, requiresAuth)
 	if enableMetrics {
-		hdr = metrics.ForHTTP(mux)
+		hdr = metrics.ForHTTP(hdr)
 	}
 
 	return http.ListenAndServe(addr, hdr)
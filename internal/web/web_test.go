@@ -1,3 +1,1242 @@
 package web
 
-// TODO: Add tests
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kellegous/go/internal"
+	"github.com/kellegous/go/internal/auth"
+	"github.com/kellegous/go/internal/dump"
+)
+
+func (e *env) redirect(path string) (*mockResponse, error) {
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &mockResponse{
+		header: map[string][]string{},
+	}
+
+	getDefault(e.backend, e.hits, http.NotFoundHandler(), res, req)
+
+	return res, nil
+}
+
+func mustRedirectTo(t *testing.T, res *mockResponse, url string) {
+	mustHaveStatus(t, res, http.StatusTemporaryRedirect)
+	if loc := res.Header().Get("Location"); loc != url {
+		t.Fatalf("expected redirect to %s, got %s", url, loc)
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := e.backend.Put(ctx, "a", &internal.Route{
+		URL:  "http://a.com/",
+		Time: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := e.redirect("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustRedirectTo(t, res, "http://a.com/")
+
+	res, err = e.redirect("/nothing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustRedirectTo(t, res, "/edit/nothing")
+}
+
+func TestRedirectTemplate(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	routes := map[string]string{
+		"gh":     "https://github.com/{1}/{2}",
+		"search": "https://www.google.com/search?q={*}",
+		"q":      "https://q.com/{*}?src=go",
+		"g":      `https://grafana.com/explore?left={"queries":[]}`,
+	}
+
+	for name, url := range routes {
+		if err := e.backend.Put(ctx, name, &internal.Route{
+			URL:  url,
+			Time: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]string{
+		"/gh/kellegous/go":        "https://github.com/kellegous/go",
+		"/gh/kellegous/go/issues": "https://github.com/kellegous/go",
+		"/gh/kellegous":           "https://github.com/kellegous/",
+		"/gh":                     "https://github.com//",
+		"/gh/a%2Fb/c":             "https://github.com/a%2Fb/c",
+		"/search/foo/bar":         "https://www.google.com/search?q=foo/bar",
+		"/search/a&b=c":           "https://www.google.com/search?q=a%26b%3Dc",
+		"/search/c++":             "https://www.google.com/search?q=c%2B%2B",
+		"/search/a%20b":           "https://www.google.com/search?q=a+b",
+		"/gh/a&b=c/c++":           "https://github.com/a&b=c/c++",
+		"/q/a/b?x=1":              "https://q.com/a/b?src=go&x=1",
+		"/g":                      `https://grafana.com/explore?left={"queries":[]}`,
+	}
+
+	for path, url := range tests {
+		res, err := e.redirect(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustRedirectTo(t, res, url)
+	}
+}
+
+func TestRedirectPassthrough(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	routes := map[string]*internal.Route{
+		"docs": {
+			URL:  "https://docs.com/wiki",
+			Time: time.Now(),
+		},
+		"slash": {
+			URL:         "https://slash.com/wiki/?a=b#top",
+			Time:        time.Now(),
+			Passthrough: internal.PassthroughAppend,
+		},
+		"ignore": {
+			URL:         "https://ignore.com/",
+			Time:        time.Now(),
+			Passthrough: internal.PassthroughIgnore,
+		},
+		"reject": {
+			URL:         "https://reject.com/",
+			Time:        time.Now(),
+			Passthrough: internal.PassthroughReject,
+		},
+		"mail": {
+			URL:  "mailto:a@b.com",
+			Time: time.Now(),
+		},
+	}
+
+	for name, rt := range routes {
+		if err := e.backend.Put(ctx, name, rt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]string{
+		"/docs":                   "https://docs.com/wiki",
+		"/docs/":                  "https://docs.com/wiki",
+		"/docs/setup/linux?x=1":   "https://docs.com/wiki/setup/linux?x=1",
+		"/docs/a%2Fb":             "https://docs.com/wiki/a%2Fb",
+		"/slash/setup?x=1":        "https://slash.com/wiki/setup?a=b&x=1#top",
+		"/ignore/setup/linux?x=1": "https://ignore.com/",
+		"/reject":                 "https://reject.com/",
+		"/mail/x":                 "mailto:a@b.com",
+	}
+
+	for path, url := range tests {
+		res, err := e.redirect(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustRedirectTo(t, res, url)
+	}
+
+	for _, path := range []string{"/reject/x", "/reject?x=1"} {
+		res, err := e.redirect(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustHaveStatus(t, res, http.StatusNotFound)
+	}
+}
+
+func TestRedirectCountsHits(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for _, name := range []string{"a", "b", "c"} {
+		if err := e.backend.Put(ctx, name, &internal.Route{
+			URL:  "http://" + name + ".com/",
+			Time: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	visits := map[string]int{
+		"/a":       1,
+		"/b":       3,
+		"/b/x":     1,
+		"/nothing": 2,
+	}
+
+	for path, n := range visits {
+		for i := 0; i < n; i++ {
+			if _, err := e.redirect(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// visits are buffered until they are flushed.
+	hits, err := e.backend.GetAllHits(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hits) != 0 {
+		t.Fatalf("expected no hits before flush, got %v", hits)
+	}
+
+	if err := e.hits.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	hits, err = e.backend.GetAllHits(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hits) != 2 || hits["a"].Count != 1 || hits["b"].Count != 4 {
+		t.Fatalf("unexpected hits: %v", hits)
+	}
+
+	res, err := e.get("/api/url/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	var m msgRoute
+	if err := json.NewDecoder(res).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	mustBeOk(t, m.Ok)
+
+	if m.Route.Hits == nil || m.Route.Hits.Count != 4 || m.Route.Hits.Last.IsZero() {
+		t.Fatalf("expected 4 hits, got %v", m.Route.Hits)
+	}
+
+	pages, err := getInPages(e, url.Values{
+		"sort":  {"hits"},
+		"limit": {"2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, page := range pages {
+		for _, rt := range page {
+			names = append(names, rt.Name)
+		}
+	}
+
+	if !reflect.DeepEqual(names, []string{"b", "a", "c"}) {
+		t.Fatalf("expected routes ordered [b a c], got %v", names)
+	}
+}
+
+func TestReferrersAreCapped(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := e.backend.Put(ctx, "a", &internal.Route{
+		URL:  "http://a.com/",
+		Time: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// all of the visits fall in the same bucket.
+	at := time.Now().UTC().Truncate(internal.ClickBucketSize)
+
+	visit := func(host string) {
+		r := httptest.NewRequest("GET", "/a", nil)
+		r.Header.Set("Referer", "http://"+host+"/")
+		e.hits.add("a", r, at)
+	}
+
+	// one host is more common than all the others.
+	for i := 0; i < 3; i++ {
+		visit("popular.com")
+	}
+
+	for i := 0; i < internal.MaxReferrers+10; i++ {
+		visit(fmt.Sprintf("%d.com", i))
+	}
+
+	if err := e.hits.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second flush is merged with the bucket that is already stored.
+	visit("late.com")
+	if err := e.hits.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	clicks, err := e.backend.GetClicks(ctx, "a", at, at.Add(internal.ClickBucketSize))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(clicks) != 1 {
+		t.Fatalf("expected 1 bucket, got %d", len(clicks))
+	}
+
+	refs := clicks[0].Referrers
+	if len(refs) != internal.MaxReferrers+1 {
+		t.Fatalf("expected %d referrers, got %d", internal.MaxReferrers+1, len(refs))
+	}
+
+	if refs["popular.com"] != 3 {
+		t.Fatalf("expected 3 visits from popular.com, got %d", refs["popular.com"])
+	}
+
+	var total int64
+	for _, n := range refs {
+		total += n
+	}
+
+	if total != internal.MaxReferrers+14 || refs[internal.OtherReferrer] == 0 {
+		t.Fatalf("expected the rest of the visits to be counted as other, got %v", refs)
+	}
+}
+
+func TestAgentOf(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36": "browser",
+		"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)":         "slack",
+		"curl/8.4.0":             "script",
+		"python-requests/2.31.0": "script",
+		"Go-http-client/1.1":     "script",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot)": "bot",
+		"": "other",
+	}
+
+	for ua, expected := range tests {
+		r, err := http.NewRequest("GET", "/a", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("User-Agent", ua)
+
+		if agent := agentOf(r); agent != expected {
+			t.Fatalf("for %q, expected %s, got %s", ua, expected, agent)
+		}
+	}
+}
+
+func TestAPIStats(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := e.backend.Put(ctx, "a", &internal.Route{
+		URL:  "http://a.com/",
+		Time: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	visits := []struct {
+		agent, referrer string
+	}{
+		{"curl/8.4.0", ""},
+		{"Mozilla/5.0", "https://Slack.com/archives/x"},
+		{"Mozilla/5.0", "https://slack.com/"},
+	}
+
+	for _, v := range visits {
+		req, err := http.NewRequest("GET", "/a", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", v.agent)
+		req.Header.Set("Referer", v.referrer)
+
+		getDefault(e.backend, e.hits, http.NotFoundHandler(), &mockResponse{
+			header: map[string][]string{},
+		}, req)
+	}
+
+	if err := e.hits.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := e.get("/api/url/a/stats?bucket=hour&from=" +
+		url.QueryEscape(time.Now().Add(-2*time.Hour).Format(time.RFC3339)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	var m msgClicks
+	if err := json.NewDecoder(res).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	mustBeOk(t, m.Ok)
+
+	if len(m.Buckets) != 3 {
+		t.Fatalf("expected 3 buckets, got %d", len(m.Buckets))
+	}
+
+	c := m.Buckets[len(m.Buckets)-1]
+	if c.Count != 3 {
+		t.Fatalf("expected 3 clicks in the last bucket, got %d", c.Count)
+	}
+
+	if !reflect.DeepEqual(c.Referrers, map[string]int64{"direct": 1, "slack.com": 2}) {
+		t.Fatalf("unexpected referrers: %v", c.Referrers)
+	}
+
+	if !reflect.DeepEqual(c.Agents, map[string]int64{"script": 1, "browser": 2}) {
+		t.Fatalf("unexpected agents: %v", c.Agents)
+	}
+
+	res, err = e.get("/api/url/a/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustHaveStatus(t, res, http.StatusOK)
+
+	if err := json.NewDecoder(res).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Bucket != "day" || m.Buckets[len(m.Buckets)-1].Count != 3 {
+		t.Fatalf("expected 3 clicks today, got %v", m.Buckets)
+	}
+
+	for _, params := range []string{
+		"bucket=minute",
+		"from=yesterday",
+		"from=2020-01-02&to=2020-01-01",
+		"bucket=hour&from=2000-01-01&to=2020-01-01",
+	} {
+		res, err := e.get("/api/url/a/stats?" + params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustHaveStatus(t, res, http.StatusBadRequest)
+	}
+}
+
+// Wrap the env's handlers in an authenticator that accepts the given static
+// tokens as well as API tokens.
+func (e *env) withAuth(tokens map[string]*auth.Identity, admins ...string) http.Handler {
+	authn := auth.New(auth.NewTokens(tokens))
+	authn.Admins = admins
+	authn = authn.With(&tokenProvider{e.backend})
+	e.mux.HandleFunc("/api/me", func(w http.ResponseWriter, r *http.Request) {
+		apiMe(authn, w, r)
+	})
+	return authn.Handler(e.mux, requiresAuth)
+}
+
+func callWithToken(h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func mustHaveCode(t *testing.T, w *httptest.ResponseRecorder, code int) *httptest.ResponseRecorder {
+	t.Helper()
+	if w.Code != code {
+		t.Fatalf("expected status %d, got %d: %s", code, w.Code, w.Body.String())
+	}
+	return w
+}
+
+func TestAuthRequiredForChanges(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	h := e.withAuth(map[string]*auth.Identity{
+		"s3cret": {Name: "alice"},
+	})
+
+	call := func(method, path, token string, body string) *httptest.ResponseRecorder {
+		return callWithToken(h, method, path, token, body)
+	}
+	if w := call("POST", "/api/url/xxx", "", `{"url": "http://ex.com/"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	if w := call("DELETE", "/api/url/xxx", "", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	w := call("POST", "/api/url/xxx", "s3cret", `{"url": "http://ex.com/"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var rm msgRoute
+	if err := json.NewDecoder(w.Body).Decode(&rm); err != nil {
+		t.Fatal(err)
+	}
+
+	if rm.Route.ModifiedBy != "alice" {
+		t.Fatalf("expected modified_by of alice, got %s", rm.Route.ModifiedBy)
+	}
+
+	// reads remain open to anonymous callers.
+	if w := call("GET", "/api/url/xxx", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var mm msgMe
+	if err := json.NewDecoder(call("GET", "/api/me", "s3cret", "").Body).Decode(&mm); err != nil {
+		t.Fatal(err)
+	}
+
+	if !mm.AuthEnabled || mm.Identity == nil || mm.Identity.Name != "alice" {
+		t.Fatalf("unexpected identity: %v", mm)
+	}
+}
+
+func TestAPIOwnership(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	h := e.withAuth(map[string]*auth.Identity{
+		"alice": {Name: "alice"},
+		"bob":   {Name: "bob"},
+		"carol": {Name: "carol", Groups: []string{"eng"}},
+		"root":  {Name: "root", Groups: []string{"admins"}},
+	}, "admins")
+
+	// the creator owns the route.
+	w := callWithToken(h, "POST", "/api/url/xxx", "alice", `{"url": "http://ex.com/"}`)
+	mustHaveCode(t, w, http.StatusOK)
+
+	rt, err := e.backend.Get(context.Background(), "xxx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.Owner != "alice" {
+		t.Fatalf("expected owner of alice, got %s", rt.Owner)
+	}
+
+	// others can neither change nor delete it.
+	w = callWithToken(h, "POST", "/api/url/xxx", "bob", `{"url": "http://ex.com/bob"}`)
+	mustHaveCode(t, w, http.StatusForbidden)
+
+	var em msgErr
+	if err := json.NewDecoder(w.Body).Decode(&em); err != nil {
+		t.Fatal(err)
+	}
+
+	if em.Ok || em.Error != errMsgNotEditor {
+		t.Fatalf("unexpected error: %v", em)
+	}
+
+	mustHaveCode(t, callWithToken(h, "DELETE", "/api/url/xxx", "bob", ""), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx/revert", "bob", `{"revision": 1}`), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx/owner", "bob", `{"owner": "bob"}`), http.StatusForbidden)
+
+	// the owner can add a group as a co-owner, whose members can then make
+	// changes but not change the owners.
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx/owner", "alice", `{"co_owners": ["eng"]}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx", "carol", `{"url": "http://ex.com/carol"}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx", "carol", `{"url": "http://ex.com/", "owner": "carol"}`), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx/owner", "carol", `{"co_owners": []}`), http.StatusForbidden)
+
+	// the owner can transfer the route.
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx/owner", "alice", `{"owner": "bob"}`), http.StatusOK)
+
+	w = callWithToken(h, "GET", "/api/url/xxx/owner", "", "")
+	mustHaveCode(t, w, http.StatusOK)
+
+	var om msgOwners
+	if err := json.NewDecoder(w.Body).Decode(&om); err != nil {
+		t.Fatal(err)
+	}
+
+	if om.Owner != "bob" || !reflect.DeepEqual(om.CoOwners, []string{"eng"}) {
+		t.Fatalf("unexpected owners: %v", om)
+	}
+
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx", "alice", `{"url": "http://ex.com/alice"}`), http.StatusForbidden)
+
+	// admins can do anything.
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx", "root", `{"url": "http://ex.com/root"}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "DELETE", "/api/url/xxx", "root", ""), http.StatusOK)
+
+	// the owner can't be cleared, which would let anyone change the route.
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/yyy", "alice", `{"url": "http://ex.com/"}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/yyy", "alice", `{"url": "http://ex.com/", "owner": "  "}`), http.StatusBadRequest)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/yyy", "bob", `{"url": "http://ex.com/bob"}`), http.StatusForbidden)
+
+	// routes from before ownership stay unowned when they are changed, until
+	// someone takes them.
+	if err := e.backend.Put(context.Background(), "legacy", &internal.Route{URL: "http://ex.com/"}); err != nil {
+		t.Fatal(err)
+	}
+
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/legacy", "alice", `{"url": "http://ex.com/alice"}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/legacy", "bob", `{"url": "http://ex.com/bob"}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/legacy", "bob", `{"url": "http://ex.com/bob", "owner": "bob"}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/legacy", "alice", `{"url": "http://ex.com/alice"}`), http.StatusForbidden)
+}
+
+func TestAPITokens(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	h := e.withAuth(map[string]*auth.Identity{
+		"alice": {Name: "alice"},
+		"root":  {Name: "root"},
+	}, "root")
+
+	create := func(token, body string) *msgToken {
+		t.Helper()
+		w := mustHaveCode(t, callWithToken(h, "POST", "/api/tokens/", token, body), http.StatusOK)
+
+		var m msgToken
+		if err := json.NewDecoder(w.Body).Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		return &m
+	}
+
+	mustHaveCode(t, callWithToken(h, "GET", "/api/tokens/", "", ""), http.StatusUnauthorized)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/tokens/", "alice", `{"scopes": []}`), http.StatusBadRequest)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/tokens/", "alice", `{"scopes": ["write:some"]}`), http.StatusBadRequest)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/tokens/", "alice", `{"scopes": ["write:all"]}`), http.StatusForbidden)
+
+	ci := create("alice", `{"name": "ci", "scopes": ["write:own"]}`)
+	if !strings.HasPrefix(ci.Secret, apiTokenPrefix) || ci.Token.Owner != "alice" {
+		t.Fatalf("unexpected token: %v", ci)
+	}
+
+	ro := create("alice", `{"name": "dashboard", "scopes": ["read"]}`)
+
+	// only hashes of the secrets are stored.
+	stored, err := e.backend.GetToken(context.Background(), ci.Token.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(stored.Hash), ci.Secret[len(apiTokenPrefix)+len(ci.Token.ID)+1:]) {
+		t.Fatal("token secret was stored")
+	}
+
+	// tokens act on behalf of their owners, within their scopes.
+	w := mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx", ci.Secret, `{"url": "http://ex.com/"}`), http.StatusOK)
+
+	var rm msgRoute
+	if err := json.NewDecoder(w.Body).Decode(&rm); err != nil {
+		t.Fatal(err)
+	}
+
+	if rm.Route.Owner != "alice" {
+		t.Fatalf("expected owner of alice, got %s", rm.Route.Owner)
+	}
+
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx", ro.Secret, `{"url": "http://ex.com/ro"}`), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/yyy", ro.Secret, `{"url": "http://ex.com/ro"}`), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "GET", "/api/url/xxx", ro.Secret, ""), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/tokens/", ci.Secret, `{"scopes": ["read"]}`), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "GET", "/api/url/xxx", ci.Secret[:len(ci.Secret)-1], ""), http.StatusUnauthorized)
+
+	// an admin's write:all token can change anything, but their write:own
+	// token cannot.
+	all := create("root", `{"scopes": ["write:all"]}`)
+	own := create("root", `{"scopes": ["write:own"]}`)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx", own.Secret, `{"url": "http://ex.com/own"}`), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/xxx", all.Secret, `{"url": "http://ex.com/all"}`), http.StatusOK)
+
+	w = mustHaveCode(t, callWithToken(h, "GET", "/api/tokens/", "alice", ""), http.StatusOK)
+
+	var tm msgTokens
+	if err := json.NewDecoder(w.Body).Decode(&tm); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tm.Tokens) != 2 {
+		t.Fatalf("expected 2 tokens, got %d", len(tm.Tokens))
+	}
+
+	mustHaveCode(t, callWithToken(h, "GET", "/api/tokens/?all=true", "alice", ""), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "GET", "/api/tokens/?all=true", "root", ""), http.StatusOK)
+
+	// admins and owners can revoke tokens, after which they no longer work.
+	mustHaveCode(t, callWithToken(h, "DELETE", "/api/tokens/"+ci.Token.ID, "root", ""), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "DELETE", "/api/tokens/"+ro.Token.ID, all.Secret, ""), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "DELETE", "/api/tokens/"+all.Token.ID, "alice", ""), http.StatusNotFound)
+	mustHaveCode(t, callWithToken(h, "GET", "/api/url/xxx", ci.Secret, ""), http.StatusUnauthorized)
+}
+
+func TestAPIAudit(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	h := e.withAuth(map[string]*auth.Identity{
+		"alice": {Name: "alice"},
+		"root":  {Name: "root"},
+	}, "root")
+
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/payroll", "alice", `{"url": "http://ex.com/"}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/payroll", "alice", `{"url": "http://ex.com/a"}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/other", "alice", `{"url": "http://ex.com/"}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "DELETE", "/api/url/payroll", "root", ""), http.StatusOK)
+
+	// the audit log is only for admins.
+	mustHaveCode(t, callWithToken(h, "GET", "/api/audit", "", ""), http.StatusUnauthorized)
+	mustHaveCode(t, callWithToken(h, "GET", "/api/audit", "alice", ""), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "GET", "/api/audit?since=yesterday", "root", ""), http.StatusBadRequest)
+
+	w := mustHaveCode(t, callWithToken(h, "GET", "/api/audit?name=payroll", "root", ""), http.StatusOK)
+
+	var am msgAudit
+	if err := json.NewDecoder(w.Body).Decode(&am); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, ev := range am.Events {
+		got = append(got, ev.Actor+" "+ev.Action)
+	}
+
+	if !reflect.DeepEqual(got, []string{"alice create", "alice update", "root delete"}) {
+		t.Fatalf("unexpected events: %v", got)
+	}
+
+	w = mustHaveCode(t, callWithToken(h, "GET", "/api/audit?actor=alice&format=jsonl", "root", ""), http.StatusOK)
+
+	var n int
+	dec := json.NewDecoder(w.Body)
+	for dec.More() {
+		var ev internal.AuditEvent
+		if err := dec.Decode(&ev); err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+
+	if n != 3 {
+		t.Fatalf("expected 3 exported events, got %d", n)
+	}
+}
+
+func TestAuditFile(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	var buf bytes.Buffer
+	a := newAuditLog(e.backend, &buf)
+	a.record(internal.WithActor(context.Background(), "alice"), internal.AuditDump, "", "")
+
+	var ev internal.AuditEvent
+	if err := json.NewDecoder(&buf).Decode(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev.Actor != "alice" || ev.Action != internal.AuditDump || ev.ID == "" {
+		t.Fatalf("unexpected event: %v", ev)
+	}
+}
+
+func TestAPITrash(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	h := e.withAuth(map[string]*auth.Identity{
+		"alice": {Name: "alice"},
+		"bob":   {Name: "bob"},
+	})
+
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/payroll", "alice", `{"url": "http://ex.com/"}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "DELETE", "/api/url/payroll", "alice", ""), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "GET", "/api/url/payroll", "", ""), http.StatusNotFound)
+
+	w := mustHaveCode(t, callWithToken(h, "GET", "/api/trash/", "", ""), http.StatusOK)
+
+	var tm msgTrash
+	if err := json.NewDecoder(w.Body).Decode(&tm); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tm.Routes) != 1 || tm.Routes[0].Name != "payroll" || tm.Routes[0].DeletedBy != "alice" {
+		t.Fatalf("unexpected trash: %v", tm.Routes)
+	}
+
+	if d := tm.Routes[0].Expires.Sub(tm.Routes[0].Deleted); d != defaultTrashRetention {
+		t.Fatalf("expected expiry after %s, got %s", defaultTrashRetention, d)
+	}
+
+	// only those who could change the route can restore it.
+	mustHaveCode(t, callWithToken(h, "POST", "/api/trash/payroll/restore", "bob", ""), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/trash/nope/restore", "alice", ""), http.StatusNotFound)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/trash/payroll/restore", "alice", ""), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "GET", "/api/url/payroll", "", ""), http.StatusOK)
+
+	// a name that has been taken again cannot be restored over.
+	mustHaveCode(t, callWithToken(h, "DELETE", "/api/url/payroll", "alice", ""), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/payroll", "bob", `{"url": "http://ex.com/bob"}`), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/trash/payroll/restore", "alice", ""), http.StatusConflict)
+
+	// reverting a route that is in the trash takes it out of the trash.
+	mustHaveCode(t, callWithToken(h, "DELETE", "/api/url/payroll", "bob", ""), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/payroll/revert", "alice", `{"revision": 1}`), http.StatusForbidden)
+	w = mustHaveCode(t, callWithToken(h, "POST", "/api/url/payroll/revert", "bob", `{"revision": 1}`), http.StatusOK)
+
+	var rm msgRoute
+	if err := json.NewDecoder(w.Body).Decode(&rm); err != nil {
+		t.Fatal(err)
+	}
+
+	if rm.Route.URL != "http://ex.com/" || rm.Route.Owner != "bob" {
+		t.Fatalf("unexpected route: %+v", rm.Route)
+	}
+
+	if _, err := e.backend.GetTrash(context.Background(), "payroll"); !errors.Is(err, internal.ErrRouteNotFound) {
+		t.Fatalf("expected payroll to be out of the trash, got %v", err)
+	}
+
+	mustHaveCode(t, callWithToken(h, "DELETE", "/api/url/payroll", "bob", ""), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/trash/payroll/restore", "bob", ""), http.StatusOK)
+	mustHaveCode(t, callWithToken(h, "DELETE", "/api/url/payroll", "bob", ""), http.StatusOK)
+
+	audit := newAuditLog(e.backend, nil)
+	if err := purgeTrash(context.Background(), e.backend, audit, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	trash, err := e.backend.ListTrash(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(trash) != 1 {
+		t.Fatalf("expected 1 route in the trash, got %d", len(trash))
+	}
+
+	if err := purgeTrash(context.Background(), e.backend, audit, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	trash, err = e.backend.ListTrash(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(trash) != 0 {
+		t.Fatalf("expected the trash to be purged, got %v", trash)
+	}
+
+	events, err := e.backend.ListAudit(context.Background(), &internal.AuditFilter{Name: "payroll"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var purged int
+	for _, ev := range events {
+		if ev.Action == internal.AuditPurge {
+			purged++
+		}
+	}
+
+	if purged != 1 {
+		t.Fatalf("expected the purge of payroll to be audited once, got %d", purged)
+	}
+}
+
+func TestAPIConditionalPut(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	post := func(path, ifMatch, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", path, strings.NewReader(body))
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		e.mux.ServeHTTP(w, r)
+		return w
+	}
+
+	// there is nothing to match yet.
+	mustHaveCode(t, post("/api/url/a", "*", `{"url": "http://a/0"}`), http.StatusPreconditionFailed)
+	mustHaveCode(t, post("/api/url/a", `"0"`, `{"url": "http://a/0"}`), http.StatusPreconditionFailed)
+
+	w := mustHaveCode(t, post("/api/url/a", "", `{"url": "http://a/1"}`), http.StatusOK)
+	if etag := w.Header().Get("ETag"); etag != `"1"` {
+		t.Fatalf(`expected ETag of "1", got %s`, etag)
+	}
+
+	w = mustHaveCode(t, callWithToken(e.mux, "GET", "/api/url/a", "", ""), http.StatusOK)
+	if etag := w.Header().Get("ETag"); etag != `"1"` {
+		t.Fatalf(`expected ETag of "1", got %s`, etag)
+	}
+
+	var m msgRoute
+	if err := json.NewDecoder(w.Body).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Route.Revision != 1 {
+		t.Fatalf("expected revision 1, got %d", m.Route.Revision)
+	}
+
+	mustHaveCode(t, post("/api/url/a", "1", `{"url": "http://a/2"}`), http.StatusBadRequest)
+	mustHaveCode(t, post("/api/url/a", `W/"1"`, `{"url": "http://a/2"}`), http.StatusBadRequest)
+
+	w = mustHaveCode(t, post("/api/url/a", `"1"`, `{"url": "http://a/2"}`), http.StatusOK)
+	if etag := w.Header().Get("ETag"); etag != `"2"` {
+		t.Fatalf(`expected ETag of "2", got %s`, etag)
+	}
+
+	// a stale revision is rejected either way it is given.
+	mustHaveCode(t, post("/api/url/a", `"1"`, `{"url": "http://a/3"}`), http.StatusPreconditionFailed)
+	mustHaveCode(t, post("/api/url/a", "", `{"url": "http://a/3", "revision": 1}`), http.StatusConflict)
+
+	mustHaveCode(t, post("/api/url/a", "", `{"url": "http://a/3", "revision": 2}`), http.StatusOK)
+	mustHaveCode(t, post("/api/url/a", "*", `{"url": "http://a/4"}`), http.StatusOK)
+
+	rt, err := e.backend.Get(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.URL != "http://a/4" || rt.Revision != 4 {
+		t.Fatalf("expected http://a/4 at revision 4, got %s at revision %d", rt.URL, rt.Revision)
+	}
+}
+
+func TestAPICreateOnly(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	post := func(path, ifNoneMatch, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", path, strings.NewReader(body))
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		e.mux.ServeHTTP(w, r)
+		return w
+	}
+
+	mustHaveCode(t, post("/api/url/a?create=true", "", `{"url": "http://a/1"}`), http.StatusOK)
+	mustHaveCode(t, post("/api/url/a?create=true", "", `{"url": "http://a/2"}`), http.StatusConflict)
+	mustHaveCode(t, post("/api/url/a", "*", `{"url": "http://a/2"}`), http.StatusPreconditionFailed)
+	mustHaveCode(t, post("/api/url/b", "*", `{"url": "http://b/1"}`), http.StatusOK)
+
+	// generated names are always new.
+	mustHaveCode(t, post("/api/url/?create=true", "", `{"url": "http://c/1"}`), http.StatusOK)
+
+	mustHaveCode(t, post("/api/url/a", `"1"`, `{"url": "http://a/2"}`), http.StatusBadRequest)
+	mustHaveCode(t, post("/api/url/a?create=nope", "", `{"url": "http://a/2"}`), http.StatusBadRequest)
+	mustHaveCode(t, post("/api/url/a?create=true", "", `{"url": "http://a/2", "revision": 1}`), http.StatusBadRequest)
+
+	rt, err := e.backend.Get(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.URL != "http://a/1" {
+		t.Fatalf("expected http://a/1, got %s", rt.URL)
+	}
+
+	// without a flag, the route is replaced.
+	mustHaveCode(t, post("/api/url/a", "", `{"url": "http://a/2"}`), http.StatusOK)
+}
+
+func TestAdminDumps(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	ctx := context.Background()
+	for _, name := range []string{"c", "a", "b"} {
+		if err := e.backend.Put(ctx, name, &internal.Route{
+			URL:  "http://" + name + "/",
+			Time: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := &adminHandler{e.backend, newAuditLog(e.backend, nil)}
+
+	w := mustHaveCode(t, callWithToken(h, "GET", "/admin/dumps", "", ""), http.StatusOK)
+
+	// the routes are written in order of name.
+	if body := w.Body.String(); !strings.HasPrefix(body, `{"a":{"url":"http://a/"`) {
+		t.Fatalf("expected a to be first, got %s", body)
+	}
+
+	var all map[string]internal.Route
+	if err := json.NewDecoder(w.Body).Decode(&all); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 3 || all["b"].URL != "http://b/" {
+		t.Fatalf("unexpected dump: %v", all)
+	}
+
+	r := httptest.NewRequest("GET", "/admin/dumps?format=jsonl", nil)
+	r.Header.Set("Accept-Encoding", "deflate, gzip")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	mustHaveCode(t, w, http.StatusOK)
+
+	if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("expected a gzipped dump, got %q", enc)
+	}
+
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	dec := json.NewDecoder(zr)
+	for dec.More() {
+		var rt dump.Entry
+		if err := dec.Decode(&rt); err != nil {
+			t.Fatal(err)
+		}
+
+		if rt.URL != "http://"+rt.Name+"/" {
+			t.Fatalf("unexpected route for %s: %v", rt.Name, rt.Route)
+		}
+		names = append(names, rt.Name)
+	}
+
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Fatalf("expected a, b and c, got %v", names)
+	}
+
+	w = mustHaveCode(t, callWithToken(h, "GET", "/admin/dumps?format=csv", "", ""), http.StatusOK)
+
+	entries, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 4 || entries[0][0] != "name" || entries[1][0] != "a" {
+		t.Fatalf("unexpected csv dump: %v", entries)
+	}
+
+	mustHaveCode(t, callWithToken(h, "GET", "/admin/dumps?format=xml", "", ""), http.StatusBadRequest)
+
+	events, err := e.backend.ListAudit(ctx, &internal.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 3 || events[0].Action != internal.AuditDump {
+		t.Fatalf("expected 3 dumps to be audited, got %v", events)
+	}
+}
+
+func TestAdminDumpsRequireAdmin(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	e.mux.Handle("/admin/", &adminHandler{e.backend, newAuditLog(e.backend, nil)})
+	h := e.withAuth(map[string]*auth.Identity{
+		"alice": {Name: "alice"},
+		"root":  {Name: "root"},
+	}, "root")
+
+	mustHaveCode(t, callWithToken(h, "GET", "/admin/dumps", "", ""), http.StatusUnauthorized)
+	mustHaveCode(t, callWithToken(h, "GET", "/admin/", "", ""), http.StatusUnauthorized)
+	mustHaveCode(t, callWithToken(h, "GET", "/admin/dumps", "alice", ""), http.StatusForbidden)
+	mustHaveCode(t, callWithToken(h, "GET", "/admin/dumps", "root", ""), http.StatusOK)
+
+	events, err := e.backend.ListAudit(context.Background(), &internal.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0].Action != internal.AuditDump || events[0].Actor != "root" {
+		t.Fatalf("expected one dump by root to be audited, got %v", events)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		gzip   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"br", false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/admin/dumps", nil)
+		r.Header.Set("Accept-Encoding", test.header)
+		if got := acceptsGzip(r); got != test.gzip {
+			t.Errorf("%q: expected %t, got %t", test.header, test.gzip, got)
+		}
+	}
+}
+
+func TestAPIImport(t *testing.T) {
+	e := needEnv(t, "")
+	defer e.destroy()
+
+	h := e.withAuth(map[string]*auth.Identity{
+		"alice": {Name: "alice"},
+		"root":  {Name: "root"},
+	}, "root")
+
+	mustHaveCode(t, callWithToken(h, "POST", "/api/url/a", "alice", `{"url": "http://ex.com/a"}`), http.StatusOK)
+
+	body := `{"name": "a", "url": "http://ex.com/a2", "owner": "bob", "created": "2020-01-02T03:04:05Z", "time": "2021-01-02T03:04:05Z"}
+{"name": ":2", "url": "http://ex.com/gen"}
+`
+
+	// importing is only for admins.
+	mustHaveCode(t, callWithToken(h, "POST", "/api/import", "", body), http.StatusUnauthorized)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/import", "alice", body), http.StatusForbidden)
+
+	mustHaveCode(t, callWithToken(h, "POST", "/api/import?conflict=maybe", "root", body), http.StatusBadRequest)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/import?format=xml", "root", body), http.StatusBadRequest)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/import", "root", `{"name": "api", "url": "http://ex.com/"}`), http.StatusBadRequest)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/import", "root", `{"name": "b", "url": "nope"}`), http.StatusBadRequest)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/import", "root", body+body), http.StatusBadRequest)
+	mustHaveCode(t, callWithToken(h, "POST", "/api/import", "root", ""), http.StatusBadRequest)
+
+	decode := func(w *httptest.ResponseRecorder) *msgImport {
+		var m msgImport
+		if err := json.NewDecoder(w.Body).Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		return &m
+	}
+
+	// by default, nothing is imported if any of the routes exist.
+	m := decode(mustHaveCode(t, callWithToken(h, "POST", "/api/import", "root", body), http.StatusConflict))
+	if m.Ok || !reflect.DeepEqual(m.Skipped, []string{"a"}) {
+		t.Fatalf("expected a to conflict, got %+v", m)
+	}
+
+	if _, err := e.backend.Get(context.Background(), ":2"); err == nil {
+		t.Fatal("expected :2 not to be imported")
+	}
+
+	m = decode(mustHaveCode(t, callWithToken(h, "POST", "/api/import?conflict=skip", "root", body), http.StatusOK))
+	if !reflect.DeepEqual(m, &msgImport{Ok: true, Created: []string{":2"}, Updated: []string{}, Skipped: []string{"a"}}) {
+		t.Fatalf("unexpected result: %+v", m)
+	}
+
+	// generated names that were imported aren't generated again.
+	if id, err := e.backend.LastID(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if id != 2 {
+		t.Fatalf("expected the last id to be 2, got %d", id)
+	}
+
+	csv := "name,url,owner,created,time\na,http://ex.com/a2,bob,2020-01-02T03:04:05Z,2021-01-02T03:04:05Z\n"
+	r := httptest.NewRequest("POST", "/api/import?conflict=overwrite", strings.NewReader(csv))
+	r.Header.Set("Authorization", "Bearer root")
+	r.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	m = decode(mustHaveCode(t, w, http.StatusOK))
+	if !reflect.DeepEqual(m.Updated, []string{"a"}) {
+		t.Fatalf("expected a to be updated, got %+v", m)
+	}
+
+	// imported routes keep their owners and timestamps.
+	rt, err := e.backend.Get(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.URL != "http://ex.com/a2" || rt.Owner != "bob" || rt.ModifiedBy != "root" ||
+		!rt.Created.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) ||
+		!rt.Time.Equal(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("unexpected route: %+v", rt)
+	}
+
+	events, err := e.backend.ListAudit(context.Background(), &internal.AuditFilter{Actor: "root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each route that was written is audited, followed by the import itself.
+	if len(events) != 4 ||
+		events[0].Action != internal.AuditCreate || events[0].Name != ":2" || events[0].Detail != "http://ex.com/gen" ||
+		events[2].Action != internal.AuditUpdate || events[2].Name != "a" || events[2].Detail != "http://ex.com/a2" ||
+		events[3].Action != internal.AuditImport || events[3].Detail != "created 0, updated 1, skipped 0" {
+		t.Fatalf("unexpected events: %v", events)
+	}
+
+	// the body is the dump even when it's sent as a form, as curl -d does.
+	r = httptest.NewRequest("POST", "/api/import?conflict=skip", strings.NewReader(`{"name": "c", "url": "http://ex.com/c"}`))
+	r.Header.Set("Authorization", "Bearer root")
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	m = decode(mustHaveCode(t, w, http.StatusOK))
+	if !reflect.DeepEqual(m.Created, []string{"c"}) {
+		t.Fatalf("expected c to be created, got %+v", m)
+	}
+}
+
+func TestDecodeID(t *testing.T) {
+	for _, id := range []uint64{1, 61, 62, 12345678} {
+		if d, ok := decodeID(encodeID(id)); !ok || d != id {
+			t.Fatalf("expected %d, got %d", id, d)
+		}
+	}
+
+	for _, name := range []string{"", ":", "a", ":-"} {
+		if _, ok := decodeID(name); ok {
+			t.Fatalf("expected %q not to decode", name)
+		}
+	}
+}
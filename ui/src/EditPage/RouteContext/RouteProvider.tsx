@@ -29,14 +29,23 @@ export const RouteProvider = ({ children }: { children: ReactNode }) => {
     ).then(setResult);
   }, [setResult, name]);
 
-  const updateRoute = async (name: string, url: string) =>
+  // edits of an existing route are conditional on it not having changed
+  // since it was loaded and new routes never replace one that was created
+  // in the meantime.
+  const updateRoute = async (name: string, url: string) => {
+    const { value } = result;
+    const options =
+      value.name === name && value.url !== ""
+        ? { revision: value.revision }
+        : { create: true };
     setResult(
       await Result.from(
-        () => api.postRoute(name, url),
+        () => api.postRoute(name, url, options),
         { name, url },
         apiErrorToString
       )
     );
+  };
 
   const deleteRoute = async (name: string) =>
     setResult(
@@ -14,15 +14,26 @@ export const UrlInput = () => {
 
   const [url, setUrl] = useState(route.url);
 
+  // whether the user has been warned that submitting will replace the url
+  // of an existing route.
+  const [confirming, setConfirming] = useState(false);
+
   useEffect(() => {
     setUrl(route.url);
-  }, [setUrl, route]);
+    setConfirming(false);
+  }, [setUrl, setConfirming, route]);
 
   const urlDidChange = (event: React.ChangeEvent<HTMLInputElement>) => {
     setUrl(event.target.value);
+    setConfirming(false);
   };
 
   const formDidSubmit = () => {
+    if (route.url !== "" && url !== route.url && !confirming) {
+      setConfirming(true);
+      return;
+    }
+    setConfirming(false);
     updateRoute(route.name, url);
   };
 
@@ -53,11 +64,18 @@ export const UrlInput = () => {
         />
       </div>
       <Drawer
-        visible={routeHasUrl || hasError}
-        style={hasError ? DrawerStyle.Error : DrawerStyle.Normal}
+        visible={routeHasUrl || hasError || confirming}
+        style={hasError || confirming ? DrawerStyle.Error : DrawerStyle.Normal}
       >
-        {hasError && <div>{String(error)}</div>}
-        {routeHasUrl && !hasError && <Link name={route.name} host={host} />}
+        {confirming && (
+          <div>
+            This replaces {route.url}. Press enter again to replace it.
+          </div>
+        )}
+        {hasError && !confirming && <div>{String(error)}</div>}
+        {routeHasUrl && !hasError && !confirming && (
+          <Link name={route.name} host={host} />
+        )}
       </Drawer>
     </CenterForm>
   );
@@ -3,12 +3,14 @@ interface RawRoute {
   url: string;
   source_host: string;
   time: string;
+  revision: number;
 }
 
 export interface Route {
   name: string;
   url: string;
   time?: Date;
+  revision?: number;
 }
 
 export interface Config {
@@ -16,8 +18,8 @@ export interface Config {
 }
 
 function toRoute(route: RawRoute): Route {
-  const { name, url, time } = route;
-  return { name, url, time: new Date(time) };
+  const { name, url, time, revision } = route;
+  return { name, url, time: new Date(time), revision };
 }
 
 interface RouteResponse {
@@ -95,13 +97,33 @@ export async function* getAllRoutes(
   } while (cursor !== "");
 }
 
-export async function postRoute(name: string, url: string): Promise<Route> {
+export interface PostOptions {
+  // The revision of the route that was read, if any. The route is only
+  // changed if nobody else has changed it since.
+  revision?: number;
+
+  // Whether to only create the route, failing if it already exists.
+  create?: boolean;
+}
+
+export async function postRoute(
+  name: string,
+  url: string,
+  { revision, create }: PostOptions = {}
+): Promise<Route> {
+  const headers: Record<string, string> = {
+    "Content-Type": "application/json",
+  };
+  if (revision !== undefined) {
+    headers["If-Match"] = `"${revision}"`;
+  } else if (create) {
+    headers["If-None-Match"] = "*";
+  }
+
   const route = await fromResponse(
     await fetch(`/api/url/${name}`, {
       method: "POST",
-      headers: {
-        "Content-Type": "application/json",
-      },
+      headers,
       body: JSON.stringify({ url }),
     }),
     (data: RouteResponse) => (data.route ? toRoute(data.route) : null)